@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gnomegl/usrsx/internal/cli"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff old.json new.json",
+	Short: "Compare two scans",
+	Long: `Compare two JSON exports (--json-output) of the same usernames and report
+accounts that appeared or disappeared, other status changes, and changes
+to profile metadata.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDiff,
+}
+
+func init() {
+	f := diffCmd.Flags()
+	f.StringVarP(&config.DiffJSONPath, "diff-json", "", "", "Export the comparison as JSON to file (- for stdout)")
+	f.StringVarP(&config.DiffMarkdownPath, "diff-md", "", "", "Export the comparison as Markdown to file (- for stdout)")
+	f.BoolVarP(&config.NoColor, "no-color", "C", false, "Disable colored output")
+
+	rootCmd.AddCommand(diffCmd)
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	renderer = cli.NewRenderer(os.Stdout, config.NoColor)
+
+	old, err := cli.LoadJSONExport(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", args[0], err)
+	}
+	current, err := cli.LoadJSONExport(args[1])
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", args[1], err)
+	}
+
+	compareResults(old, current)
+	return nil
+}
+
+// compareResults prints the changes between two scans unless the output is
+// going to stdout in another format, and exports them for --diff-json and
+// --diff-md.
+func compareResults(old, current *cli.Exporter) {
+	report := cli.CompareExports(old, current)
+
+	if !isStdoutExport() {
+		fmt.Println(renderer.FormatDiff(report))
+	}
+
+	if config.DiffJSONPath != "" {
+		if err := cli.ExportDiffJSON(stdoutPath(config.DiffJSONPath), report); err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting comparison: %v\n", err)
+		}
+	}
+	if config.DiffMarkdownPath != "" {
+		if err := cli.ExportDiffMarkdown(stdoutPath(config.DiffMarkdownPath), report); err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting comparison: %v\n", err)
+		}
+	}
+}
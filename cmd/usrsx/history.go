@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gnomegl/usrsx/internal/cli"
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/internal/store"
+	"github.com/gnomegl/usrsx/pkg/events"
+)
+
+var (
+	historyLimit int
+
+	historyCmd = &cobra.Command{
+		Use:   "history",
+		Short: "List, show and re-export recorded scans",
+		Long: `Work with the scans recorded with --record. Without a subcommand the
+most recent runs are listed.`,
+		Args: cobra.NoArgs,
+		RunE: runHistoryList,
+	}
+
+	historyListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List recorded runs, most recent first",
+		Args:  cobra.NoArgs,
+		RunE:  runHistoryList,
+	}
+
+	historyShowCmd = &cobra.Command{
+		Use:   "show run-id",
+		Short: "Show a recorded run and its results",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runHistoryShow,
+	}
+
+	historyExportCmd = &cobra.Command{
+		Use:   "export run-id",
+		Short: "Export a recorded run in any of the export formats",
+		Args:  cobra.ExactArgs(1),
+		RunE:  runHistoryExport,
+	}
+)
+
+func init() {
+	historyCmd.PersistentFlags().StringVarP(&config.HistoryPath, "history-db", "", store.DefaultPath(), "Path of the history database")
+	historyCmd.PersistentFlags().BoolVarP(&config.NoColor, "no-color", "C", false, "Disable colored output")
+
+	for _, cmd := range []*cobra.Command{historyCmd, historyListCmd} {
+		cmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Number of runs to list (0 = all)")
+	}
+
+	f := historyShowCmd.Flags()
+	f.BoolVarP(&config.FilterAll, "filter-all", "a", false, "Show all results")
+	f.BoolVarP(&config.FilterErrors, "filter-errors", "e", false, "Show only errors")
+	f.BoolVarP(&config.FilterNotFound, "filter-not-found", "n", false, "Show only not found")
+	f.BoolVarP(&config.FilterUnknown, "filter-unknown", "u", false, "Show only unknown")
+	f.BoolVarP(&config.FilterAmbiguous, "filter-ambiguous", "g", false, "Show only ambiguous")
+	f.BoolVarP(&config.ShowDetails, "show-details", "d", false, "Show detailed output")
+
+	f = historyExportCmd.Flags()
+	f.BoolVarP(&config.CSVExport, "csv", "c", false, "Output as CSV to stdout")
+	f.StringVarP(&config.CSVPath, "csv-output", "", "", "Export to CSV file (path required)")
+	f.BoolVarP(&config.JSONExport, "json", "j", false, "Output as JSON to stdout")
+	f.StringVarP(&config.JSONPath, "json-output", "", "", "Export to JSON file (path required)")
+	f.BoolVarP(&config.HTMLExport, "html", "H", false, "Export to HTML")
+	f.StringVarP(&config.HTMLPath, "html-path", "T", "", "Custom HTML path")
+	f.StringVarP(&config.PDFPath, "pdf", "", "", "Export to PDF (path required)")
+	f.StringVarP(&config.MarkdownPath, "md-output", "", "", "Export a Markdown report to file (- for stdout)")
+	f.StringVarP(&config.TextPath, "txt-output", "", "", "Export a plain text report to file (- for stdout)")
+
+	historyCmd.AddCommand(historyListCmd, historyShowCmd, historyExportCmd)
+	rootCmd.AddCommand(historyCmd)
+}
+
+// recordRun saves a finished scan for --record. Failing to record is
+// reported but does not fail the scan, whose results were already shown.
+func recordRun(ctx context.Context, started time.Time, lists []core.SiteListVersion, results []core.SiteResult, runErr error) {
+	mode := events.ModeUsernames
+	if config.SelfCheck {
+		mode = events.ModeSelfCheck
+	}
+	run := store.Run{
+		StartedAt:  started,
+		FinishedAt: time.Now(),
+		Version:    core.Version,
+		Mode:       string(mode),
+		Usernames:  config.Usernames,
+		Config:     config.Snapshot(),
+		Lists:      lists,
+	}
+	if runErr != nil {
+		run.Error = runErr.Error()
+	}
+
+	db, err := store.Open(config.HistoryPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error recording run: %v\n", err)
+		return
+	}
+	defer db.Close()
+
+	// The scan context is already cancelled when the run was interrupted,
+	// and the partial results should still be recorded.
+	id, err := db.SaveRun(context.WithoutCancel(ctx), run, results)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error recording run: %v\n", err)
+		return
+	}
+	if !isStdoutExport() {
+		fmt.Printf("Recorded as run %d in %s\n", id, config.HistoryPath)
+	}
+}
+
+func runHistoryList(cmd *cobra.Command, args []string) error {
+	db, err := store.Open(config.HistoryPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	runs, err := db.Runs(cmd.Context(), historyLimit)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Printf("No runs recorded in %s (scan with --record)\n", config.HistoryPath)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTARTED\tDURATION\tMODE\tUSERNAMES\tCHECKS\tFOUND\tSTATUS")
+	for _, run := range runs {
+		status := "complete"
+		if run.Error != "" {
+			status = "aborted: " + run.Error
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			run.ID,
+			run.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			run.FinishedAt.Sub(run.StartedAt).Round(time.Second),
+			run.Mode,
+			strings.Join(run.Usernames, ","),
+			run.Total,
+			run.Found,
+			status)
+	}
+	return tw.Flush()
+}
+
+func runHistoryShow(cmd *cobra.Command, args []string) error {
+	renderer = cli.NewRenderer(os.Stdout, config.NoColor)
+
+	run, results, err := loadRun(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Run %d\n", run.ID)
+	fmt.Printf("  Started:   %s\n", run.StartedAt.Local().Format(time.RFC3339))
+	fmt.Printf("  Finished:  %s (%s)\n", run.FinishedAt.Local().Format(time.RFC3339), run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond))
+	fmt.Printf("  Version:   %s\n", run.Version)
+	fmt.Printf("  Mode:      %s\n", run.Mode)
+	if len(run.Usernames) > 0 {
+		fmt.Printf("  Usernames: %s\n", strings.Join(run.Usernames, ", "))
+	}
+	if run.Error != "" {
+		fmt.Printf("  Aborted:   %s\n", run.Error)
+	}
+	for _, list := range run.Lists {
+		fmt.Printf("  Site list: %s (%d sites, sha256 %s)\n", list.Source, list.Sites, list.SHA256[:12])
+	}
+	fmt.Println()
+
+	for _, result := range results {
+		displayResult(result)
+	}
+	displaySummary(results)
+	return nil
+}
+
+func runHistoryExport(cmd *cobra.Command, args []string) error {
+	if !shouldExport() {
+		return fmt.Errorf("no export format given (see usrsx history export --help)")
+	}
+
+	run, results, err := loadRun(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+
+	exporter := cli.NewExporter(results, run.Usernames)
+	exporter.Timestamp = run.StartedAt.Local()
+	exportResults(exporter)
+	return nil
+}
+
+func loadRun(ctx context.Context, arg string) (store.Run, []core.SiteResult, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return store.Run{}, nil, fmt.Errorf("invalid run id %q", arg)
+	}
+
+	db, err := store.Open(config.HistoryPath)
+	if err != nil {
+		return store.Run{}, nil, err
+	}
+	defer db.Close()
+
+	run, err := db.Run(ctx, id)
+	if err != nil {
+		return store.Run{}, nil, err
+	}
+	results, err := db.Results(ctx, id)
+	if err != nil {
+		return store.Run{}, nil, err
+	}
+	return run, results, nil
+}
@@ -1,26 +1,56 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
+	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 
 	"github.com/gnomegl/usrsx/internal/cli"
 	"github.com/gnomegl/usrsx/internal/client"
 	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/internal/notify"
+	"github.com/gnomegl/usrsx/internal/store"
 	"github.com/gnomegl/usrsx/internal/utils"
+	"github.com/gnomegl/usrsx/pkg/events"
 )
 
+// errCancelledByUser is the cause recorded when the scan is stopped from
+// the progress view.
+var errCancelledByUser = errors.New("cancelled by user")
+
 var (
-	config  cli.Config
-	rootCmd = &cobra.Command{
+	config   cli.Config
+	renderer *cli.Renderer
+	stream   *cli.EventStream // --json event stream, nil without --json
+	notifier *notify.Notifier // --notify webhooks, nil without --notify
+	rootCmd  = &cobra.Command{
 		Use:     "usrsx [username...]",
 		Short:   "Username availability checker across hundreds of websites",
 		Long:    `usrsx is a powerful username enumeration tool that checks username availability across hundreds of websites using the WhatsMyName dataset.`,
 		Version: core.Version,
+		Args:    cobra.ArbitraryArgs,
 		RunE:    runCheck,
+
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	tuiShowAll bool
+	tuiCmd     = &cobra.Command{
+		Use:   "tui results.json",
+		Short: "Browse the results of a previous scan",
+		Long: `Open a JSON export (--json-output) in the full-screen results browser.
+False-positive marks made in the browser are saved back to the file.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runTUI,
 	}
 )
 
@@ -31,7 +61,11 @@ func init() {
 	f.StringSliceVarP(&config.LocalLists, "local-list", "l", []string{}, "Path(s) to local JSON file(s)")
 	f.StringSliceVarP(&config.RemoteLists, "remote-list", "r", []string{}, "URL(s) to fetch remote lists")
 	f.StringVarP(&config.LocalSchema, "local-schema", "L", "", "Path to local schema file")
-	f.StringVarP(&config.RemoteSchema, "remote-schema", "R", core.WMNSchemaURL, "URL to fetch schema")
+	f.StringVarP(&config.RemoteSchema, "remote-schema", "R", core.WMNSchemaURL, "URL to fetch schema (empty disables validation)")
+	f.BoolVarP(&config.StrictSchema, "strict-schema", "", false, "Abort when a site list does not match the schema instead of skipping invalid sites")
+	f.StringVarP(&config.CacheDir, "cache-dir", "", cli.DefaultCacheDir(), "Directory for cached remote site lists")
+	f.DurationVarP(&config.CacheMaxAge, "cache-max-age", "", core.WMNCacheMaxAgeHours*time.Hour, "Use a cached remote list without revalidating it until it is this old")
+	f.BoolVarP(&config.Offline, "offline", "", false, "Load remote lists from the cache only, never from the network")
 	f.BoolVarP(&config.SelfCheck, "self-check", "S", false, "Run self-check mode")
 
 	f.StringSliceVarP(&config.IncludeCategories, "include-categories", "I", []string{}, "Include only these categories")
@@ -49,26 +83,68 @@ func init() {
 	f.BoolVarP(&config.HTMLExport, "html", "H", false, "Export to HTML")
 	f.StringVarP(&config.HTMLPath, "html-path", "T", "", "Custom HTML path")
 	f.StringVarP(&config.PDFPath, "pdf", "", "", "Export to PDF (path required)")
+	f.StringVarP(&config.MarkdownPath, "md-output", "", "", "Export a Markdown report to file (- for stdout)")
+	f.StringVarP(&config.TextPath, "txt-output", "", "", "Export a plain text report to file (- for stdout)")
 
 	f.StringVarP(&config.Proxy, "proxy", "p", "", "Proxy server (http://proxy:port, socks5://proxy:port)")
 	f.StringVarP(&config.ProxyFile, "proxy-file", "F", "", "File containing proxies (one per line)")
+	f.StringVarP(&config.ProxyStrategy, "proxy-strategy", "", string(client.ProxyRoundRobin), "Proxy selection per request (round-robin, random, least-failed)")
 	f.IntVarP(&config.Timeout, "timeout", "t", core.HTTPRequestTimeoutSeconds, "Request timeout in seconds")
 	f.BoolVarP(&config.AllowRedirect, "allow-redirects", "A", core.HTTPAllowRedirects, "Follow HTTP redirects")
 	f.BoolVarP(&config.VerifySSL, "verify-ssl", "V", core.HTTPSSLVerify, "Verify SSL certificates")
-	f.StringVarP(&config.Impersonate, "impersonate", "i", "chrome", "Browser to impersonate (chrome, firefox, safari, edge)")
+	f.StringVarP(&config.Impersonate, "impersonate", "i", "chrome", "Browser to impersonate (chrome, chrome_android, firefox, safari, safari_ios, edge, none)")
 	f.IntVarP(&config.MaxTasks, "max-tasks", "m", core.MaxConcurrentTasks, "Maximum concurrent tasks")
+	f.Float64VarP(&config.PerHostRPS, "per-host-rps", "", 0, "Maximum requests per second to any single host (0 = unlimited)")
+	f.DurationVarP(&config.Deadline, "deadline", "", 0, "Abort the scan after this duration (e.g. 90s, 10m)")
+	f.IntVarP(&config.RetryAttempts, "retries", "", core.RetryMaxAttempts, "Maximum attempts per check (1 disables retries)")
+	f.DurationVarP(&config.RetryBackoff, "retry-backoff", "", core.RetryBaseDelayMillis*time.Millisecond, "Base delay for exponential retry backoff")
+	f.DurationVarP(&config.RetryMaxDelay, "retry-max-delay", "", core.RetryMaxDelaySeconds*time.Second, "Maximum delay between attempts, including Retry-After")
+	f.IntSliceVarP(&config.RetryStatusCodes, "retry-status", "", core.DefaultRetryStatusCodes, "HTTP status codes that trigger a retry")
+	f.BoolVarP(&config.RetryRotateProxy, "retry-rotate-proxy", "", false, "Switch to the next proxy from --proxy-file between attempts")
+	f.StringVarP(&config.ProxyCheckURL, "proxy-check-url", "", "", "Probe every proxy against this URL before scanning and drop dead ones")
+	f.IntVarP(&config.ProxyMaxFailures, "proxy-max-failures", "", core.ProxyMaxFailures, "Consecutive failures before a proxy is quarantined (0 = never)")
+	f.DurationVarP(&config.ProxyCooldown, "proxy-cooldown", "", core.ProxyCooldownSeconds*time.Second, "How long a quarantined proxy is kept out of rotation")
+	f.StringVarP(&config.ProxyReportPath, "proxy-report", "", "", "Export per-proxy statistics to a JSON file")
 
 	f.BoolVarP(&config.FuzzyMode, "fuzzy", "f", false, "Enable fuzzy validation mode")
 	f.BoolVarP(&config.ShowDetails, "show-details", "d", false, "Show detailed output")
 	f.BoolVarP(&config.NoColor, "no-color", "C", false, "Disable colored output")
 	f.BoolVarP(&config.NoProgressbar, "no-progressbar", "P", false, "Disable progress bar")
 	f.BoolVarP(&config.Browse, "browse", "b", false, "Open found profiles in browser")
+	f.IntVarP(&config.BrowseLimit, "browse-limit", "", core.BrowseLimit, "Ask before opening more than this many profiles (0 = never ask)")
+	f.StringSliceVarP(&config.BrowseCategories, "browse-categories", "", []string{}, "Open only profiles on sites in these categories")
+	f.BoolVarP(&config.Interactive, "interactive", "", false, "Browse the results in a full-screen view after the scan")
 	f.BoolVarP(&config.SaveResponse, "save-response", "w", false, "Save HTTP responses")
-	f.StringVarP(&config.ResponsePath, "response-path", "W", "", "Custom path for responses")
-	f.BoolVarP(&config.OpenResponse, "open-response", "o", false, "Open saved responses")
+	f.StringVarP(&config.ResponsePath, "response-path", "W", core.ResponseDir, "Directory for saved responses")
+	f.StringSliceVarP(&config.SaveStatuses, "save-status", "", []string{}, "Save only responses with these result statuses (e.g. found,ambiguous)")
+	f.BoolVarP(&config.OpenResponse, "open-response", "o", false, "Open saved responses of found profiles (implies --save-response)")
+	f.BoolVarP(&config.Record, "record", "", false, "Record the run in the history database")
+	f.StringVarP(&config.HistoryPath, "history-db", "", store.DefaultPath(), "Path of the history database")
+	f.StringVarP(&config.CompareTo, "compare-to", "", "", "Compare the results with a previous JSON export")
+	f.StringVarP(&config.DiffJSONPath, "diff-json", "", "", "Export the comparison as JSON to file (- for stdout)")
+	f.StringVarP(&config.DiffMarkdownPath, "diff-md", "", "", "Export the comparison as Markdown to file (- for stdout)")
+	f.StringArrayVarP(&config.NotifyWebhooks, "notify", "", []string{}, "POST found results and a summary to this webhook ([json|template|slack|discord|mattermost=]url)")
+	f.StringVarP(&config.NotifyTemplate, "notify-template", "", "", "Go template file for the body of template webhooks")
+	f.StringVarP(&config.NotifyContentType, "notify-content-type", "", "application/json", "Content type of template webhook bodies")
+	f.IntVarP(&config.NotifyBatchSize, "notify-batch-size", "", core.NotifyBatchSize, "Most found results per webhook message")
+	f.DurationVarP(&config.NotifyMinInterval, "notify-interval", "", core.NotifyMinIntervalMillis*time.Millisecond, "Least time between two messages to a webhook")
+
+	for _, name := range scanFlags {
+		watchCmd.Flags().AddFlag(f.Lookup(name))
+		serveCmd.Flags().AddFlag(f.Lookup(name))
+	}
+
+	tuiCmd.Flags().BoolVarP(&tuiShowAll, "all", "a", false, "List every result, not only found and ambiguous ones")
+	tuiCmd.Flags().BoolVarP(&config.NoColor, "no-color", "C", false, "Disable colored output")
+	rootCmd.AddCommand(tuiCmd)
 }
 
 func runCheck(cmd *cobra.Command, args []string) error {
+	renderer = cli.NewRenderer(os.Stdout, config.NoColor)
+	if config.JSONExport {
+		stream = cli.NewEventStream(os.Stdout, core.ProgressEventIntervalMillis*time.Millisecond)
+	}
+
 	if !config.SelfCheck {
 		if len(args) == 0 {
 			return fmt.Errorf("at least one username is required")
@@ -76,79 +152,258 @@ func runCheck(cmd *cobra.Command, args []string) error {
 		config.Usernames = args
 	}
 
-	if err := utils.ValidateNumericValues(config.MaxTasks, config.Timeout); err != nil {
+	if err := validateScanConfig(); err != nil {
 		return err
 	}
 
-	if config.Proxy != "" {
-		if err := utils.ValidateProxy(config.Proxy); err != nil {
-			return err
+	if err := utils.ValidateBrowseLimit(config.BrowseLimit); err != nil {
+		return err
+	}
+
+	saveStatuses, err := utils.ParseResultStatuses(config.SaveStatuses)
+	if err != nil {
+		return err
+	}
+
+	if (config.DiffJSONPath != "" || config.DiffMarkdownPath != "") && config.CompareTo == "" {
+		return core.NewConfigurationError("--diff-json and --diff-md need --compare-to", nil)
+	}
+	var previous *cli.Exporter
+	if config.CompareTo != "" {
+		previous, err = cli.LoadJSONExport(config.CompareTo)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", config.CompareTo, err)
 		}
 	}
 
 	if len(config.Usernames) > 0 {
-		var err error
 		config.Usernames, err = utils.ValidateUsernames(config.Usernames)
 		if err != nil {
 			return err
 		}
 	}
 
-	wmnData, err := cli.LoadWMNData(&config)
+	notifyConfig, err := notifyConfig()
 	if err != nil {
-		return fmt.Errorf("failed to load WMN data: %w", err)
+		return err
 	}
-	if !isStdoutExport() {
-		fmt.Printf("Loaded %d sites\n", len(wmnData.Sites))
+
+	ctx := cmd.Context()
+	if config.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Deadline)
+		defer cancel()
 	}
 
-	sites := wmnData.Sites
-	if len(config.SiteNames) > 0 {
-		sites, err = utils.FilterSites(config.SiteNames, sites)
-		if err != nil {
-			return err
+	httpClient, err := newHTTPClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	wmnData, sites, err := loadSites(ctx, httpClient)
+	if err != nil {
+		return err
+	}
+
+	var responses core.ResponseSaver
+	if config.SaveResponse || config.OpenResponse {
+		responses = cli.NewResponseStore(config.ResponsePath, saveStatuses)
+	}
+	checker := newChecker(httpClient, wmnData, responses)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stopScan := func() { cancel(errCancelledByUser) }
+
+	var results []core.SiteResult
+	started := time.Now()
+	if notifyConfig != nil {
+		notifier = notify.New(*notifyConfig, config.Usernames)
+	}
+
+	if config.SelfCheck {
+		results = runSelfCheck(ctx, stopScan, checker, sites)
+	} else {
+		results = runUsernameCheck(ctx, stopScan, checker, sites)
+	}
+
+	var runErr error
+	if ctx.Err() != nil {
+		runErr = context.Cause(ctx)
+		fmt.Fprintf(os.Stderr, "Scan aborted (%v), reporting partial results\n", runErr)
+	}
+
+	if config.Interactive {
+		results = browseResults(cmd.Context(), results)
+	}
+
+	if config.Browse {
+		openFound(cmd.Context(), "profiles", cli.BrowseTargets(results, config.BrowseCategories))
+	}
+	if config.OpenResponse {
+		openFound(cmd.Context(), "saved responses", cli.ResponseTargets(results, config.BrowseCategories))
+	}
+
+	if shouldExport() && !config.JSONExport {
+		exportResults(cli.NewExporter(results, config.Usernames))
+	}
+
+	if previous != nil {
+		if !isStdoutExport() {
+			fmt.Println()
 		}
+		compareResults(previous, cli.NewExporter(results, config.Usernames))
+	}
+
+	if stream != nil {
+		emitEvent(stream.RunFinished(results, runErr))
+	}
+
+	if notifier != nil {
+		finishNotifications(cmd.Context(), started, results, runErr)
+	}
+
+	if config.Record {
+		recordRun(cmd.Context(), started, wmnData.Lists, results, runErr)
+	}
+
+	if httpClient.HasProxies() {
+		report := httpClient.ProxyReport()
 		if !isStdoutExport() {
-			fmt.Printf("Filtered to %d sites\n", len(sites))
+			fmt.Println(renderer.FormatProxyReport(report))
+		}
+		if config.ProxyReportPath != "" {
+			if err := cli.ExportProxyReport(config.ProxyReportPath, report); err != nil {
+				fmt.Fprintf(os.Stderr, "Error exporting proxy report: %v\n", err)
+			}
+		}
+	}
+
+	if runErr != nil {
+		return fmt.Errorf("scan aborted: %w", runErr)
+	}
+	return nil
+}
+
+// validateScanConfig checks the options shared by scans and watch.
+func validateScanConfig() error {
+	if err := utils.ValidateNumericValues(config.MaxTasks, config.Timeout); err != nil {
+		return err
+	}
+
+	if err := utils.ValidatePerHostRPS(config.PerHostRPS); err != nil {
+		return err
+	}
+
+	if err := utils.ValidateRetryAttempts(config.RetryAttempts); err != nil {
+		return err
+	}
+
+	if config.Proxy != "" {
+		if err := utils.ValidateProxy(config.Proxy); err != nil {
+			return err
 		}
 	}
 
+	if _, err := client.ParseBrowserImpersonation(config.Impersonate); err != nil {
+		return core.NewConfigurationError("Invalid browser", err)
+	}
+
+	if _, err := client.ParseProxyStrategy(config.ProxyStrategy); err != nil {
+		return core.NewConfigurationError("Invalid proxy strategy", err)
+	}
+	return nil
+}
+
+// newHTTPClient builds the HTTP client and, with --proxy-check-url, drops
+// the proxies that fail the health check.
+func newHTTPClient(ctx context.Context) (*client.HTTPClient, error) {
+	browser, err := client.ParseBrowserImpersonation(config.Impersonate)
+	if err != nil {
+		return nil, core.NewConfigurationError("Invalid browser", err)
+	}
+
+	proxyStrategy, err := client.ParseProxyStrategy(config.ProxyStrategy)
+	if err != nil {
+		return nil, core.NewConfigurationError("Invalid proxy strategy", err)
+	}
+
 	clientConfig := client.ClientConfig{
 		Timeout:       config.Timeout,
 		VerifySSL:     config.VerifySSL,
 		AllowRedirect: config.AllowRedirect,
-		Impersonate:   client.BrowserImpersonation(config.Impersonate),
+		Impersonate:   browser,
 		Proxy:         config.Proxy,
 		ProxyFile:     config.ProxyFile,
+		ProxyStrategy: proxyStrategy,
+
+		ProxyMaxFailures: config.ProxyMaxFailures,
+		ProxyCooldown:    config.ProxyCooldown,
 	}
 
 	httpClient, err := client.NewHTTPClient(clientConfig)
 	if err != nil {
-		return fmt.Errorf("failed to create HTTP client: %w", err)
+		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
 	}
 
-	checker := core.NewChecker(httpClient, wmnData, config.MaxTasks)
-
-	var results []core.SiteResult
-
-	if config.SelfCheck {
-		results = runSelfCheck(checker, sites)
-	} else {
-		results = runUsernameCheck(checker, sites)
+	if config.ProxyCheckURL != "" && httpClient.HasProxies() {
+		if err := httpClient.CheckProxies(ctx, config.ProxyCheckURL); err != nil {
+			return nil, core.NewNetworkError("Proxy health check failed", err)
+		}
+		if !isStdoutExport() {
+			report := httpClient.ProxyReport()
+			alive := 0
+			for _, stats := range report {
+				if stats.Status != client.ProxyStatusEvicted {
+					alive++
+				}
+			}
+			fmt.Printf("%d/%d proxies passed the health check\n", alive, len(report))
+		}
 	}
+	return httpClient, nil
+}
 
-	if shouldExport() && !config.JSONExport {
-		exportResults(results)
+// loadSites loads the site lists and returns them with the sites to check,
+// narrowed down by --site.
+func loadSites(ctx context.Context, httpClient *client.HTTPClient) (*core.WMNData, []core.Site, error) {
+	wmnData, err := cli.LoadWMNData(ctx, &config, httpClient)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load WMN data: %w", err)
+	}
+	if !isStdoutExport() {
+		fmt.Printf("Loaded %d sites\n", len(wmnData.Sites))
 	}
 
-	if config.JSONExport {
-		cli.StreamJSONSummary(results, config.Usernames)
+	sites := wmnData.Sites
+	if len(config.SiteNames) > 0 {
+		sites, err = utils.FilterSites(config.SiteNames, sites)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !isStdoutExport() {
+			fmt.Printf("Filtered to %d sites\n", len(sites))
+		}
 	}
+	return wmnData, sites, nil
+}
 
-	return nil
+func newChecker(httpClient *client.HTTPClient, wmnData *core.WMNData, responses core.ResponseSaver) *core.Checker {
+	return core.NewChecker(httpClient, wmnData, core.CheckerConfig{
+		MaxTasks:   config.MaxTasks,
+		PerHostRPS: config.PerHostRPS,
+		Retry: core.RetryPolicy{
+			MaxAttempts: config.RetryAttempts,
+			BaseDelay:   config.RetryBackoff,
+			MaxDelay:    config.RetryMaxDelay,
+			StatusCodes: config.RetryStatusCodes,
+			RotateProxy: config.RetryRotateProxy,
+		},
+		Responses: responses,
+	})
 }
 
-func runUsernameCheck(checker *core.Checker, sites []core.Site) []core.SiteResult {
+func runUsernameCheck(ctx context.Context, stopScan context.CancelFunc, checker *core.Checker, sites []core.Site) []core.SiteResult {
 	totalChecks := len(config.Usernames) * len(sites)
 
 	if !isStdoutExport() {
@@ -156,21 +411,47 @@ func runUsernameCheck(checker *core.Checker, sites []core.Site) []core.SiteResul
 			len(config.Usernames), len(sites), totalChecks)
 	}
 
-	progressChan := make(chan core.SiteResult, totalChecks)
+	if stream != nil {
+		emitEvent(stream.RunStarted(events.ModeUsernames, config.Usernames, sites, totalChecks))
+	}
+
+	progressChan := make(chan core.SiteResult, config.MaxTasks)
 	results := make([]core.SiteResult, 0, totalChecks)
 
 	go func() {
-		checker.CheckUsernames(config.Usernames, sites, config.FuzzyMode, progressChan)
+		checker.CheckUsernames(ctx, config.Usernames, sites, config.FuzzyMode, progressChan)
 		close(progressChan)
 	}()
 
-	for result := range progressChan {
-		results = append(results, result)
-		if !isStdoutExport() {
-			displayResult(result)
-		} else if config.JSONExport {
-			if shouldStreamJSON(result) {
-				cli.StreamJSON(result)
+	if useProgressView() {
+		err := cli.RunProgress(cli.ProgressConfig{
+			Total:       totalChecks,
+			ShowDetails: config.ShowDetails,
+			Renderer:    renderer,
+			Display:     shouldDisplayResult,
+			Cancel:      stopScan,
+		}, func(p *tea.Program) {
+			for result := range progressChan {
+				results = append(results, result)
+				if notifier != nil {
+					notifier.Found(result)
+				}
+				p.Send(cli.ResultMsg{Result: result})
+			}
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Progress display failed: %v\n", err)
+		}
+	} else {
+		for result := range progressChan {
+			results = append(results, result)
+			if notifier != nil {
+				notifier.Found(result)
+			}
+			if !isStdoutExport() {
+				displayResult(result)
+			} else if stream != nil {
+				emitEvent(stream.Result(result, shouldStreamJSON(result)))
 			}
 		}
 	}
@@ -181,33 +462,60 @@ func runUsernameCheck(checker *core.Checker, sites []core.Site) []core.SiteResul
 	return results
 }
 
-func runSelfCheck(checker *core.Checker, sites []core.Site) []core.SiteResult {
+func runSelfCheck(ctx context.Context, stopScan context.CancelFunc, checker *core.Checker, sites []core.Site) []core.SiteResult {
+	// Only sites that list known accounts are checked, so only they are
+	// announced in the event stream.
+	var checked []core.Site
+	totalChecks := 0
+	for _, site := range sites {
+		if len(site.Known) > 0 {
+			checked = append(checked, site)
+			totalChecks += len(site.Known)
+		}
+	}
+	sites = checked
+
 	if !isStdoutExport() {
 		fmt.Printf("\nRunning self-check on %d sites\n\n", len(sites))
 	}
+	if stream != nil {
+		emitEvent(stream.RunStarted(events.ModeSelfCheck, nil, sites, totalChecks))
+	}
 
-	progressChan := make(chan core.SelfCheckResult, len(sites))
+	progressChan := make(chan core.SelfCheckResult, config.MaxTasks)
 	allResults := make([]core.SiteResult, 0)
 
 	go func() {
-		selfCheckResults := checker.SelfCheck(sites, config.FuzzyMode, progressChan)
-		for _, scr := range selfCheckResults {
-			progressChan <- scr
-		}
+		checker.SelfCheck(ctx, sites, config.FuzzyMode, progressChan)
 		close(progressChan)
 	}()
 
-	for selfCheckResult := range progressChan {
-		if !isStdoutExport() {
-			fmt.Println(cli.FormatSelfCheckResult(selfCheckResult, config.ShowDetails))
-		} else if config.JSONExport {
-			for _, result := range selfCheckResult.Results {
-				if shouldStreamJSON(result) {
-					cli.StreamJSON(result)
+	if useProgressView() {
+		err := cli.RunProgress(cli.ProgressConfig{
+			Total:       totalChecks,
+			ShowDetails: config.ShowDetails,
+			Renderer:    renderer,
+			Cancel:      stopScan,
+		}, func(p *tea.Program) {
+			for selfCheckResult := range progressChan {
+				allResults = append(allResults, selfCheckResult.Results...)
+				p.Send(cli.SelfCheckMsg{Result: selfCheckResult})
+			}
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Progress display failed: %v\n", err)
+		}
+	} else {
+		for selfCheckResult := range progressChan {
+			if !isStdoutExport() {
+				fmt.Println(renderer.FormatSelfCheckResult(selfCheckResult, config.ShowDetails))
+			} else if stream != nil {
+				for _, result := range selfCheckResult.Results {
+					emitEvent(stream.Result(result, shouldStreamJSON(result)))
 				}
 			}
+			allResults = append(allResults, selfCheckResult.Results...)
 		}
-		allResults = append(allResults, selfCheckResult.Results...)
 	}
 
 	if !isStdoutExport() {
@@ -216,12 +524,113 @@ func runSelfCheck(checker *core.Checker, sites []core.Site) []core.SiteResult {
 	return allResults
 }
 
+// useProgressView reports whether the scan runs under the live progress
+// view. Machine-readable output, --no-progressbar and a stdout that is not
+// a terminal all fall back to plain line output.
+func useProgressView() bool {
+	if isStdoutExport() || config.NoProgressbar {
+		return false
+	}
+	return stdoutIsTerminal()
+}
+
+func stdoutIsTerminal() bool {
+	return isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())
+}
+
+// browseResults opens the results browser for --interactive and returns
+// the results with the false-positive marks made in it, so that they end
+// up in the exports.
+func browseResults(ctx context.Context, results []core.SiteResult) []core.SiteResult {
+	if isStdoutExport() || !stdoutIsTerminal() {
+		fmt.Fprintf(os.Stderr, "Warning: --interactive needs a terminal on stdout, skipping the results browser\n")
+		return results
+	}
+
+	marked, _, err := cli.RunBrowser(ctx, cli.BrowserConfig{
+		Results:   results,
+		Usernames: config.Usernames,
+		Renderer:  renderer,
+		ShowAll:   config.FilterAll,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Results browser failed: %v\n", err)
+	}
+	return marked
+}
+
+// openFound opens urls for --browse and --open-response. Above
+// --browse-limit the user is asked on the terminal whether to open all of
+// them; without a terminal on stdin only the first --browse-limit are
+// opened.
+func openFound(ctx context.Context, what string, urls []string) {
+	if len(urls) == 0 {
+		return
+	}
+
+	browseConfig := cli.BrowseConfig{
+		Opener:   cli.SystemOpener{},
+		Limit:    config.BrowseLimit,
+		Interval: core.BrowseIntervalMillis * time.Millisecond,
+	}
+	if isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		browseConfig.Confirm = func(n int) bool {
+			return cli.PromptConfirm(os.Stdin, os.Stderr,
+				fmt.Sprintf("Open all %d found %s in the browser? Otherwise only the first %d are opened.", n, what, config.BrowseLimit))
+		}
+	}
+
+	opened, err := cli.OpenURLs(ctx, urls, browseConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", what, err)
+	}
+	if opened < len(urls) {
+		fmt.Fprintf(os.Stderr, "Opened %d of %d found %s\n", opened, len(urls), what)
+	}
+}
+
+func runTUI(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	exporter, err := cli.LoadJSONExport(path)
+	if err != nil {
+		return fmt.Errorf("failed to load results: %w", err)
+	}
+	if !stdoutIsTerminal() {
+		return fmt.Errorf("the results browser needs a terminal on stdout")
+	}
+
+	results, changed, err := cli.RunBrowser(cmd.Context(), cli.BrowserConfig{
+		Results:   exporter.Results,
+		Usernames: exporter.Usernames,
+		Renderer:  cli.NewRenderer(os.Stdout, config.NoColor),
+		ShowAll:   tuiShowAll,
+	})
+	if err != nil {
+		return fmt.Errorf("results browser failed: %w", err)
+	}
+	if !changed {
+		return nil
+	}
+
+	exporter.Results = results
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to save false-positive marks: %w", err)
+	}
+	defer file.Close()
+	if err := exporter.WriteJSON(file); err != nil {
+		return fmt.Errorf("failed to save false-positive marks: %w", err)
+	}
+	fmt.Printf("Saved false-positive marks to %s\n", path)
+	return nil
+}
+
 func displayResult(result core.SiteResult) {
 	if !shouldDisplayResult(result) {
 		return
 	}
 
-	fmt.Println(cli.FormatResult(result, config.ShowDetails))
+	fmt.Println(renderer.FormatResult(result, config.ShowDetails))
 }
 
 func shouldDisplayResult(result core.SiteResult) bool {
@@ -246,6 +655,13 @@ func shouldDisplayResult(result core.SiteResult) bool {
 	return false
 }
 
+// emitEvent reports a failed write to the --json event stream.
+func emitEvent(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing event: %v\n", err)
+	}
+}
+
 func shouldStreamJSON(result core.SiteResult) bool {
 	if config.FilterAll {
 		return true
@@ -269,50 +685,29 @@ func shouldStreamJSON(result core.SiteResult) bool {
 }
 
 func displaySummary(results []core.SiteResult) {
-	found := 0
-	notFound := 0
-	errors := 0
-	unknown := 0
-	ambiguous := 0
-
-	for _, r := range results {
-		switch r.ResultStatus {
-		case core.ResultStatusFound:
-			found++
-		case core.ResultStatusNotFound:
-			notFound++
-		case core.ResultStatusError:
-			errors++
-		case core.ResultStatusUnknown:
-			unknown++
-		case core.ResultStatusAmbiguous:
-			ambiguous++
-		}
-	}
-
-	fmt.Println("\n" + strings.Repeat("=", 50))
-	fmt.Println("Summary")
-	fmt.Println(strings.Repeat("=", 50))
-	fmt.Printf("Total: %d\n", len(results))
-	fmt.Printf("Found: %d\n", found)
-	fmt.Printf("Not Found: %d\n", notFound)
-	fmt.Printf("Errors: %d\n", errors)
-	fmt.Printf("Unknown: %d\n", unknown)
-	fmt.Printf("Ambiguous: %d\n", ambiguous)
-	fmt.Println(strings.Repeat("=", 50))
+	fmt.Println(renderer.FormatSummary(results))
 }
 
 func isStdoutExport() bool {
-	return config.JSONExport || config.CSVExport
+	return config.JSONExport || config.CSVExport || config.MarkdownPath == "-" || config.TextPath == "-" ||
+		config.DiffJSONPath == "-" || config.DiffMarkdownPath == "-" || config.WatchOutput == "-"
 }
 
 func shouldExport() bool {
-	return config.CSVExport || config.CSVPath != "" || config.JSONExport || config.JSONPath != "" || config.HTMLExport || config.PDFPath != ""
+	return config.CSVExport || config.CSVPath != "" || config.JSONExport || config.JSONPath != "" || config.HTMLExport || config.PDFPath != "" ||
+		config.MarkdownPath != "" || config.TextPath != ""
 }
 
-func exportResults(results []core.SiteResult) {
-	exporter := cli.NewExporter(results, config.Usernames)
+// stdoutPath maps the "-" of a report flag to the empty path the exporters
+// take for stdout.
+func stdoutPath(path string) string {
+	if path == "-" {
+		return ""
+	}
+	return path
+}
 
+func exportResults(exporter *cli.Exporter) {
 	if config.CSVExport {
 		if err := exporter.ExportCSV(""); err != nil {
 			fmt.Fprintf(os.Stderr, "Error exporting CSV: %v\n", err)
@@ -348,10 +743,32 @@ func exportResults(results []core.SiteResult) {
 			fmt.Fprintf(os.Stderr, "Error exporting PDF: %v\n", err)
 		}
 	}
+
+	if config.MarkdownPath != "" {
+		if err := exporter.ExportMarkdown(stdoutPath(config.MarkdownPath)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting Markdown: %v\n", err)
+		}
+	}
+
+	if config.TextPath != "" {
+		if err := exporter.ExportText(stdoutPath(config.TextPath)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting text: %v\n", err)
+		}
+	}
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// A second interrupt after cancellation falls through to the default
+	// handler and kills the process immediately.
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/cli"
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/internal/notify"
+	"github.com/gnomegl/usrsx/internal/utils"
+)
+
+// notifyConfig builds the notifier configuration from the --notify
+// options. It returns nil without --notify.
+func notifyConfig() (*notify.Config, error) {
+	if len(config.NotifyWebhooks) == 0 {
+		if config.NotifyTemplate != "" {
+			return nil, core.NewConfigurationError("--notify-template needs --notify", nil)
+		}
+		return nil, nil
+	}
+	if config.SelfCheck {
+		return nil, core.NewConfigurationError("--notify does not apply to --self-check", nil)
+	}
+	if config.NotifyBatchSize < 1 {
+		return nil, core.NewConfigurationError(
+			fmt.Sprintf("Invalid notify-batch-size: %d must be at least 1", config.NotifyBatchSize), nil)
+	}
+
+	var tmpl *notify.Template
+	if config.NotifyTemplate != "" {
+		var err error
+		tmpl, err = notify.LoadTemplate(config.NotifyTemplate, config.NotifyContentType)
+		if err != nil {
+			return nil, core.NewConfigurationError("Invalid notify-template", err)
+		}
+	}
+
+	notifyConfig := &notify.Config{
+		BatchSize:   config.NotifyBatchSize,
+		MinInterval: config.NotifyMinInterval,
+	}
+	for _, spec := range config.NotifyWebhooks {
+		webhook, err := notify.ParseWebhook(spec, tmpl)
+		if err != nil {
+			return nil, core.NewConfigurationError("Invalid notify webhook", err)
+		}
+		if err := utils.ValidateWebhookURL(webhook.URL); err != nil {
+			return nil, err
+		}
+		notifyConfig.Webhooks = append(notifyConfig.Webhooks, webhook)
+	}
+	return notifyConfig, nil
+}
+
+// finishNotifications sends the end-of-run summary and reports webhooks
+// that failed. Interrupted runs are reported too, so this does not stop
+// when the scan context is cancelled.
+func finishNotifications(ctx context.Context, started time.Time, results []core.SiteResult, runErr error) {
+	summary := notify.Summary{
+		Summary: cli.Summarize(results),
+		Elapsed: time.Since(started).Seconds(),
+	}
+	if runErr != nil {
+		summary.Error = runErr.Error()
+	}
+
+	if err := notifier.Finish(context.WithoutCancel(ctx), summary); err != nil {
+		fmt.Fprintf(os.Stderr, "Error sending notifications: %v\n", err)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/internal/server"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a REST API for running scans",
+	Long: `Serve a REST API that runs scan jobs: submit usernames with
+POST /api/v1/scans, poll the job, follow its events as Server-Sent Events
+and fetch its results in any export format.
+
+Every request needs an API token as a Bearer credential, taken from
+USRSX_API_TOKEN and --api-token-file. The scan flags apply to all jobs;
+--max-tasks caps the checks in flight across all of them and --deadline is
+the longest a job may run. The site lists are loaded once at startup.`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	f := serveCmd.Flags()
+	f.StringVarP(&config.ServeListen, "listen", "", core.ServeListenAddr, "Address to listen on")
+	f.StringVarP(&config.APITokenFile, "api-token-file", "", "", "File with accepted API tokens, one per line")
+	f.IntVarP(&config.ServeMaxJobs, "max-jobs", "", core.ServeMaxJobs, "Most jobs kept; the oldest finished one makes room for a new one")
+
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if err := validateScanConfig(); err != nil {
+		return err
+	}
+	if config.ServeMaxJobs < 1 {
+		return core.NewConfigurationError(
+			fmt.Sprintf("Invalid max-jobs: %d must be at least 1", config.ServeMaxJobs), nil)
+	}
+	tokens, err := apiTokens()
+	if err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	httpClient, err := newHTTPClient(ctx)
+	if err != nil {
+		return err
+	}
+	wmnData, sites, err := loadSites(ctx, httpClient)
+	if err != nil {
+		return err
+	}
+
+	api := server.New(server.Config{
+		Checker:  newChecker(httpClient, wmnData, nil),
+		Sites:    sites,
+		Tokens:   tokens,
+		Deadline: config.Deadline,
+		MaxJobs:  config.ServeMaxJobs,
+	})
+
+	listener, err := net.Listen("tcp", config.ServeListen)
+	if err != nil {
+		return core.NewConfigurationError("Cannot listen on "+config.ServeListen, err)
+	}
+	httpServer := &http.Server{
+		Handler:           api.Handler(),
+		ReadHeaderTimeout: core.ServeReadHeaderSeconds * time.Second,
+	}
+
+	fmt.Printf("Serving the API on http://%s/api/v1/\n", listener.Addr())
+	served := make(chan error, 1)
+	go func() {
+		served <- httpServer.Serve(listener)
+	}()
+
+	select {
+	case err := <-served:
+		api.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	// Aborting the jobs first ends their event streams, which Shutdown
+	// would otherwise wait for.
+	fmt.Println("Shutting down")
+	api.Close()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), core.ServeShutdownSeconds*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return nil
+}
+
+// apiTokens returns the tokens from USRSX_API_TOKEN and --api-token-file.
+// Blank lines and lines starting with # in the file are skipped.
+func apiTokens() ([]string, error) {
+	var tokens []string
+	if token := strings.TrimSpace(os.Getenv("USRSX_API_TOKEN")); token != "" {
+		tokens = append(tokens, token)
+	}
+
+	if config.APITokenFile != "" {
+		file, err := os.Open(config.APITokenFile)
+		if err != nil {
+			return nil, core.NewConfigurationError("Cannot read api-token-file", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				tokens = append(tokens, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, core.NewConfigurationError("Cannot read api-token-file", err)
+		}
+	}
+
+	if len(tokens) == 0 {
+		return nil, core.NewConfigurationError("serve needs an API token in USRSX_API_TOKEN or --api-token-file", nil)
+	}
+	return tokens, nil
+}
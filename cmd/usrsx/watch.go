@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/internal/diff"
+	"github.com/gnomegl/usrsx/internal/notify"
+	"github.com/gnomegl/usrsx/internal/store"
+	"github.com/gnomegl/usrsx/internal/utils"
+	"github.com/gnomegl/usrsx/internal/watch"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch username...",
+	Short: "Rescan usernames on a schedule and report changes",
+	Long: `Scan the usernames every --interval and report only what changed since
+the previous scan: accounts that appeared, accounts that disappeared and
+changed profile metadata. Changes are written as JSON lines to stdout or
+--output, and posted to --webhook.
+
+The last scan is kept in --state, so a restarted watcher compares against
+it and waits out the rest of the interval instead of scanning right away.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runWatch,
+}
+
+// scanFlags are the root command's flags that shape a scan. watch and
+// serve share them, so they are added to both in main.go's init once they
+// exist.
+var scanFlags = []string{
+	"site", "local-list", "remote-list", "local-schema", "remote-schema", "strict-schema",
+	"cache-dir", "cache-max-age", "offline", "include-categories", "exclude-categories",
+	"proxy", "proxy-file", "proxy-strategy", "proxy-check-url", "proxy-max-failures", "proxy-cooldown",
+	"timeout", "allow-redirects", "verify-ssl", "impersonate", "max-tasks", "per-host-rps", "deadline",
+	"retries", "retry-backoff", "retry-max-delay", "retry-status", "retry-rotate-proxy", "fuzzy",
+}
+
+func init() {
+	f := watchCmd.Flags()
+	f.DurationVarP(&config.WatchInterval, "interval", "", core.WatchIntervalHours*time.Hour, "Time between the starts of two scans")
+	f.StringVarP(&config.WatchStatePath, "state", "", "", "State file of the last scan (default: per username set, next to the history database)")
+	f.StringVarP(&config.WatchOutput, "output", "", "", "Append changes to this file instead of stdout (- for stdout)")
+	f.StringVarP(&config.WebhookURL, "webhook", "", "", "POST the changes of each scan as JSON to this URL")
+
+	rootCmd.AddCommand(watchCmd)
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	usernames, err := utils.ValidateUsernames(args)
+	if err != nil {
+		return err
+	}
+	config.Usernames = usernames
+
+	if err := validateScanConfig(); err != nil {
+		return err
+	}
+	if err := utils.ValidateWatchInterval(config.WatchInterval); err != nil {
+		return err
+	}
+	if config.WebhookURL != "" {
+		if err := utils.ValidateWebhookURL(config.WebhookURL); err != nil {
+			return err
+		}
+	}
+
+	if config.WatchStatePath == "" {
+		config.WatchStatePath = watchStatePath(config.Usernames)
+		if config.WatchStatePath == "" {
+			return core.NewConfigurationError("no default state file location, use --state", nil)
+		}
+	}
+
+	var sinks []watch.Sink
+	if config.WatchOutput == "" || config.WatchOutput == "-" {
+		// Marks stdout as taken for isStdoutExport.
+		config.WatchOutput = "-"
+		sinks = append(sinks, watch.NewJSONLines(os.Stdout))
+	} else {
+		file, err := os.OpenFile(config.WatchOutput, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", config.WatchOutput, err)
+		}
+		defer file.Close()
+		sinks = append(sinks, watch.NewJSONLines(file))
+	}
+	if config.WebhookURL != "" {
+		sinks = append(sinks, watch.NewWebhook(config.WebhookURL, notify.Config{}))
+	}
+
+	ctx := cmd.Context()
+	httpClient, err := newHTTPClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !isStdoutExport() {
+		fmt.Printf("Watching %s every %s (state: %s)\n", strings.Join(config.Usernames, ", "), config.WatchInterval, config.WatchStatePath)
+	}
+
+	return watch.Run(ctx, watch.Config{
+		Usernames: config.Usernames,
+		Interval:  config.WatchInterval,
+		StatePath: config.WatchStatePath,
+		Sinks:     sinks,
+		Scan: func(ctx context.Context) ([]core.SiteResult, error) {
+			if config.Deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, config.Deadline)
+				defer cancel()
+			}
+
+			// The site lists are reloaded for every scan so that a watcher
+			// picks up list updates; the cache keeps this cheap.
+			wmnData, sites, err := loadSites(ctx, httpClient)
+			if err != nil {
+				return nil, err
+			}
+			checker := newChecker(httpClient, wmnData, nil)
+
+			progressChan := make(chan core.SiteResult, config.MaxTasks)
+			go func() {
+				checker.CheckUsernames(ctx, config.Usernames, sites, config.FuzzyMode, progressChan)
+				close(progressChan)
+			}()
+
+			results := make([]core.SiteResult, 0, len(config.Usernames)*len(sites))
+			for result := range progressChan {
+				results = append(results, result)
+			}
+			return results, nil
+		},
+		OnScan: func(report diff.Report, next time.Time) {
+			if !isStdoutExport() {
+				fmt.Printf("%s: %d change(s), next scan at %s\n",
+					report.New.Timestamp.Format(time.RFC3339), len(report.Changes), next.Format(time.RFC3339))
+			}
+		},
+		OnError: func(err error) {
+			fmt.Fprintf(os.Stderr, "Watch error: %v\n", err)
+		},
+	})
+}
+
+// watchStatePath returns the default state file for a set of usernames:
+// one per set, under watch/ next to the history database.
+func watchStatePath(usernames []string) string {
+	history := store.DefaultPath()
+	if history == "" {
+		return ""
+	}
+
+	sorted := append([]string(nil), usernames...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\x00")))
+	return filepath.Join(filepath.Dir(history), "watch", hex.EncodeToString(sum[:6])+".json")
+}
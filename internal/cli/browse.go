@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+type BrowseConfig struct {
+	Opener Opener
+
+	// Limit is how many URLs are opened without asking. 0 never asks.
+	Limit int
+
+	// Confirm is asked whether all n URLs should be opened when there are
+	// more than Limit. Without it, or when it declines, only the first
+	// Limit are opened.
+	Confirm func(n int) bool
+
+	// Interval spaces out the browser launches.
+	Interval time.Duration
+}
+
+// BrowseTargets returns the URLs --browse opens: found results that are
+// not marked as false positives, in the given categories (all when empty),
+// without duplicates.
+func BrowseTargets(results []core.SiteResult, categories []string) []string {
+	wanted := inCategories(categories)
+
+	var urls []string
+	seen := make(map[string]bool)
+	for _, result := range results {
+		if result.ResultStatus != core.ResultStatusFound || result.FalsePositive || result.ResultURL == "" {
+			continue
+		}
+		if !wanted(result.Category) {
+			continue
+		}
+		if seen[result.ResultURL] {
+			continue
+		}
+		seen[result.ResultURL] = true
+		urls = append(urls, result.ResultURL)
+	}
+	return urls
+}
+
+// ResponseTargets returns file URLs of the saved responses of the results
+// BrowseTargets would pick, for --open-response.
+func ResponseTargets(results []core.SiteResult, categories []string) []string {
+	wanted := inCategories(categories)
+
+	var urls []string
+	for _, result := range results {
+		if result.ResultStatus != core.ResultStatusFound || result.FalsePositive || result.ResponsePath == "" {
+			continue
+		}
+		if !wanted(result.Category) {
+			continue
+		}
+		path, err := filepath.Abs(result.ResponsePath)
+		if err != nil {
+			continue
+		}
+		path = filepath.ToSlash(path)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		urls = append(urls, (&url.URL{Scheme: "file", Path: path}).String())
+	}
+	return urls
+}
+
+// OpenURLs opens urls with the configured Opener and returns how many were
+// opened. A URL that fails to open does not stop the others.
+func OpenURLs(ctx context.Context, urls []string, config BrowseConfig) (int, error) {
+	if config.Limit > 0 && len(urls) > config.Limit {
+		if config.Confirm == nil || !config.Confirm(len(urls)) {
+			urls = urls[:config.Limit]
+		}
+	}
+
+	opened := 0
+	var errs []error
+	for i, target := range urls {
+		if i > 0 && config.Interval > 0 {
+			select {
+			case <-ctx.Done():
+				return opened, ctx.Err()
+			case <-time.After(config.Interval):
+			}
+		}
+		if err := config.Opener.Open(target); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		opened++
+	}
+	return opened, errors.Join(errs...)
+}
+
+// inCategories returns a case-insensitive membership test for categories
+// that accepts everything when categories is empty.
+func inCategories(categories []string) func(string) bool {
+	set := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		set[strings.ToLower(category)] = true
+	}
+	return func(category string) bool {
+		return len(set) == 0 || set[strings.ToLower(category)]
+	}
+}
+
+// PromptConfirm writes question to out and reports whether the answer read
+// from in is yes. Anything else, including EOF, is a no.
+func PromptConfirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
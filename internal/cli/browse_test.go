@@ -0,0 +1,228 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+func TestOpenURLs(t *testing.T) {
+	urls := []string{"https://a.example", "https://b.example", "https://c.example"}
+
+	tests := []struct {
+		name      string
+		limit     int
+		confirm   func(n int) bool
+		fail      map[string]bool
+		want      []string
+		wantAsked int
+		wantErr   bool
+	}{
+		{name: "no limit", want: urls},
+		{name: "under the limit", limit: 3, want: urls},
+		{name: "over the limit without a terminal", limit: 2, want: urls[:2]},
+		{name: "over the limit confirmed", limit: 2, confirm: func(int) bool { return true }, want: urls, wantAsked: 3},
+		{name: "over the limit declined", limit: 1, confirm: func(int) bool { return false }, want: urls[:1], wantAsked: 3},
+		{name: "failure does not stop the rest", fail: map[string]bool{urls[1]: true}, want: []string{urls[0], urls[2]}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opener := &fakeOpener{fail: tt.fail}
+			asked := 0
+			config := BrowseConfig{Opener: opener, Limit: tt.limit}
+			if tt.confirm != nil {
+				config.Confirm = func(n int) bool {
+					asked = n
+					return tt.confirm(n)
+				}
+			}
+
+			opened, err := OpenURLs(context.Background(), urls, config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OpenURLs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(opener.opened, tt.want) {
+				t.Errorf("opened %v, want %v", opener.opened, tt.want)
+			}
+			if opened != len(tt.want) {
+				t.Errorf("OpenURLs() = %d, want %d", opened, len(tt.want))
+			}
+			if asked != tt.wantAsked {
+				t.Errorf("Confirm asked about %d URLs, want %d", asked, tt.wantAsked)
+			}
+		})
+	}
+}
+
+func TestOpenURLsInterval(t *testing.T) {
+	urls := []string{"https://a.example", "https://b.example", "https://c.example"}
+	interval := 30 * time.Millisecond
+
+	opener := &fakeOpener{}
+	start := time.Now()
+	opened, err := OpenURLs(context.Background(), urls, BrowseConfig{Opener: opener, Interval: interval})
+	if err != nil || opened != 3 {
+		t.Fatalf("OpenURLs() = %d, %v", opened, err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("opening 3 URLs took %v, want at least %v", elapsed, 2*interval)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	opener = &fakeOpener{}
+	config := BrowseConfig{Opener: opener, Interval: time.Hour}
+	time.AfterFunc(10*time.Millisecond, cancel)
+	opened, err = OpenURLs(ctx, urls, config)
+	if err != context.Canceled || opened != 1 {
+		t.Errorf("OpenURLs() after cancel = %d, %v, want 1, %v", opened, err, context.Canceled)
+	}
+}
+
+func TestBrowseTargets(t *testing.T) {
+	results := []core.SiteResult{
+		{SiteName: "GitHub", Category: "coding", ResultStatus: core.ResultStatusFound,
+			ResultURL: "https://github.com/alice", ResponsePath: "/tmp/responses/alice/GitHub.html"},
+		{SiteName: "GitHub", Category: "coding", ResultStatus: core.ResultStatusFound,
+			ResultURL: "https://github.com/alice"},
+		{SiteName: "Mastodon", Category: "Social", ResultStatus: core.ResultStatusFound,
+			ResultURL: "https://mastodon.social/@alice", ResponsePath: "/tmp/responses/alice/Mastodon.html"},
+		{SiteName: "Reddit", Category: "social", ResultStatus: core.ResultStatusNotFound,
+			ResultURL: "https://reddit.com/user/alice", ResponsePath: "/tmp/responses/alice/Reddit.html"},
+		{SiteName: "Steam", Category: "gaming", ResultStatus: core.ResultStatusFound, FalsePositive: true,
+			ResultURL: "https://steamcommunity.com/id/alice", ResponsePath: "/tmp/responses/alice/Steam.html"},
+		{SiteName: "Vague", Category: "social", ResultStatus: core.ResultStatusAmbiguous,
+			ResultURL: "https://vague.example/alice"},
+		{SiteName: "NoURL", Category: "misc", ResultStatus: core.ResultStatusFound},
+	}
+
+	tests := []struct {
+		name          string
+		categories    []string
+		wantURLs      []string
+		wantResponses []string
+	}{
+		{
+			name:          "all categories",
+			wantURLs:      []string{"https://github.com/alice", "https://mastodon.social/@alice"},
+			wantResponses: []string{"file:///tmp/responses/alice/GitHub.html", "file:///tmp/responses/alice/Mastodon.html"},
+		},
+		{
+			name:          "category match ignores case",
+			categories:    []string{"SOCIAL"},
+			wantURLs:      []string{"https://mastodon.social/@alice"},
+			wantResponses: []string{"file:///tmp/responses/alice/Mastodon.html"},
+		},
+		{
+			name:       "no match",
+			categories: []string{"dating"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BrowseTargets(results, tt.categories); !reflect.DeepEqual(got, tt.wantURLs) {
+				t.Errorf("BrowseTargets() = %v, want %v", got, tt.wantURLs)
+			}
+			if got := ResponseTargets(results, tt.categories); !reflect.DeepEqual(got, tt.wantResponses) {
+				t.Errorf("ResponseTargets() = %v, want %v", got, tt.wantResponses)
+			}
+		})
+	}
+}
+
+func TestResponseTargetsRelativePath(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	got := ResponseTargets([]core.SiteResult{{
+		SiteName: "GitHub", ResultStatus: core.ResultStatusFound, ResponsePath: filepath.Join("responses", "GitHub.html"),
+	}}, nil)
+	want := "file://" + filepath.ToSlash(filepath.Join(dir, "responses", "GitHub.html"))
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("ResponseTargets() = %v, want [%s]", got, want)
+	}
+}
+
+func TestBrowserCommands(t *testing.T) {
+	const target = "https://github.com/alice"
+	sep := string(os.PathListSeparator)
+
+	tests := []struct {
+		name     string
+		browsers string
+		want     [][]string
+	}{
+		{name: "empty", browsers: ""},
+		{name: "only separators", browsers: sep + " " + sep},
+		{name: "URL appended", browsers: "firefox", want: [][]string{{"firefox", target}}},
+		{name: "arguments kept", browsers: "firefox --new-tab", want: [][]string{{"firefox", "--new-tab", target}}},
+		{name: "%s substituted", browsers: "lynx %s -dump", want: [][]string{{"lynx", target, "-dump"}}},
+		{name: "%s inside an argument", browsers: "open --url=%s", want: [][]string{{"open", "--url=" + target}}},
+		{name: "%s twice", browsers: "w3m %s %s", want: [][]string{{"w3m", target, target}}},
+		{
+			name:     "several commands",
+			browsers: "chromium %s" + sep + sep + "firefox",
+			want:     [][]string{{"chromium", target}, {"firefox", target}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := browserCommands(tt.browsers, target); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("browserCommands(%q) = %q, want %q", tt.browsers, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenWithBrowserEnvErrors(t *testing.T) {
+	const target = "https://github.com/alice"
+	sep := string(os.PathListSeparator)
+
+	err := openWithBrowserEnv(sep, target)
+	if err == nil || !strings.Contains(err.Error(), "$BROWSER is empty") {
+		t.Errorf("openWithBrowserEnv(%q) error = %v", sep, err)
+	}
+
+	missing := filepath.Join(t.TempDir(), "no-such-browser")
+	err = openWithBrowserEnv(missing+" %s"+sep+missing+"-too", target)
+	if err == nil {
+		t.Fatal("openWithBrowserEnv() with missing commands succeeded")
+	}
+	if got := strings.Count(err.Error(), "failed to open "+target); got != 2 {
+		t.Errorf("openWithBrowserEnv() error = %v, want one failure per command", err)
+	}
+}
+
+func TestPromptConfirm(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{" yes \n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+		{"yep\n", false},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if got := PromptConfirm(strings.NewReader(tt.answer), &out, "Open all?"); got != tt.want {
+			t.Errorf("PromptConfirm(%q) = %v, want %v", tt.answer, got, tt.want)
+		}
+		if out.String() != "Open all? [y/N] " {
+			t.Errorf("PromptConfirm() wrote %q", out.String())
+		}
+	}
+}
@@ -0,0 +1,661 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+// responseSnippetBytes caps how much of a response body the detail pane
+// shows.
+const responseSnippetBytes = 4096
+
+type BrowserConfig struct {
+	Results   []core.SiteResult
+	Usernames []string
+	Renderer  *Renderer
+	Opener    Opener
+
+	// ShowAll lists every result from the start instead of only found and
+	// ambiguous ones.
+	ShowAll bool
+}
+
+type sortKey int
+
+const (
+	sortBySite sortKey = iota
+	sortByCategory
+	sortByStatus
+	sortByCode
+	sortByElapsed
+	sortKeyCount
+)
+
+func (k sortKey) String() string {
+	return [...]string{"site", "category", "status", "http", "time"}[k]
+}
+
+// BrowserModel is the full-screen results browser: a table of results
+// grouped by username above a detail pane for the selected row.
+type BrowserModel struct {
+	config  BrowserConfig
+	r       *Renderer
+	results []core.SiteResult
+
+	// visible holds indexes into results in display order.
+	visible []int
+
+	table  table.Model
+	filter textinput.Model
+	detail viewport.Model
+
+	sortKey     sortKey
+	reverse     bool
+	showAll     bool
+	focusDetail bool
+	changed     bool
+	message     string
+
+	width  int
+	height int
+}
+
+func NewBrowserModel(config BrowserConfig) BrowserModel {
+	results := make([]core.SiteResult, len(config.Results))
+	copy(results, config.Results)
+
+	r := config.Renderer
+	if r == nil {
+		r = NewRenderer(os.Stdout, false)
+	}
+	if config.Opener == nil {
+		config.Opener = SystemOpener{}
+	}
+
+	t := table.New(table.WithFocused(true), table.WithStyles(table.Styles{
+		Header: r.bold.Padding(0, 1).
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderForeground(lipgloss.Color("240")).
+			BorderBottom(true),
+		Cell:     r.NewStyle().Padding(0, 1),
+		Selected: r.bold.Foreground(lipgloss.Color("212")),
+	}))
+
+	f := textinput.New()
+	f.Prompt = "/"
+	f.Placeholder = "filter by site, category, status or URL"
+	f.PromptStyle = r.NewStyle()
+	f.TextStyle = r.NewStyle()
+	f.PlaceholderStyle = r.subtle
+
+	m := BrowserModel{
+		config:  config,
+		r:       r,
+		results: results,
+		table:   t,
+		filter:  f,
+		detail:  viewport.New(0, 0),
+		showAll: config.ShowAll,
+		width:   80,
+		height:  24,
+	}
+	// The table needs its columns before it gets rows; the real size
+	// arrives with the first WindowSizeMsg.
+	m.layout()
+	m.refresh()
+	return m
+}
+
+// RunBrowser shows the results browser until the user quits and returns
+// the results with the false-positive marks made during the session.
+func RunBrowser(ctx context.Context, config BrowserConfig) ([]core.SiteResult, bool, error) {
+	p := tea.NewProgram(NewBrowserModel(config), tea.WithAltScreen(), tea.WithContext(ctx))
+	final, err := p.Run()
+	if m, ok := final.(BrowserModel); ok {
+		return m.results, m.changed, err
+	}
+	return config.Results, false, err
+}
+
+func (m BrowserModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m BrowserModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.layout()
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.filter.Focused() {
+			return m.updateFilter(msg)
+		}
+
+		m.message = ""
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "/":
+			m.focusDetail = false
+			m.table.Focus()
+			return m, m.filter.Focus()
+		case "esc":
+			if m.filter.Value() != "" {
+				m.filter.SetValue("")
+				m.refresh()
+			}
+			return m, nil
+		case "tab":
+			m.focusDetail = !m.focusDetail
+			if m.focusDetail {
+				m.table.Blur()
+			} else {
+				m.table.Focus()
+			}
+			return m, nil
+		case "s":
+			m.sortKey = (m.sortKey + 1) % sortKeyCount
+			m.refresh()
+			return m, nil
+		case "S":
+			m.reverse = !m.reverse
+			m.refresh()
+			return m, nil
+		case "a":
+			m.showAll = !m.showAll
+			m.refresh()
+			return m, nil
+		case "o":
+			m.openSelected()
+			return m, nil
+		case "x":
+			m.toggleFalsePositive()
+			return m, nil
+		case "e":
+			m.exportSelection()
+			return m, nil
+		}
+
+		var cmd tea.Cmd
+		if m.focusDetail {
+			m.detail, cmd = m.detail.Update(msg)
+			return m, cmd
+		}
+		cursor := m.table.Cursor()
+		m.table, cmd = m.table.Update(msg)
+		if m.table.Cursor() != cursor {
+			m.renderDetail()
+		}
+		return m, cmd
+	}
+
+	return m, nil
+}
+
+func (m BrowserModel) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		m.filter.Blur()
+		return m, nil
+	case "esc":
+		m.filter.Blur()
+		m.filter.SetValue("")
+		m.refresh()
+		return m, nil
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+
+	var cmd tea.Cmd
+	m.filter, cmd = m.filter.Update(msg)
+	m.refresh()
+	return m, cmd
+}
+
+func (m BrowserModel) selected() (*core.SiteResult, bool) {
+	cursor := m.table.Cursor()
+	if cursor < 0 || cursor >= len(m.visible) {
+		return nil, false
+	}
+	return &m.results[m.visible[cursor]], true
+}
+
+func (m *BrowserModel) openSelected() {
+	result, ok := m.selected()
+	if !ok {
+		return
+	}
+	if result.ResultURL == "" {
+		m.message = "No URL for this result"
+		return
+	}
+	if err := m.config.Opener.Open(result.ResultURL); err != nil {
+		m.message = m.r.failure.Render(err.Error())
+		return
+	}
+	m.message = "Opened " + result.ResultURL
+}
+
+func (m *BrowserModel) toggleFalsePositive() {
+	result, ok := m.selected()
+	if !ok {
+		return
+	}
+	result.FalsePositive = !result.FalsePositive
+	m.changed = true
+	if result.FalsePositive {
+		m.message = fmt.Sprintf("Marked %s as a false positive", result.SiteName)
+	} else {
+		m.message = fmt.Sprintf("Unmarked %s", result.SiteName)
+	}
+	m.refreshRows()
+	m.renderDetail()
+}
+
+// exportSelection writes the rows currently listed, minus the ones marked
+// as false positives, to a JSON file in the working directory.
+func (m *BrowserModel) exportSelection() {
+	var selection []core.SiteResult
+	var usernames []string
+	seen := make(map[string]bool)
+	skipped := 0
+	for _, i := range m.visible {
+		result := m.results[i]
+		if result.FalsePositive {
+			skipped++
+			continue
+		}
+		selection = append(selection, result)
+		if !seen[result.Username] {
+			seen[result.Username] = true
+			usernames = append(usernames, result.Username)
+		}
+	}
+	if len(selection) == 0 {
+		m.message = "Nothing to export"
+		return
+	}
+
+	exporter := NewExporter(selection, usernames)
+	path := fmt.Sprintf("usrsx_selection_%s.json", exporter.Timestamp.Format("20060102_150405"))
+	file, err := os.Create(path)
+	if err != nil {
+		m.message = m.r.failure.Render(fmt.Sprintf("failed to create JSON file: %v", err))
+		return
+	}
+	defer file.Close()
+	if err := exporter.WriteJSON(file); err != nil {
+		m.message = m.r.failure.Render(err.Error())
+		return
+	}
+
+	m.message = fmt.Sprintf("Exported %d result(s) to %s", len(selection), path)
+	if skipped > 0 {
+		m.message += fmt.Sprintf(" (%d false positive(s) left out)", skipped)
+	}
+}
+
+// refresh recomputes which results are listed and in what order, keeping
+// the selected result under the cursor when it is still listed.
+func (m *BrowserModel) refresh() {
+	current := -1
+	if cursor := m.table.Cursor(); cursor >= 0 && cursor < len(m.visible) {
+		current = m.visible[cursor]
+	}
+
+	query := strings.ToLower(strings.TrimSpace(m.filter.Value()))
+	m.visible = make([]int, 0, len(m.results))
+	for i, result := range m.results {
+		if !m.showAll && !isHit(result) {
+			continue
+		}
+		if query != "" && !matchesQuery(result, query) {
+			continue
+		}
+		m.visible = append(m.visible, i)
+	}
+
+	group := make(map[string]int, len(m.config.Usernames))
+	for i, username := range m.config.Usernames {
+		group[username] = i
+	}
+	sort.SliceStable(m.visible, func(a, b int) bool {
+		ra, rb := &m.results[m.visible[a]], &m.results[m.visible[b]]
+		if ra.Username != rb.Username {
+			ga, okA := group[ra.Username]
+			gb, okB := group[rb.Username]
+			switch {
+			case okA && okB:
+				return ga < gb
+			case okA != okB:
+				return okA
+			}
+			return ra.Username < rb.Username
+		}
+		c := m.compare(ra, rb)
+		if m.reverse {
+			c = -c
+		}
+		if c == 0 {
+			return ra.SiteName < rb.SiteName
+		}
+		return c < 0
+	})
+
+	m.refreshRows()
+
+	cursor := 0
+	for i, index := range m.visible {
+		if index == current {
+			cursor = i
+			break
+		}
+	}
+	m.table.SetCursor(cursor)
+	m.renderDetail()
+}
+
+func (m *BrowserModel) compare(a, b *core.SiteResult) int {
+	switch m.sortKey {
+	case sortByCategory:
+		return cmp.Compare(strings.ToLower(a.Category), strings.ToLower(b.Category))
+	case sortByStatus:
+		return cmp.Compare(statusRank(a.ResultStatus), statusRank(b.ResultStatus))
+	case sortByCode:
+		return cmp.Compare(a.ResponseCode, b.ResponseCode)
+	case sortByElapsed:
+		return cmp.Compare(a.Elapsed, b.Elapsed)
+	default:
+		return cmp.Compare(strings.ToLower(a.SiteName), strings.ToLower(b.SiteName))
+	}
+}
+
+// refreshRows rebuilds the table rows from visible. The username is only
+// shown on the first row of each group.
+func (m *BrowserModel) refreshRows() {
+	rows := make([]table.Row, 0, len(m.visible))
+	previous := ""
+	for i, index := range m.visible {
+		result := m.results[index]
+		username := result.Username
+		if i > 0 && username == previous {
+			username = ""
+		}
+		previous = result.Username
+
+		code := ""
+		if result.ResponseCode > 0 {
+			code = fmt.Sprintf("%d", result.ResponseCode)
+		}
+		elapsed := ""
+		if result.Elapsed > 0 {
+			elapsed = fmt.Sprintf("%.2fs", result.Elapsed)
+		}
+
+		rows = append(rows, table.Row{
+			username,
+			result.SiteName,
+			result.Category,
+			statusLabel(result),
+			code,
+			elapsed,
+			result.ResultURL,
+		})
+	}
+	m.table.SetRows(rows)
+}
+
+func (m *BrowserModel) layout() {
+	const (
+		usernameWidth = 16
+		siteWidth     = 22
+		categoryWidth = 12
+		statusWidth   = 16
+		codeWidth     = 4
+		elapsedWidth  = 7
+	)
+
+	// Every column carries one cell of padding on each side.
+	urlWidth := m.width - usernameWidth - siteWidth - categoryWidth - statusWidth - codeWidth - elapsedWidth - 7*2
+	urlWidth = max(urlWidth, 10)
+
+	m.table.SetColumns([]table.Column{
+		{Title: "Username", Width: usernameWidth},
+		{Title: "Site", Width: siteWidth},
+		{Title: "Category", Width: categoryWidth},
+		{Title: "Status", Width: statusWidth},
+		{Title: "HTTP", Width: codeWidth},
+		{Title: "Time", Width: elapsedWidth},
+		{Title: "URL", Width: urlWidth},
+	})
+	m.table.SetWidth(m.width)
+
+	// Title, separator, filter/status line and help line.
+	body := max(m.height-4, 4)
+	tableHeight := max(body*55/100, 3)
+	m.table.SetHeight(tableHeight)
+
+	m.detail.Width = m.width
+	m.detail.Height = max(body-tableHeight, 1)
+	m.renderDetail()
+}
+
+func (m *BrowserModel) renderDetail() {
+	result, ok := m.selected()
+	if !ok {
+		m.detail.SetContent(m.r.subtle.Render("No results match the current filter."))
+		return
+	}
+	m.detail.SetContent(m.r.formatResultDetail(*result, m.detail.Width))
+	m.detail.GotoTop()
+}
+
+func (m BrowserModel) View() string {
+	var b strings.Builder
+
+	view := "hits"
+	if m.showAll {
+		view = "all"
+	}
+	direction := "↑"
+	if m.reverse {
+		direction = "↓"
+	}
+	b.WriteString(m.r.header.Render(fmt.Sprintf("usrsx results  %d/%d shown  view: %s  sort: %s %s",
+		len(m.visible), len(m.results), view, m.sortKey, direction)))
+	b.WriteString("\n")
+
+	b.WriteString(m.table.View())
+	b.WriteString("\n")
+
+	separator := strings.Repeat("─", m.width)
+	if m.focusDetail {
+		b.WriteString(m.r.info.Render(separator))
+	} else {
+		b.WriteString(m.r.subtle.Render(separator))
+	}
+	b.WriteString("\n")
+	b.WriteString(m.detail.View())
+	b.WriteString("\n")
+
+	switch {
+	case m.filter.Focused() || m.filter.Value() != "":
+		b.WriteString(m.filter.View())
+	case m.message != "":
+		b.WriteString(m.message)
+	}
+	b.WriteString("\n")
+
+	b.WriteString(m.r.subtle.Render("/ filter  s sort  S reverse  a all/hits  o open  x false positive  e export  tab detail  q quit"))
+
+	return b.String()
+}
+
+// formatResultDetail renders everything known about result for the detail
+// pane, wrapped to width.
+func (r *Renderer) formatResultDetail(result core.SiteResult, width int) string {
+	var lines []string
+	field := func(label, value string) {
+		if value != "" {
+			lines = append(lines, r.label.Render(label+": ")+value)
+		}
+	}
+
+	lines = append(lines, r.bold.Render(result.SiteName)+" | "+result.Username+" | "+statusLabel(result))
+	if result.FalsePositive {
+		lines = append(lines, r.warning.Render("Marked as a false positive"))
+	}
+	field("Category", result.Category)
+	if result.ResultURL != "" {
+		field("URL", r.info.Render(result.ResultURL))
+	}
+	if result.ResponseCode > 0 {
+		field("HTTP", fmt.Sprintf("%d", result.ResponseCode))
+	}
+	if result.Elapsed > 0 {
+		field("Elapsed", fmt.Sprintf("%.2fs", result.Elapsed))
+	}
+	if result.Attempts > 1 {
+		field("Attempts", fmt.Sprintf("%d", result.Attempts))
+	}
+	field("Proxy", result.Proxy)
+	if result.Error != "" {
+		field("Error", r.failure.Render(result.Error))
+	}
+	if !result.CreatedAt.IsZero() {
+		field("Checked", result.CreatedAt.Format(time.RFC3339))
+	}
+
+	if result.Metadata != nil {
+		if metadata := r.FormatMetadata(result.Metadata); metadata != "" {
+			lines = append(lines, "", r.header.Render("Profile"), metadata)
+		}
+	}
+
+	if snippet := responseSnippet(result.ResponseText); snippet != "" {
+		lines = append(lines, "", r.header.Render(fmt.Sprintf("Response (%d bytes)", len(result.ResponseText))))
+		lines = append(lines, r.subtle.Width(width).Render(snippet))
+	}
+
+	return r.NewStyle().Width(width).Render(strings.Join(lines, "\n"))
+}
+
+// responseSnippet returns the start of a response body with control
+// characters removed and runs of blank lines collapsed, so raw HTML can be
+// shown in the terminal safely.
+func responseSnippet(body string) string {
+	truncated := false
+	if len(body) > responseSnippetBytes {
+		body = body[:responseSnippetBytes]
+		for !utf8.ValidString(body) && len(body) > 0 {
+			body = body[:len(body)-1]
+		}
+		truncated = true
+	}
+
+	var b strings.Builder
+	blank := 0
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.Map(func(r rune) rune {
+			switch {
+			case r == '\t':
+				return ' '
+			case r == utf8.RuneError, unicode.IsControl(r):
+				return -1
+			}
+			return r
+		}, line)
+		line = strings.TrimRight(line, " ")
+		if line == "" {
+			blank++
+			if blank > 1 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	snippet := strings.Trim(b.String(), "\n")
+	if truncated && snippet != "" {
+		snippet += "\n…"
+	}
+	return snippet
+}
+
+func isHit(result core.SiteResult) bool {
+	return result.ResultStatus == core.ResultStatusFound || result.ResultStatus == core.ResultStatusAmbiguous
+}
+
+func matchesQuery(result core.SiteResult, query string) bool {
+	for _, field := range []string{result.SiteName, result.Category, result.Username, string(result.ResultStatus), result.ResultURL} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// statusRank orders statuses from most to least interesting.
+func statusRank(status core.ResultStatus) int {
+	switch status {
+	case core.ResultStatusFound:
+		return 0
+	case core.ResultStatusAmbiguous:
+		return 1
+	case core.ResultStatusUnknown:
+		return 2
+	case core.ResultStatusError:
+		return 3
+	case core.ResultStatusNotValid:
+		return 4
+	case core.ResultStatusCancelled:
+		return 5
+	default:
+		return 6
+	}
+}
+
+func statusLabel(result core.SiteResult) string {
+	var icon string
+	switch result.ResultStatus {
+	case core.ResultStatusFound:
+		icon = "✓"
+	case core.ResultStatusNotFound:
+		icon = "✗"
+	case core.ResultStatusError:
+		icon = "!"
+	case core.ResultStatusAmbiguous:
+		icon = "~"
+	case core.ResultStatusUnknown:
+		icon = "?"
+	case core.ResultStatusCancelled:
+		icon = "-"
+	default:
+		icon = " "
+	}
+
+	label := icon + " " + string(result.ResultStatus)
+	if result.FalsePositive {
+		label += " [fp]"
+	}
+	return label
+}
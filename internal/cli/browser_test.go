@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+// fakeOpener records the URLs it is asked to open instead of starting a
+// browser. URLs in fail are refused.
+type fakeOpener struct {
+	opened []string
+	fail   map[string]bool
+}
+
+func (o *fakeOpener) Open(url string) error {
+	if o.fail[url] {
+		return errors.New("failed to open " + url)
+	}
+	o.opened = append(o.opened, url)
+	return nil
+}
+
+func browserResults() []core.SiteResult {
+	return []core.SiteResult{
+		{SiteName: "Reddit", Category: "social", Username: "alice", ResultStatus: core.ResultStatusNotFound, ResponseCode: 404},
+		{SiteName: "Mastodon", Category: "art", Username: "alice", ResultStatus: core.ResultStatusFound,
+			ResultURL: "https://mastodon.social/@alice", ResponseCode: 200},
+		{SiteName: "Steam", Category: "gaming", Username: "bob", ResultStatus: core.ResultStatusAmbiguous,
+			ResultURL: "https://steamcommunity.com/id/bob", ResponseCode: 200},
+		{SiteName: "GitHub", Category: "coding", Username: "alice", ResultStatus: core.ResultStatusFound,
+			ResultURL: "https://github.com/alice", ResponseCode: 200},
+	}
+}
+
+// press feeds keys to m one at a time. A key longer than one rune is a
+// named key such as "tab" or "enter".
+func press(t *testing.T, m BrowserModel, keys ...string) BrowserModel {
+	t.Helper()
+	named := map[string]tea.KeyType{"tab": tea.KeyTab, "enter": tea.KeyEnter, "esc": tea.KeyEsc, "down": tea.KeyDown}
+	for _, key := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		if typ, ok := named[key]; ok {
+			msg = tea.KeyMsg{Type: typ}
+		}
+		model, _ := m.Update(msg)
+		m = model.(BrowserModel)
+	}
+	return m
+}
+
+func visibleSites(m BrowserModel) []string {
+	var sites []string
+	for _, i := range m.visible {
+		sites = append(sites, m.results[i].SiteName)
+	}
+	return sites
+}
+
+func TestBrowserListing(t *testing.T) {
+	tests := []struct {
+		name    string
+		showAll bool
+		keys    []string
+		want    []string
+	}{
+		{name: "hits grouped by username", want: []string{"GitHub", "Mastodon", "Steam"}},
+		{name: "all results", keys: []string{"a"}, want: []string{"GitHub", "Mastodon", "Reddit", "Steam"}},
+		{name: "ShowAll", showAll: true, want: []string{"GitHub", "Mastodon", "Reddit", "Steam"}},
+		{name: "sort by category", keys: []string{"s"}, want: []string{"Mastodon", "GitHub", "Steam"}},
+		{name: "sort reversed", keys: []string{"S"}, want: []string{"Mastodon", "GitHub", "Steam"}},
+		{name: "sort by status", showAll: true, keys: []string{"s", "s"}, want: []string{"GitHub", "Mastodon", "Reddit", "Steam"}},
+		{name: "filter", keys: []string{"/", "m", "a", "s", "enter"}, want: []string{"Mastodon"}},
+		{name: "filter by category", keys: []string{"/", "g", "a", "m", "enter"}, want: []string{"Steam"}},
+		{name: "filter cleared", keys: []string{"/", "m", "a", "s", "enter", "esc"}, want: []string{"GitHub", "Mastodon", "Steam"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewBrowserModel(BrowserConfig{
+				Results:   browserResults(),
+				Usernames: []string{"alice", "bob"},
+				Renderer:  plainRenderer(t),
+				Opener:    &fakeOpener{},
+				ShowAll:   tt.showAll,
+			})
+			m = press(t, m, tt.keys...)
+			if got := visibleSites(m); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("listed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBrowserOpenAndMark(t *testing.T) {
+	opener := &fakeOpener{fail: map[string]bool{"https://mastodon.social/@alice": true}}
+	m := NewBrowserModel(BrowserConfig{
+		Results:   browserResults(),
+		Usernames: []string{"alice", "bob"},
+		Renderer:  plainRenderer(t),
+		Opener:    opener,
+	})
+
+	m = press(t, m, "o")
+	if want := []string{"https://github.com/alice"}; strings.Join(opener.opened, ",") != strings.Join(want, ",") {
+		t.Errorf("opened %v, want %v", opener.opened, want)
+	}
+	if m.message != "Opened https://github.com/alice" {
+		t.Errorf("message = %q", m.message)
+	}
+
+	m = press(t, m, "down", "o")
+	if len(opener.opened) != 1 || !strings.Contains(m.message, "failed to open https://mastodon.social/@alice") {
+		t.Errorf("opened %v, message %q, want the failure reported", opener.opened, m.message)
+	}
+
+	m = press(t, m, "x")
+	if !m.changed || !m.results[1].FalsePositive {
+		t.Fatalf("x did not mark Mastodon as a false positive")
+	}
+	if !strings.Contains(m.View(), "found [fp]") {
+		t.Errorf("view does not show the mark:\n%s", m.View())
+	}
+
+	m = press(t, m, "x")
+	if m.results[1].FalsePositive {
+		t.Errorf("second x did not unmark Mastodon")
+	}
+}
+
+func TestBrowserExportSelection(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	m := NewBrowserModel(BrowserConfig{
+		Results:   browserResults(),
+		Usernames: []string{"alice", "bob"},
+		Renderer:  plainRenderer(t),
+		Opener:    &fakeOpener{},
+	})
+	m = press(t, m, "x", "e")
+	if !strings.Contains(m.message, "Exported 2 result(s)") || !strings.Contains(m.message, "1 false positive(s) left out") {
+		t.Fatalf("message = %q", m.message)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "usrsx_selection_*.json"))
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("selection files = %v, %v", paths, err)
+	}
+	exporter, err := LoadJSONExport(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sites []string
+	for _, result := range exporter.Results {
+		sites = append(sites, result.SiteName)
+	}
+	if want := "Mastodon,Steam"; strings.Join(sites, ",") != want {
+		t.Errorf("exported %v, want %s", sites, want)
+	}
+	if want := "alice,bob"; strings.Join(exporter.Usernames, ",") != want {
+		t.Errorf("exported usernames %v, want %s", exporter.Usernames, want)
+	}
+}
+
+func TestResponseSnippet(t *testing.T) {
+	long := strings.Repeat("é", responseSnippetBytes)
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"empty", "", ""},
+		{"tabs and trailing spaces", "<p>\thi  \n", "<p> hi"},
+		{"control characters", "a\x1b[31mb\x00c\r", "a[31mbc"},
+		{"blank lines collapsed", "a\n\n\n\nb\n", "a\n\nb"},
+		{"truncated on a rune boundary", long, strings.Repeat("é", responseSnippetBytes/2) + "\n…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := responseSnippet(tt.body); got != tt.want {
+				t.Errorf("responseSnippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ListCache keeps the last copy of every remote site list on disk together
+// with the validators needed to revalidate it with a conditional request.
+type ListCache struct {
+	dir    string
+	maxAge time.Duration
+}
+
+type cacheMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+type cacheEntry struct {
+	meta cacheMeta
+	body []byte
+}
+
+// DefaultCacheDir returns usrsx's directory under the user's cache
+// directory ($XDG_CACHE_HOME or ~/.cache on Linux).
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "usrsx")
+}
+
+func NewListCache(dir string, maxAge time.Duration) *ListCache {
+	return &ListCache{dir: dir, maxAge: maxAge}
+}
+
+func (lc *ListCache) paths(url string) (body, meta string) {
+	sum := sha256.Sum256([]byte(url))
+	base := filepath.Join(lc.dir, "lists", hex.EncodeToString(sum[:8]))
+	return base + ".json", base + ".meta.json"
+}
+
+// load returns the cached copy of url, or nil if there is none.
+func (lc *ListCache) load(url string) *cacheEntry {
+	if lc.dir == "" {
+		return nil
+	}
+
+	bodyPath, metaPath := lc.paths(url)
+	metaData, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil
+	}
+	var meta cacheMeta
+	if err := json.Unmarshal(metaData, &meta); err != nil || meta.URL != url {
+		return nil
+	}
+	body, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return nil
+	}
+
+	return &cacheEntry{meta: meta, body: body}
+}
+
+func (lc *ListCache) fresh(entry *cacheEntry) bool {
+	return time.Since(entry.meta.FetchedAt) < lc.maxAge
+}
+
+// store writes entry to disk. The body is written before the metadata and
+// both go through a rename, so an interrupted write never leaves a
+// metadata file pointing at a truncated list.
+func (lc *ListCache) store(entry *cacheEntry) error {
+	if lc.dir == "" {
+		return nil
+	}
+
+	bodyPath, metaPath := lc.paths(entry.meta.URL)
+	if err := os.MkdirAll(filepath.Dir(bodyPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	metaData, err := json.MarshalIndent(entry.meta, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(bodyPath, entry.body); err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, metaData)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/client"
+)
+
+// listServer serves a site list with an ETag and a Last-Modified date and
+// answers conditional requests for the current version with 304.
+type listServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	version  int
+	status   int
+	requests []*http.Request
+}
+
+func newListServer(t *testing.T) *listServer {
+	t.Helper()
+	s := &listServer{version: 1}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, r)
+
+		if s.status != 0 {
+			w.WriteHeader(s.status)
+			return
+		}
+		etag := fmt.Sprintf(`"v%d"`, s.version)
+		modified := time.Date(2024, 1, s.version, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", modified)
+		fmt.Fprintf(w, `{"version": %d}`, s.version)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *listServer) lastRequest() (*http.Request, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return nil, 0
+	}
+	return s.requests[len(s.requests)-1], len(s.requests)
+}
+
+func newTestClient(t *testing.T) *client.HTTPClient {
+	t.Helper()
+	httpClient, err := client.NewHTTPClient(client.ClientConfig{Timeout: 5, Impersonate: client.BrowserNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httpClient
+}
+
+func TestListCacheStoreLoad(t *testing.T) {
+	cache := NewListCache(t.TempDir(), time.Hour)
+	entry := &cacheEntry{
+		meta: cacheMeta{URL: "https://example.com/wmn.json", ETag: `"abc"`, FetchedAt: time.Now()},
+		body: []byte(`{"sites": []}`),
+	}
+	if err := cache.store(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	got := cache.load(entry.meta.URL)
+	if got == nil || string(got.body) != string(entry.body) || got.meta.ETag != `"abc"` {
+		t.Fatalf("load = %+v, want the stored entry", got)
+	}
+	if !cache.fresh(got) {
+		t.Error("entry fetched just now is not fresh")
+	}
+	if cache.load("https://example.com/other.json") != nil {
+		t.Error("loaded an entry for a URL that was never stored")
+	}
+
+	if NewListCache("", time.Hour).load(entry.meta.URL) != nil {
+		t.Error("cache without a directory loaded an entry")
+	}
+}
+
+func TestLoadCachedRevalidates(t *testing.T) {
+	server := newListServer(t)
+	httpClient := newTestClient(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Every copy is stale, so each load revalidates.
+	cache := NewListCache(dir, 0)
+	body, err := loadCached(ctx, httpClient, cache, server.URL, false)
+	if err != nil || string(body) != `{"version": 1}` {
+		t.Fatalf("first load = %q, %v", body, err)
+	}
+	req, _ := server.lastRequest()
+	if req.Header.Get("If-None-Match") != "" {
+		t.Errorf("first request sent If-None-Match %q", req.Header.Get("If-None-Match"))
+	}
+	fetchedAt := cache.load(server.URL).meta.FetchedAt
+
+	body, err = loadCached(ctx, httpClient, cache, server.URL, false)
+	if err != nil || string(body) != `{"version": 1}` {
+		t.Fatalf("revalidated load = %q, %v", body, err)
+	}
+	req, _ = server.lastRequest()
+	if req.Header.Get("If-None-Match") != `"v1"` || req.Header.Get("If-Modified-Since") == "" {
+		t.Errorf("revalidation sent If-None-Match %q, If-Modified-Since %q",
+			req.Header.Get("If-None-Match"), req.Header.Get("If-Modified-Since"))
+	}
+	if !cache.load(server.URL).meta.FetchedAt.After(fetchedAt) {
+		t.Error("304 did not refresh the fetch time")
+	}
+
+	// A new version replaces the cached one.
+	server.mu.Lock()
+	server.version = 2
+	server.mu.Unlock()
+	body, err = loadCached(ctx, httpClient, cache, server.URL, false)
+	if err != nil || string(body) != `{"version": 2}` {
+		t.Fatalf("load after update = %q, %v", body, err)
+	}
+	if etag := cache.load(server.URL).meta.ETag; etag != `"v2"` {
+		t.Errorf("cached ETag = %q, want \"v2\"", etag)
+	}
+
+	// A fresh copy is used without a request.
+	_, before := server.lastRequest()
+	body, err = loadCached(ctx, httpClient, NewListCache(dir, time.Hour), server.URL, false)
+	if _, after := server.lastRequest(); err != nil || string(body) != `{"version": 2}` || after != before {
+		t.Errorf("fresh load = %q, %v after %d requests, want the cached copy and no request", body, err, after-before)
+	}
+}
+
+func TestLoadCachedFallback(t *testing.T) {
+	server := newListServer(t)
+	httpClient := newTestClient(t)
+	ctx := context.Background()
+	cache := NewListCache(t.TempDir(), 0)
+
+	if _, err := loadCached(ctx, httpClient, cache, server.URL, false); err != nil {
+		t.Fatal(err)
+	}
+
+	server.mu.Lock()
+	server.status = http.StatusInternalServerError
+	server.mu.Unlock()
+
+	body, err := loadCached(ctx, httpClient, cache, server.URL, false)
+	if err != nil || string(body) != `{"version": 1}` {
+		t.Errorf("got %q, %v; want the cached copy when the refresh fails", body, err)
+	}
+	if _, err := loadCached(ctx, httpClient, cache, server.URL+"/other", false); err == nil {
+		t.Error("no error for a failing list with no cached copy")
+	}
+}
+
+func TestLoadCachedOffline(t *testing.T) {
+	server := newListServer(t)
+	httpClient := newTestClient(t)
+	ctx := context.Background()
+	cache := NewListCache(t.TempDir(), 0)
+
+	if _, err := loadCached(ctx, httpClient, cache, server.URL, true); err == nil {
+		t.Fatal("offline load without a cached copy succeeded")
+	}
+	if _, n := server.lastRequest(); n != 0 {
+		t.Fatalf("offline load sent %d requests", n)
+	}
+
+	if _, err := loadCached(ctx, httpClient, cache, server.URL, false); err != nil {
+		t.Fatal(err)
+	}
+	body, err := loadCached(ctx, httpClient, cache, server.URL, true)
+	if _, n := server.lastRequest(); err != nil || string(body) != `{"version": 1}` || n != 1 {
+		t.Errorf("offline load = %q, %v after %d requests; want the stale copy and no request", body, err, n)
+	}
+}
@@ -1,12 +1,22 @@
 package cli
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gnomegl/usrsx/internal/client"
 	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/internal/schema"
 )
 
 type Config struct {
@@ -20,6 +30,12 @@ type Config struct {
 	LocalSchema  string
 	RemoteSchema string
 
+	StrictSchema bool
+
+	CacheDir    string
+	CacheMaxAge time.Duration
+	Offline     bool
+
 	SelfCheck bool
 
 	IncludeCategories []string
@@ -27,18 +43,37 @@ type Config struct {
 
 	Proxy         string
 	ProxyFile     string
+	ProxyStrategy string
 	Timeout       int
 	AllowRedirect bool
 	VerifySSL     bool
 	Impersonate   string
 
 	MaxTasks    int
+	PerHostRPS  float64
+	Deadline    time.Duration
 	FuzzyMode   bool
 	ShowDetails bool
 	Browse      bool
+	Interactive bool
+
+	BrowseLimit      int
+	BrowseCategories []string
+
+	RetryAttempts    int
+	RetryBackoff     time.Duration
+	RetryMaxDelay    time.Duration
+	RetryStatusCodes []int
+	RetryRotateProxy bool
+
+	ProxyCheckURL    string
+	ProxyMaxFailures int
+	ProxyCooldown    time.Duration
+	ProxyReportPath  string
 
 	SaveResponse bool
 	ResponsePath string
+	SaveStatuses []string
 	OpenResponse bool
 
 	CSVExport  bool
@@ -49,6 +84,38 @@ type Config struct {
 	JSONExport bool
 	JSONPath   string
 
+	// MarkdownPath and TextPath are file paths, or "-" for stdout.
+	MarkdownPath string
+	TextPath     string
+
+	Record      bool
+	HistoryPath string
+
+	// CompareTo is a JSON export to compare the scan with. DiffJSONPath and
+	// DiffMarkdownPath are file paths, or "-" for stdout.
+	CompareTo        string
+	DiffJSONPath     string
+	DiffMarkdownPath string
+
+	WatchInterval  time.Duration
+	WatchStatePath string
+	// WatchOutput is a file the watch command appends changes to, or "-"
+	// for stdout.
+	WatchOutput string
+	WebhookURL  string
+
+	// NotifyWebhooks are webhooks as [format=]url for found results and
+	// the end-of-run summary.
+	NotifyWebhooks    []string
+	NotifyTemplate    string
+	NotifyContentType string
+	NotifyBatchSize   int
+	NotifyMinInterval time.Duration
+
+	ServeListen  string
+	APITokenFile string
+	ServeMaxJobs int
+
 	FilterAll       bool
 	FilterErrors    bool
 	FilterNotFound  bool
@@ -56,8 +123,55 @@ type Config struct {
 	FilterAmbiguous bool
 }
 
-func LoadWMNData(config *Config) (*core.WMNData, error) {
+// Snapshot returns the configuration as JSON for the scan history, with
+// the password of --proxy and the paths of webhooks, which usually hold a
+// token, redacted.
+func (c Config) Snapshot() json.RawMessage {
+	if proxyURL, err := url.Parse(c.Proxy); err == nil && c.Proxy != "" {
+		c.Proxy = proxyURL.Redacted()
+	}
+	c.WebhookURL = redactWebhook(c.WebhookURL)
+	c.NotifyWebhooks = append([]string(nil), c.NotifyWebhooks...)
+	for i, webhook := range c.NotifyWebhooks {
+		c.NotifyWebhooks[i] = redactWebhook(webhook)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// redactWebhook keeps the format and host of a webhook and drops the rest.
+func redactWebhook(webhook string) string {
+	if webhook == "" {
+		return ""
+	}
+	start := strings.Index(webhook, "://")
+	if start < 0 {
+		return "xxxxx"
+	}
+	end := strings.IndexAny(webhook[start+3:], "/?#")
+	if end < 0 {
+		return webhook
+	}
+	return webhook[:start+3+end] + "/xxxxx"
+}
+
+// LoadWMNData merges the site lists named in config. Remote lists are
+// fetched through httpClient and cached on disk (see loadCached). Every list
+// is checked against the configured schema (see validateSites).
+func LoadWMNData(ctx context.Context, config *Config, httpClient *client.HTTPClient) (*core.WMNData, error) {
 	var wmnData core.WMNData
+	cache := NewListCache(config.CacheDir, config.CacheMaxAge)
+
+	validator, err := loadSchema(ctx, config, httpClient, cache)
+	if err != nil {
+		if config.StrictSchema {
+			return nil, core.NewSchemaValidationError("Failed to load schema", err)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: schema validation disabled: %v\n", err)
+	}
 
 	sources := append(config.RemoteLists, config.LocalLists...)
 	if len(sources) == 0 {
@@ -70,15 +184,7 @@ func LoadWMNData(config *Config) (*core.WMNData, error) {
 	var licenses []string
 
 	for _, source := range sources {
-		var data core.WMNData
-		var err error
-
-		if isURL(source) {
-			data, err = loadFromURL(source)
-		} else {
-			data, err = loadFromFile(source)
-		}
-
+		data, err := loadSource(ctx, httpClient, cache, source, config.Offline, validator, config.StrictSchema)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load WMN data from %s: %w", source, err)
 		}
@@ -93,6 +199,7 @@ func LoadWMNData(config *Config) (*core.WMNData, error) {
 			authorsSet[author] = true
 		}
 		licenses = append(licenses, data.License...)
+		wmnData.Lists = append(wmnData.Lists, data.Lists...)
 	}
 
 	for _, site := range allSites {
@@ -111,7 +218,7 @@ func LoadWMNData(config *Config) (*core.WMNData, error) {
 	}
 
 	if len(config.IncludeCategories) > 0 || len(config.ExcludeCategories) > 0 {
-		wmnData.Sites = filterSitesByCategory(wmnData.Sites, config.IncludeCategories, config.ExcludeCategories)
+		wmnData.Sites = FilterSitesByCategory(wmnData.Sites, config.IncludeCategories, config.ExcludeCategories)
 	}
 
 	return &wmnData, nil
@@ -121,37 +228,269 @@ func isURL(s string) bool {
 	return len(s) > 7 && (s[:7] == "http://" || s[:8] == "https://")
 }
 
-func loadFromURL(url string) (core.WMNData, error) {
-	resp, err := http.Get(url)
+func loadSource(ctx context.Context, httpClient *client.HTTPClient, cache *ListCache, source string, offline bool, validator *schema.Schema, strict bool) (core.WMNData, error) {
+	var body []byte
+	var err error
+	if isURL(source) {
+		body, err = loadCached(ctx, httpClient, cache, source, offline)
+	} else {
+		body, err = os.ReadFile(source)
+	}
 	if err != nil {
 		return core.WMNData{}, err
 	}
-	defer resp.Body.Close()
 
-	var data core.WMNData
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return core.WMNData{}, err
+	var invalid map[int]bool
+	if validator != nil {
+		invalid, err = validateSites(validator, source, body, strict)
+		if err != nil {
+			return core.WMNData{}, err
+		}
 	}
 
+	data, err := decodeWMNData(body, invalid)
+	if err != nil {
+		return core.WMNData{}, err
+	}
+	sum := sha256.Sum256(body)
+	data.Lists = []core.SiteListVersion{{Source: source, SHA256: hex.EncodeToString(sum[:]), Sites: len(data.Sites)}}
 	return data, nil
 }
 
-func loadFromFile(path string) (core.WMNData, error) {
-	file, err := os.Open(path)
+// loadSchema returns the schema from --local-schema, or from
+// --remote-schema through the list cache. It returns nil when neither is
+// set.
+func loadSchema(ctx context.Context, config *Config, httpClient *client.HTTPClient, cache *ListCache) (*schema.Schema, error) {
+	var body []byte
+	var err error
+	switch {
+	case config.LocalSchema != "":
+		body, err = os.ReadFile(config.LocalSchema)
+	case config.RemoteSchema != "":
+		body, err = loadCached(ctx, httpClient, cache, config.RemoteSchema, config.Offline)
+	default:
+		return nil, nil
+	}
 	if err != nil {
-		return core.WMNData{}, err
+		return nil, err
+	}
+	return schema.Parse(body)
+}
+
+// validateSites checks the list in body against validator and returns the
+// indexes of the sites that do not match. Violations are reported with the
+// name of the site they occur in and their JSON path. In strict mode any
+// violation is an error; otherwise every violation is printed as a warning
+// and the caller drops the offending sites.
+func validateSites(validator *schema.Schema, source string, body []byte, strict bool) (map[int]bool, error) {
+	doc, err := schema.Decode(body)
+	if err != nil {
+		return nil, err
+	}
+
+	violations := append(siteExtensions(doc), validator.Validate(doc)...)
+	if len(violations) == 0 {
+		return nil, nil
 	}
-	defer file.Close()
 
-	var data core.WMNData
-	if err := json.NewDecoder(file).Decode(&data); err != nil {
+	var sites []interface{}
+	if root, ok := doc.(map[string]interface{}); ok {
+		sites, _ = root["sites"].([]interface{})
+	}
+
+	invalid := make(map[int]bool)
+	problems := make([]error, 0, len(violations))
+	for _, violation := range violations {
+		label := "list"
+		if index, ok := siteIndex(violation.Path); ok && index < len(sites) {
+			invalid[index] = true
+			label = fmt.Sprintf("site #%d", index)
+			if site, ok := sites[index].(map[string]interface{}); ok {
+				if name, ok := site["name"].(string); ok && name != "" {
+					label = fmt.Sprintf("site %q", name)
+				}
+			}
+		}
+		problems = append(problems, fmt.Errorf("%s: %s", label, violation))
+	}
+
+	if strict {
+		return nil, core.NewSchemaValidationError(
+			fmt.Sprintf("Site list does not match the schema (%d violations)", len(violations)),
+			errors.Join(problems...),
+		)
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "Warning: schema: %v\n", problem)
+	}
+	if len(invalid) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: skipped %d site(s) from %s that do not match the schema\n", len(invalid), source)
+	}
+	return invalid, nil
+}
+
+// siteIndex extracts N from a violation path starting with $.sites[N].
+// siteExtensions checks the site keys usrsx adds to the WhatsMyName
+// format and removes them from doc, so that a schema that forbids unknown
+// keys does not reject the sites using them.
+func siteExtensions(doc interface{}) []schema.Violation {
+	root, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	sites, _ := root["sites"].([]interface{})
+
+	var violations []schema.Violation
+	for i, entry := range sites {
+		site, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, ok := site["rate_limit"]
+		if !ok {
+			continue
+		}
+		delete(site, "rate_limit")
+		if n, isNumber := value.(json.Number); !isNumber || !validRateLimit(n) {
+			violations = append(violations, schema.Violation{
+				Path:    fmt.Sprintf("$.sites[%d].rate_limit", i),
+				Message: "expected a non-negative number",
+			})
+		}
+	}
+	return violations
+}
+
+func validRateLimit(n json.Number) bool {
+	rate, err := n.Float64()
+	return err == nil && rate >= 0
+}
+
+func siteIndex(path string) (int, bool) {
+	rest, ok := strings.CutPrefix(path, "$.sites[")
+	if !ok {
+		return 0, false
+	}
+	end := strings.IndexByte(rest, ']')
+	if end < 0 {
+		return 0, false
+	}
+	index, err := strconv.Atoi(rest[:end])
+	return index, err == nil
+}
+
+// loadCached returns the document at url. A cached copy younger than the
+// cache's max age is used as is; an older one is revalidated with
+// If-None-Match/If-Modified-Since, and is still used if the refresh fails.
+// In offline mode the network is never touched.
+func loadCached(ctx context.Context, httpClient *client.HTTPClient, cache *ListCache, url string, offline bool) ([]byte, error) {
+	cached := cache.load(url)
+
+	if offline {
+		if cached == nil {
+			return nil, fmt.Errorf("offline mode and no cached copy in %s (run once without --offline)", cache.dir)
+		}
+		return cached.body, nil
+	}
+
+	if cached != nil && cache.fresh(cached) {
+		return cached.body, nil
+	}
+
+	body, err := fetchList(ctx, httpClient, cache, url, cached)
+	if err != nil {
+		if cached == nil || ctx.Err() != nil {
+			return nil, fmt.Errorf("%w (no cached copy available)", err)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: could not refresh %s (%v), using cached copy from %s\n",
+			url, err, cached.meta.FetchedAt.Format(time.RFC3339))
+		body = cached.body
+	}
+
+	return body, nil
+}
+
+func fetchList(ctx context.Context, httpClient *client.HTTPClient, cache *ListCache, url string, cached *cacheEntry) ([]byte, error) {
+	headers := map[string]string{}
+	if cached != nil {
+		if cached.meta.ETag != "" {
+			headers["If-None-Match"] = cached.meta.ETag
+		}
+		if cached.meta.LastModified != "" {
+			headers["If-Modified-Since"] = cached.meta.LastModified
+		}
+	}
+
+	resp, err := httpClient.Get(ctx, url, headers)
+	if err != nil {
+		return nil, err
+	}
+	body, err := client.ReadResponseBody(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry *cacheEntry
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		entry = cached
+		entry.meta.FetchedAt = time.Now()
+
+	case resp.StatusCode == http.StatusOK:
+		if !json.Valid([]byte(body)) {
+			return nil, fmt.Errorf("response is not valid JSON")
+		}
+		entry = &cacheEntry{
+			meta: cacheMeta{
+				URL:          url,
+				ETag:         resp.Header.Get("ETag"),
+				LastModified: resp.Header.Get("Last-Modified"),
+				FetchedAt:    time.Now(),
+			},
+			body: []byte(body),
+		}
+
+	default:
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	if err := cache.store(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to cache %s: %v\n", url, err)
+	}
+	return entry.body, nil
+}
+
+// decodeWMNData decodes a site list, leaving out the sites whose indexes
+// are in skip. Skipped entries are never decoded, so a site that failed
+// schema validation because of a wrong type does not fail the whole list.
+func decodeWMNData(body []byte, skip map[int]bool) (core.WMNData, error) {
+	var raw struct {
+		core.WMNData
+		Sites []json.RawMessage `json:"sites"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
 		return core.WMNData{}, err
 	}
 
+	data := raw.WMNData
+	data.Sites = make([]core.Site, 0, len(raw.Sites))
+	for i, entry := range raw.Sites {
+		if skip[i] {
+			continue
+		}
+		var site core.Site
+		if err := json.Unmarshal(entry, &site); err != nil {
+			return core.WMNData{}, fmt.Errorf("site #%d: %w", i, err)
+		}
+		data.Sites = append(data.Sites, site)
+	}
 	return data, nil
 }
 
-func filterSitesByCategory(sites []core.Site, include, exclude []string) []core.Site {
+// FilterSitesByCategory keeps the sites in include (all when empty) that
+// are not in exclude.
+func FilterSitesByCategory(sites []core.Site, include, exclude []string) []core.Site {
 	includeSet := make(map[string]bool)
 	for _, cat := range include {
 		includeSet[cat] = true
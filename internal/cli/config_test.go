@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gnomegl/usrsx/internal/schema"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["sites"],
+	"properties": {
+		"sites": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["name", "uri_check", "cat"],
+				"properties": {
+					"name": {"type": "string"},
+					"uri_check": {"type": "string"},
+					"cat": {"type": "string"},
+					"e_code": {"type": "integer"}
+				}
+			}
+		}
+	}
+}`
+
+// testList has a valid site, one whose e_code has the wrong type and would
+// fail to decode, one missing uri_check, and another valid site.
+const testList = `{
+	"categories": ["social"],
+	"sites": [
+		{"name": "first", "uri_check": "https://first.test/{account}", "cat": "social", "e_code": 200},
+		{"name": "wrong-type", "uri_check": "https://wrong.test/{account}", "cat": "social", "e_code": "200"},
+		{"name": "no-uri", "cat": "social"},
+		{"name": "last", "uri_check": "https://last.test/{account}", "cat": "social"}
+	]
+}`
+
+func TestValidateSites(t *testing.T) {
+	validator, err := schema.Parse([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid, err := validateSites(validator, "test.json", []byte(testList), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invalid) != 2 || !invalid[1] || !invalid[2] {
+		t.Errorf("invalid = %v, want sites 1 and 2", invalid)
+	}
+
+	if _, err := validateSites(validator, "test.json", []byte(testList), true); err == nil {
+		t.Error("strict validation accepted an invalid list")
+	}
+
+	invalid, err = validateSites(validator, "test.json", []byte(`{"sites": []}`), true)
+	if err != nil || invalid != nil {
+		t.Errorf("valid list: invalid = %v, err = %v", invalid, err)
+	}
+}
+
+func TestDecodeWMNDataSkipsInvalidSites(t *testing.T) {
+	data, err := decodeWMNData([]byte(testList), map[int]bool{1: true, 2: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Sites) != 2 || data.Sites[0].Name != "first" || data.Sites[1].Name != "last" {
+		t.Errorf("got sites %+v, want first and last", data.Sites)
+	}
+	if len(data.Categories) != 1 {
+		t.Errorf("categories = %v, want them kept", data.Categories)
+	}
+
+	// Without the skip set the badly typed site fails the whole list.
+	if _, err := decodeWMNData([]byte(testList), nil); err == nil {
+		t.Error("decoded a site whose e_code is a string")
+	}
+}
+
+func TestLoadSourceWithSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.json")
+	if err := os.WriteFile(path, []byte(testList), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	validator, err := schema.Parse([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := loadSource(t.Context(), nil, nil, path, false, validator, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Sites) != 2 || len(data.Lists) != 1 || data.Lists[0].Sites != 2 {
+		t.Errorf("got %d sites and lists %+v, want the 2 valid sites", len(data.Sites), data.Lists)
+	}
+}
+
+// rateLimitList uses usrsx's rate_limit extension, which the WhatsMyName
+// schema does not list.
+const rateLimitList = `{
+	"license": ["CC BY-SA 4.0"],
+	"authors": ["test"],
+	"categories": ["social"],
+	"sites": [
+		{"name": "slow", "uri_check": "https://slow.test/{account}", "e_code": 200, "e_string": "profile",
+		 "m_string": "missing", "m_code": 404, "known": ["alice"], "cat": "social", "rate_limit": 0.5},
+		{"name": "plain", "uri_check": "https://plain.test/{account}", "e_code": 200, "e_string": "profile",
+		 "m_string": "missing", "m_code": 404, "known": ["alice"], "cat": "social"},
+		{"name": "bad-rate", "uri_check": "https://bad.test/{account}", "e_code": 200, "e_string": "profile",
+		 "m_string": "missing", "m_code": 404, "known": ["alice"], "cat": "social", "rate_limit": "fast"}
+	]
+}`
+
+func TestLoadSourceRateLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.json")
+	if err := os.WriteFile(path, []byte(rateLimitList), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A copy of the WhatsMyName schema, which forbids unknown site keys.
+	body, err := os.ReadFile(filepath.Join("testdata", "wmn-data-schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator, err := schema.Parse(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := loadSource(t.Context(), nil, nil, path, false, validator, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Sites) != 2 || data.Sites[0].Name != "slow" || data.Sites[0].RateLimit != 0.5 {
+		t.Errorf("got sites %+v, want slow with its rate limit and plain", data.Sites)
+	}
+
+	if _, err := loadSource(t.Context(), nil, nil, path, false, validator, true); err == nil ||
+		!strings.Contains(err.Error(), "bad-rate") || strings.Contains(err.Error(), `"slow"`) {
+		t.Errorf("strict validation: %v, want only bad-rate rejected", err)
+	}
+}
+
+func TestSiteIndex(t *testing.T) {
+	tests := []struct {
+		path  string
+		index int
+		ok    bool
+	}{
+		{"$.sites[3].e_code", 3, true},
+		{"$.sites[12]", 12, true},
+		{"$.sites", 0, false},
+		{"$.categories[1]", 0, false},
+		{"$.sites[x]", 0, false},
+	}
+	for _, tt := range tests {
+		index, ok := siteIndex(tt.path)
+		if index != tt.index || ok != tt.ok {
+			t.Errorf("siteIndex(%q) = %d, %v; want %d, %v", tt.path, index, ok, tt.index, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/diff"
+)
+
+// diffValueWidth is how much of a changed profile field the terminal
+// shows. The exports keep the whole value.
+const diffValueWidth = 60
+
+// CompareExports compares two scans and records their timestamps and
+// usernames in the report.
+func CompareExports(old, current *Exporter) diff.Report {
+	report := diff.Compare(old.Results, current.Results)
+	report.Old = diff.Scan{Timestamp: old.Timestamp, Usernames: old.Usernames}
+	report.New = diff.Scan{Timestamp: current.Timestamp, Usernames: current.Usernames}
+	return report
+}
+
+// FormatDiff renders a comparison for the terminal, one line per change
+// followed by a summary.
+func (r *Renderer) FormatDiff(report diff.Report) string {
+	var b strings.Builder
+	b.WriteString(r.bold.Render("Changes") + r.subtle.Render(fmt.Sprintf(" %s -> %s",
+		report.Old.Timestamp.Format(time.RFC3339), report.New.Timestamp.Format(time.RFC3339))) + "\n\n")
+
+	for _, change := range report.Changes {
+		var tag string
+		switch change.Kind {
+		case diff.KindAppeared:
+			tag = r.success.Render("+ NEW    ")
+		case diff.KindDisappeared:
+			tag = r.failure.Render("- GONE   ")
+		case diff.KindStatusChanged:
+			tag = r.warning.Render("~ STATUS ")
+		case diff.KindMetadataChanged:
+			tag = r.info.Render("* PROFILE")
+		}
+
+		line := fmt.Sprintf("%s | %s | %s", tag, change.Username, change.SiteName)
+		switch change.Kind {
+		case diff.KindAppeared, diff.KindStatusChanged:
+			line += " | " + statusTransition(change)
+		}
+		if change.URL != "" && change.Kind != diff.KindStatusChanged {
+			line += " | " + r.info.Render(change.URL)
+		}
+		b.WriteString(line + "\n")
+
+		for _, field := range change.Fields {
+			field.Old, field.New = shorten(field.Old, diffValueWidth), shorten(field.New, diffValueWidth)
+			b.WriteString(r.label.Render("    "+field.Field+": ") + r.value.Render(fieldTransition(field)) + "\n")
+		}
+	}
+	if !report.Changed() {
+		b.WriteString("No changes.\n")
+	}
+
+	s := report.Summary
+	b.WriteString(fmt.Sprintf("\n%d appeared, %d disappeared, %d status changes, %d profile changes, %d unchanged",
+		s.Appeared, s.Disappeared, s.StatusChanged, s.MetadataChanged, s.Unchanged))
+	return b.String()
+}
+
+// ExportDiffJSON writes report as JSON to path, or to stdout when path is
+// empty.
+func ExportDiffJSON(path string, report diff.Report) error {
+	return exportTo(path, "JSON", func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+		return nil
+	})
+}
+
+// ExportDiffMarkdown writes report as Markdown to path, or to stdout when
+// path is empty.
+func ExportDiffMarkdown(path string, report diff.Report) error {
+	return exportTo(path, "Markdown", func(w io.Writer) error {
+		return WriteDiffMarkdown(w, report)
+	})
+}
+
+// WriteDiffMarkdown writes a comparison as Markdown: a summary, then a
+// table per kind of change.
+func WriteDiffMarkdown(w io.Writer, report diff.Report) error {
+	var b strings.Builder
+
+	b.WriteString("# usrsx Changes\n\n")
+	fmt.Fprintf(&b, "- **Old scan:** %s (%s)\n", report.Old.Timestamp.Format(time.RFC3339), markdownCell(strings.Join(report.Old.Usernames, ", ")))
+	fmt.Fprintf(&b, "- **New scan:** %s (%s)\n\n", report.New.Timestamp.Format(time.RFC3339), markdownCell(strings.Join(report.New.Usernames, ", ")))
+
+	s := report.Summary
+	b.WriteString("## Summary\n\n| Change | Count |\n|---|---:|\n")
+	fmt.Fprintf(&b, "| Newly found | %d |\n", s.Appeared)
+	fmt.Fprintf(&b, "| No longer found | %d |\n", s.Disappeared)
+	fmt.Fprintf(&b, "| Status changes | %d |\n", s.StatusChanged)
+	fmt.Fprintf(&b, "| Profile changes | %d |\n", s.MetadataChanged)
+	fmt.Fprintf(&b, "| Unchanged | %d |\n", s.Unchanged)
+
+	sections := []struct {
+		kind  diff.Kind
+		title string
+	}{
+		{diff.KindAppeared, "Newly found"},
+		{diff.KindDisappeared, "No longer found"},
+		{diff.KindStatusChanged, "Status changes"},
+		{diff.KindMetadataChanged, "Profile changes"},
+	}
+	for _, section := range sections {
+		var changes []diff.Change
+		for _, change := range report.Changes {
+			if change.Kind == section.kind {
+				changes = append(changes, change)
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\n## %s\n\n", section.title)
+		switch section.kind {
+		case diff.KindMetadataChanged:
+			b.WriteString("| Username | Site | Field | Change |\n|---|---|---|---|\n")
+			for _, change := range changes {
+				for _, field := range change.Fields {
+					fmt.Fprintf(&b, "| %s | %s | %s | %s |\n",
+						markdownCell(change.Username), markdownCell(change.SiteName),
+						markdownCell(field.Field), markdownCell(fieldTransition(field)))
+				}
+			}
+		default:
+			b.WriteString("| Username | Site | Category | Status | URL |\n|---|---|---|---|---|\n")
+			for _, change := range changes {
+				fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n",
+					markdownCell(change.Username), markdownCell(change.SiteName), markdownCell(change.Category),
+					markdownCell(statusTransition(change)), markdownURL(change.URL))
+			}
+		}
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write Markdown: %w", err)
+	}
+	return nil
+}
+
+func statusTransition(change diff.Change) string {
+	if change.OldStatus == "" {
+		return fmt.Sprintf("%s (not checked before)", change.NewStatus)
+	}
+	return fmt.Sprintf("%s -> %s", change.OldStatus, change.NewStatus)
+}
+
+func fieldTransition(field diff.FieldChange) string {
+	if field.Delta != nil {
+		return fmt.Sprintf("%s -> %s (%+d)", field.Old, field.New, *field.Delta)
+	}
+	return fmt.Sprintf("%q -> %q", field.Old, field.New)
+}
+
+// shorten cuts s to at most width runes, marking the cut with "...".
+func shorten(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-3]) + "..."
+}
@@ -4,9 +4,8 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
-	"html/template"
+	"io"
 	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/gnomegl/usrsx/internal/core"
@@ -27,21 +26,12 @@ func NewExporter(results []core.SiteResult, usernames []string) *Exporter {
 }
 
 func (e *Exporter) ExportCSV(path string) error {
-	var writer *csv.Writer
-	var file *os.File
-	var err error
+	return exportTo(path, "CSV", e.WriteCSV)
+}
 
-	if path == "" {
-		writer = csv.NewWriter(os.Stdout)
-	} else {
-		file, err = os.Create(path)
-		if err != nil {
-			return fmt.Errorf("failed to create CSV file: %w", err)
-		}
-		defer file.Close()
-		writer = csv.NewWriter(file)
-	}
-	defer writer.Flush()
+// WriteCSV writes one row per result to w.
+func (e *Exporter) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
 
 	header := []string{"Username", "Site", "Category", "Status", "URL", "Response Code", "Elapsed", "Error", "Timestamp"}
 	if err := writer.Write(header); err != nil {
@@ -65,239 +55,79 @@ func (e *Exporter) ExportCSV(path string) error {
 		}
 	}
 
-	if path != "" {
-		fmt.Printf("Exported to CSV: %s\n", path)
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
 	}
 	return nil
 }
 
 func (e *Exporter) ExportJSON(path string) error {
-	var encoder *json.Encoder
-	var file *os.File
-	var err error
-
-	data := map[string]interface{}{
-		"usernames": e.Usernames,
-		"timestamp": e.Timestamp.Format(time.RFC3339),
-		"results":   e.Results,
-		"summary": map[string]int{
-			"total":     len(e.Results),
-			"found":     e.countByStatus(core.ResultStatusFound),
-			"not_found": e.countByStatus(core.ResultStatusNotFound),
-			"errors":    e.countByStatus(core.ResultStatusError),
-			"unknown":   e.countByStatus(core.ResultStatusUnknown),
-			"ambiguous": e.countByStatus(core.ResultStatusAmbiguous),
-		},
-	}
-
-	if path == "" {
-		encoder = json.NewEncoder(os.Stdout)
-		encoder.SetIndent("", "  ")
-		if err := encoder.Encode(data); err != nil {
-			return fmt.Errorf("failed to encode JSON: %w", err)
-		}
-	} else {
-		file, err = os.Create(path)
-		if err != nil {
-			return fmt.Errorf("failed to create JSON file: %w", err)
-		}
-		defer file.Close()
-		encoder = json.NewEncoder(file)
-		encoder.SetIndent("", "  ")
-		if err := encoder.Encode(data); err != nil {
-			return fmt.Errorf("failed to encode JSON: %w", err)
-		}
-		fmt.Printf("Exported to JSON: %s\n", path)
-	}
-
-	return nil
+	return exportTo(path, "JSON", e.WriteJSON)
 }
 
-func (e *Exporter) ExportHTML(path string) error {
+// exportTo writes a report with write to path, or to stdout when path is
+// empty, and tells the user on stderr where a file went, so the notice
+// never ends up inside a report written to stdout.
+func exportTo(path, format string, write func(io.Writer) error) error {
 	if path == "" {
-		path = fmt.Sprintf("usrsx_results_%s.html", e.Timestamp.Format("20060102_150405"))
-	}
-
-	tmpl := `
-<html>
-<head>
-    <meta charset="UTF-8">
-    <title>usrsx Results - {{.Timestamp}}</title>
-    <style>
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, Cantarell, sans-serif;
-            max-width: 1200px;
-            margin: 0 auto;
-            padding: 20px;
-            background: #f5f5f5;
-        }
-        h1 { color: #333; }
-        .summary {
-            background: white;
-            padding: 20px;
-            border-radius: 8px;
-            margin-bottom: 20px;
-            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
-        }
-        .summary-item {
-            display: inline-block;
-            margin-right: 20px;
-            padding: 10px;
-        }
-        table {
-            width: 100%;
-            background: white;
-            border-collapse: collapse;
-            border-radius: 8px;
-            overflow: hidden;
-            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
-        }
-        th, td {
-            padding: 12px;
-            text-align: left;
-            border-bottom: 1px solid #ddd;
-        }
-        th {
-            background: #4CAF50;
-            color: white;
-            font-weight: 600;
-        }
-        tr:hover { background: #f5f5f5; }
-        .status-found { color: #4CAF50; font-weight: bold; }
-        .status-not-found { color: #999; }
-        .status-error { color: #f44336; }
-        .status-unknown { color: #ff9800; }
-        .status-ambiguous { color: #ff9800; }
-        a { color: #2196F3; text-decoration: none; }
-        a:hover { text-decoration: underline; }
-    </style>
-</head>
-<body>
-    <h1>usrsx Results</h1>
-    
-    <div class="summary">
-        <h2>Summary</h2>
-        <div class="summary-item"><strong>Usernames:</strong> {{.UsernamesStr}}</div>
-        <div class="summary-item"><strong>Timestamp:</strong> {{.Timestamp}}</div>
-        <div class="summary-item"><strong>Total:</strong> {{.Total}}</div>
-        <div class="summary-item"><strong>Found:</strong> <span class="status-found">{{.Found}}</span></div>
-        <div class="summary-item"><strong>Not Found:</strong> {{.NotFound}}</div>
-        <div class="summary-item"><strong>Errors:</strong> <span class="status-error">{{.Errors}}</span></div>
-    </div>
-
-    <table>
-        <thead>
-            <tr>
-                <th>Username</th>
-                <th>Site</th>
-                <th>Category</th>
-                <th>Status</th>
-                <th>URL</th>
-                <th>Response</th>
-                <th>Time</th>
-            </tr>
-        </thead>
-        <tbody>
-            {{range .Results}}
-            <tr>
-                <td>{{.Username}}</td>
-                <td>{{.SiteName}}</td>
-                <td>{{.Category}}</td>
-                <td class="status-{{.ResultStatus}}">{{.ResultStatus}}</td>
-                <td>{{if .ResultURL}}<a href="{{.ResultURL}}" target="_blank">{{.ResultURL}}</a>{{end}}</td>
-                <td>{{.ResponseCode}}</td>
-                <td>{{printf "%.2f" .Elapsed}}s</td>
-            </tr>
-            {{end}}
-        </tbody>
-    </table>
-</body>
-</html>`
-
-	t, err := template.New("report").Parse(tmpl)
-	if err != nil {
-		return fmt.Errorf("failed to parse HTML template: %w", err)
+		return write(os.Stdout)
 	}
 
 	file, err := os.Create(path)
 	if err != nil {
-		return fmt.Errorf("failed to create HTML file: %w", err)
+		return fmt.Errorf("failed to create %s file: %w", format, err)
 	}
 	defer file.Close()
-
-	data := struct {
-		Usernames    []string
-		UsernamesStr string
-		Timestamp    string
-		Total        int
-		Found        int
-		NotFound     int
-		Errors       int
-		Results      []core.SiteResult
-	}{
-		Usernames:    e.Usernames,
-		UsernamesStr: fmt.Sprintf("%v", e.Usernames),
-		Timestamp:    e.Timestamp.Format(time.RFC3339),
-		Total:        len(e.Results),
-		Found:        e.countByStatus(core.ResultStatusFound),
-		NotFound:     e.countByStatus(core.ResultStatusNotFound),
-		Errors:       e.countByStatus(core.ResultStatusError),
-		Results:      e.Results,
+	if err := write(file); err != nil {
+		return err
 	}
+	fmt.Fprintf(os.Stderr, "Exported to %s: %s\n", format, path)
+	return nil
+}
 
-	if err := t.Execute(file, data); err != nil {
-		return fmt.Errorf("failed to execute HTML template: %w", err)
+// WriteJSON writes the results in the --json export format to w.
+func (e *Exporter) WriteJSON(w io.Writer) error {
+	data := map[string]interface{}{
+		"usernames": e.Usernames,
+		"timestamp": e.Timestamp.Format(time.RFC3339),
+		"results":   e.Results,
+		"summary":   Summarize(e.Results),
 	}
 
-	fmt.Printf("Exported to HTML: %s\n", path)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
 	return nil
 }
 
-func (e *Exporter) ExportPDF(path string) error {
-	file, err := os.Create(path)
+// LoadJSONExport reads a file written by ExportJSON back into an Exporter,
+// keeping the original usernames and timestamp.
+func LoadJSONExport(path string) (*Exporter, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
 
-	fmt.Fprintf(file, "usrsx Results\n")
-	fmt.Fprintf(file, "================\n\n")
-	fmt.Fprintf(file, "Usernames: %v\n", e.Usernames)
-	fmt.Fprintf(file, "Timestamp: %s\n", e.Timestamp.Format(time.RFC3339))
-	fmt.Fprintf(file, "Total Results: %d\n\n", len(e.Results))
-
-	fmt.Fprintf(file, "Summary:\n")
-	fmt.Fprintf(file, "  Found: %d\n", e.countByStatus(core.ResultStatusFound))
-	fmt.Fprintf(file, "  Not Found: %d\n", e.countByStatus(core.ResultStatusNotFound))
-	fmt.Fprintf(file, "  Errors: %d\n", e.countByStatus(core.ResultStatusError))
-	fmt.Fprintf(file, "  Unknown: %d\n", e.countByStatus(core.ResultStatusUnknown))
-	fmt.Fprintf(file, "  Ambiguous: %d\n\n", e.countByStatus(core.ResultStatusAmbiguous))
-
-	fmt.Fprintf(file, "Detailed Results:\n")
-	fmt.Fprintf(file, "=================\n\n")
-
-	for _, result := range e.Results {
-		fmt.Fprintf(file, "Username: %s\n", result.Username)
-		fmt.Fprintf(file, "Site: %s (%s)\n", result.SiteName, result.Category)
-		fmt.Fprintf(file, "Status: %s\n", result.ResultStatus)
-		if result.ResultURL != "" {
-			fmt.Fprintf(file, "URL: %s\n", result.ResultURL)
-		}
-		if result.ResponseCode > 0 {
-			fmt.Fprintf(file, "Response Code: %d\n", result.ResponseCode)
-		}
-		if result.Elapsed > 0 {
-			fmt.Fprintf(file, "Elapsed: %.2fs\n", result.Elapsed)
-		}
-		if result.Error != "" {
-			fmt.Fprintf(file, "Error: %s\n", result.Error)
-		}
-		fmt.Fprintf(file, "\n")
+	var export struct {
+		Usernames []string          `json:"usernames"`
+		Timestamp string            `json:"timestamp"`
+		Results   []core.SiteResult `json:"results"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	if export.Results == nil {
+		return nil, fmt.Errorf("%s is not a usrsx JSON export (no results)", path)
 	}
 
-	absPath, _ := filepath.Abs(path)
-	fmt.Printf("Exported to text file (PDF placeholder): %s\n", absPath)
-	return nil
+	exporter := NewExporter(export.Results, export.Usernames)
+	if ts, err := time.Parse(time.RFC3339, export.Timestamp); err == nil {
+		exporter.Timestamp = ts
+	}
+	return exporter, nil
 }
 
 func (e *Exporter) countByStatus(status core.ResultStatus) int {
@@ -309,61 +139,3 @@ func (e *Exporter) countByStatus(status core.ResultStatus) int {
 	}
 	return count
 }
-
-func StreamJSON(result core.SiteResult) {
-	encoder := json.NewEncoder(os.Stdout)
-	data := map[string]interface{}{
-		"type":          "result",
-		"username":      result.Username,
-		"site_name":     result.SiteName,
-		"category":      result.Category,
-		"status":        result.ResultStatus,
-		"url":           result.ResultURL,
-		"response_code": result.ResponseCode,
-		"elapsed":       result.Elapsed,
-		"error":         result.Error,
-		"timestamp":     result.CreatedAt.Format(time.RFC3339),
-		"metadata":      result.Metadata,
-	}
-	encoder.Encode(data)
-}
-
-func StreamJSONSummary(results []core.SiteResult, usernames []string) {
-	encoder := json.NewEncoder(os.Stdout)
-
-	found := 0
-	notFound := 0
-	errors := 0
-	unknown := 0
-	ambiguous := 0
-
-	for _, r := range results {
-		switch r.ResultStatus {
-		case core.ResultStatusFound:
-			found++
-		case core.ResultStatusNotFound:
-			notFound++
-		case core.ResultStatusError:
-			errors++
-		case core.ResultStatusUnknown:
-			unknown++
-		case core.ResultStatusAmbiguous:
-			ambiguous++
-		}
-	}
-
-	data := map[string]interface{}{
-		"type":      "summary",
-		"usernames": usernames,
-		"timestamp": time.Now().Format(time.RFC3339),
-		"summary": map[string]int{
-			"total":     len(results),
-			"found":     found,
-			"not_found": notFound,
-			"errors":    errors,
-			"unknown":   unknown,
-			"ambiguous": ambiguous,
-		},
-	}
-	encoder.Encode(data)
-}
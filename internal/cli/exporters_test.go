@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns what fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	return capture(t, &os.Stdout, fn)
+}
+
+// captureStderr returns what fn writes to os.Stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	return capture(t, &os.Stderr, fn)
+}
+
+func capture(t *testing.T, f **os.File, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := *f
+	*f = w
+	defer func() { *f = saved }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func TestExportToKeepsStdoutClean(t *testing.T) {
+	exporter := reportExporter()
+	dir := t.TempDir()
+
+	// A Markdown report on stdout next to file exports of every kind.
+	out := captureStdout(t, func() {
+		if err := exporter.ExportMarkdown(""); err != nil {
+			t.Fatal(err)
+		}
+		if err := exporter.ExportCSV(filepath.Join(dir, "r.csv")); err != nil {
+			t.Fatal(err)
+		}
+		if err := exporter.ExportJSON(filepath.Join(dir, "r.json")); err != nil {
+			t.Fatal(err)
+		}
+		if err := exporter.ExportPDF(filepath.Join(dir, "r.pdf")); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if !strings.HasPrefix(out, "# usrsx Results") {
+		t.Errorf("stdout does not start with the Markdown report: %.40q", out)
+	}
+	if strings.Contains(out, "Exported") {
+		t.Error("export notices were written to stdout")
+	}
+}
+
+// TestExportNotices checks that every file export reports the path as
+// given and fails with the same wording.
+func TestExportNotices(t *testing.T) {
+	exporter := reportExporter()
+	t.Chdir(t.TempDir())
+
+	tests := []struct {
+		format string
+		export func(string) error
+	}{
+		{"CSV", exporter.ExportCSV},
+		{"JSON", exporter.ExportJSON},
+		{"HTML", exporter.ExportHTML},
+		{"PDF", exporter.ExportPDF},
+		{"Markdown", exporter.ExportMarkdown},
+		{"text", exporter.ExportText},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			path := "report." + strings.ToLower(tt.format)
+			notice := captureStderr(t, func() {
+				if err := tt.export(path); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if want := "Exported to " + tt.format + ": " + path + "\n"; notice != want {
+				t.Errorf("notice = %q, want %q", notice, want)
+			}
+
+			missing := filepath.Join("missing", "report")
+			err := tt.export(missing)
+			if err == nil || !strings.HasPrefix(err.Error(), "failed to create "+tt.format+" file: ") {
+				t.Errorf("error = %v, want a failed to create %s file error", err, tt.format)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package cli
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+// reportTemplate is the single-file HTML report. Its CSS and JavaScript
+// are inline so the report works offline; only avatars load remotely.
+//
+//go:embed templates/report.html
+var reportTemplate string
+
+type htmlReport struct {
+	Timestamp  string
+	Version    string
+	Summary    []statusCount
+	Usernames  []htmlUsername
+	Categories []string
+	Statuses   []htmlStatusFilter
+	Charts     []htmlChart
+	Results    []htmlResult
+}
+
+// statusCount is one line of a report's summary. Status is empty for the
+// total.
+type statusCount struct {
+	Status core.ResultStatus
+	Label  string
+	Count  int
+}
+
+type htmlUsername struct {
+	Name  string
+	Found int
+}
+
+type htmlStatusFilter struct {
+	Status  core.ResultStatus
+	Label   string
+	Checked bool
+}
+
+// htmlChart holds the hits per category of one username, or of all of
+// them when User is empty.
+type htmlChart struct {
+	User string
+	Bars []htmlBar
+}
+
+type htmlBar struct {
+	Category string
+	Hits     int
+	Percent  int
+}
+
+type htmlResult struct {
+	core.SiteResult
+	ResponseLink string
+	Search       string
+}
+
+// reportStatuses lists the statuses in the order the report shows them,
+// with the ones whose rows are visible when the report is opened.
+var reportStatuses = []htmlStatusFilter{
+	{Status: core.ResultStatusFound, Label: "Found", Checked: true},
+	{Status: core.ResultStatusAmbiguous, Label: "Ambiguous", Checked: true},
+	{Status: core.ResultStatusUnknown, Label: "Unknown"},
+	{Status: core.ResultStatusNotFound, Label: "Not Found"},
+	{Status: core.ResultStatusError, Label: "Errors"},
+	{Status: core.ResultStatusNotValid, Label: "Not Valid"},
+	{Status: core.ResultStatusCancelled, Label: "Cancelled"},
+}
+
+func (e *Exporter) ExportHTML(path string) error {
+	if path == "" {
+		path = fmt.Sprintf("usrsx_results_%s.html", e.Timestamp.Format("20060102_150405"))
+	}
+	return exportTo(path, "HTML", func(w io.Writer) error {
+		return e.writeHTML(w, path)
+	})
+}
+
+// WriteHTML writes the report to w. Links to saved responses are relative
+// to the working directory.
+func (e *Exporter) WriteHTML(w io.Writer) error {
+	return e.writeHTML(w, "")
+}
+
+// writeHTML writes the report for a file at path, which saved responses
+// are linked relative to.
+func (e *Exporter) writeHTML(w io.Writer, path string) error {
+	t, err := template.New("report").Parse(reportTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse HTML template: %w", err)
+	}
+	if err := t.Execute(w, e.htmlReport(path)); err != nil {
+		return fmt.Errorf("failed to execute HTML template: %w", err)
+	}
+	return nil
+}
+
+func (e *Exporter) htmlReport(path string) htmlReport {
+	report := htmlReport{
+		Timestamp: e.Timestamp.Format(time.RFC3339),
+		Version:   core.Version,
+		Summary:   e.statusCounts(),
+	}
+
+	for _, count := range report.Summary[1:] {
+		for _, status := range reportStatuses {
+			if status.Status == count.Status {
+				report.Statuses = append(report.Statuses, status)
+			}
+		}
+	}
+
+	categories := make(map[string]bool)
+	for _, result := range e.Results {
+		if result.Category != "" {
+			categories[result.Category] = true
+		}
+	}
+	for category := range categories {
+		report.Categories = append(report.Categories, category)
+	}
+	sort.Strings(report.Categories)
+
+	groups := groupByUsername(e, func(core.SiteResult) bool { return true })
+	report.Charts = append(report.Charts, htmlChart{Bars: hitsPerCategory(e.Results)})
+	for _, username := range groups.order {
+		results := groups.results[username]
+		hits := hitsPerCategory(results)
+		found := 0
+		for _, bar := range hits {
+			found += bar.Hits
+		}
+		report.Usernames = append(report.Usernames, htmlUsername{Name: username, Found: found})
+		report.Charts = append(report.Charts, htmlChart{User: username, Bars: hits})
+
+		for _, result := range results {
+			row := htmlResult{SiteResult: result, Search: searchText(result)}
+			if result.ResponsePath != "" {
+				row.ResponseLink = responseLink(path, result.ResponsePath)
+			}
+			report.Results = append(report.Results, row)
+		}
+	}
+
+	return report
+}
+
+// statusCounts returns the total followed by the count of each status in
+// reportStatuses. Not valid and cancelled are left out when there are none.
+func (e *Exporter) statusCounts() []statusCount {
+	counts := []statusCount{{Label: "Total", Count: len(e.Results)}}
+	for _, status := range reportStatuses {
+		count := e.countByStatus(status.Status)
+		if count == 0 && (status.Status == core.ResultStatusNotValid || status.Status == core.ResultStatusCancelled) {
+			continue
+		}
+		counts = append(counts, statusCount{Status: status.Status, Label: status.Label, Count: count})
+	}
+	return counts
+}
+
+// hitsPerCategory counts found results that are not marked as false
+// positives per category, most hits first. Percent is relative to the
+// largest category.
+func hitsPerCategory(results []core.SiteResult) []htmlBar {
+	counts := make(map[string]int)
+	for _, result := range results {
+		if result.ResultStatus == core.ResultStatusFound && !result.FalsePositive {
+			counts[result.Category]++
+		}
+	}
+
+	bars := make([]htmlBar, 0, len(counts))
+	max := 0
+	for category, hits := range counts {
+		if category == "" {
+			category = "uncategorized"
+		}
+		bars = append(bars, htmlBar{Category: category, Hits: hits})
+		if hits > max {
+			max = hits
+		}
+	}
+	sort.Slice(bars, func(i, j int) bool {
+		if bars[i].Hits != bars[j].Hits {
+			return bars[i].Hits > bars[j].Hits
+		}
+		return bars[i].Category < bars[j].Category
+	})
+	for i := range bars {
+		bars[i].Percent = bars[i].Hits * 100 / max
+	}
+	return bars
+}
+
+// searchText is the lower-cased text the report's search box matches
+// against.
+func searchText(result core.SiteResult) string {
+	fields := []string{result.Username, result.SiteName, result.Category, result.ResultURL, result.Error}
+	if m := result.Metadata; m != nil {
+		fields = append(fields, m.DisplayName, m.Bio, m.Location, m.Website)
+		for _, key := range sortedKeys(m.CustomFields) {
+			fields = append(fields, m.CustomFields[key])
+		}
+	}
+	return strings.ToLower(strings.Join(fields, " "))
+}
+
+// responseLink returns a link to a saved response that works from the
+// report at reportPath: relative when possible, absolute otherwise.
+func responseLink(reportPath, responsePath string) string {
+	target, err := filepath.Abs(responsePath)
+	if err != nil {
+		return filepath.ToSlash(responsePath)
+	}
+	if reportDir, err := filepath.Abs(filepath.Dir(reportPath)); err == nil {
+		if rel, err := filepath.Rel(reportDir, target); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(target)
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+// ExportMarkdown writes a Markdown report to path, or to stdout when path
+// is empty: a summary, a table of found accounts per username and the
+// errors. Results marked as false positives are left out.
+func (e *Exporter) ExportMarkdown(path string) error {
+	return exportTo(path, "Markdown", e.WriteMarkdown)
+}
+
+// WriteMarkdown writes the --md-output report to w.
+func (e *Exporter) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("# usrsx Results\n\n")
+	fmt.Fprintf(&b, "- **Usernames:** %s\n", markdownCell(strings.Join(e.Usernames, ", ")))
+	fmt.Fprintf(&b, "- **Timestamp:** %s\n\n", e.Timestamp.Format(time.RFC3339))
+
+	b.WriteString("## Summary\n\n")
+	b.WriteString("| Status | Count |\n|---|---:|\n")
+	for _, count := range e.statusCounts() {
+		fmt.Fprintf(&b, "| %s | %d |\n", count.Label, count.Count)
+	}
+
+	b.WriteString("\n## Found accounts\n")
+	found := groupByUsername(e, isReportedHit)
+	if len(found.order) == 0 {
+		b.WriteString("\nNo accounts were found.\n")
+	}
+	for _, username := range found.order {
+		results := found.results[username]
+		fmt.Fprintf(&b, "\n### %s (%d)\n\n", markdownCell(username), len(results))
+		b.WriteString("| Site | Category | URL | Display name | Followers |\n|---|---|---|---|---:|\n")
+		for _, result := range results {
+			name, followers := profileColumns(result)
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n",
+				markdownCell(result.SiteName),
+				markdownCell(result.Category),
+				markdownURL(result.ResultURL),
+				markdownCell(name),
+				followers)
+		}
+	}
+
+	errors := groupByUsername(e, isError)
+	if len(errors.order) > 0 {
+		b.WriteString("\n## Errors\n\n")
+		b.WriteString("| Username | Site | Error |\n|---|---|---|\n")
+		for _, username := range errors.order {
+			for _, result := range errors.results[username] {
+				fmt.Fprintf(&b, "| %s | %s | %s |\n",
+					markdownCell(username),
+					markdownCell(result.SiteName),
+					markdownCell(result.Error))
+			}
+		}
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write Markdown: %w", err)
+	}
+	return nil
+}
+
+// markdownCell keeps s on one line and escapes the characters that would
+// end a table cell or start formatting.
+func markdownCell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.NewReplacer(
+		`\`, `\\`,
+		"|", `\|`,
+		"*", `\*`,
+		"_", `\_`,
+		"`", "\\`",
+		"<", "&lt;",
+	).Replace(s)
+}
+
+// markdownURL writes url as an autolink. Escapes are not processed inside
+// autolinks, so the characters that would break it are percent-encoded.
+func markdownURL(url string) string {
+	if url == "" {
+		return ""
+	}
+	return "<" + strings.NewReplacer(
+		" ", "%20",
+		"|", "%7C",
+		"<", "%3C",
+		">", "%3E",
+	).Replace(url) + ">"
+}
+
+// isReportedHit reports whether a result belongs in a report's found
+// accounts.
+func isReportedHit(result core.SiteResult) bool {
+	return result.ResultStatus == core.ResultStatusFound && !result.FalsePositive
+}
+
+func isError(result core.SiteResult) bool {
+	return result.ResultStatus == core.ResultStatusError
+}
+
+// profileColumns returns the display name and follower count of a result
+// for the report tables, empty when unknown.
+func profileColumns(result core.SiteResult) (name, followers string) {
+	if result.Metadata == nil {
+		return "", ""
+	}
+	name = result.Metadata.DisplayName
+	if result.Metadata.FollowerCount > 0 {
+		followers = strconv.Itoa(result.Metadata.FollowerCount)
+	}
+	return name, followers
+}
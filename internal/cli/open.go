@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Opener opens a URL in a web browser.
+type Opener interface {
+	Open(url string) error
+}
+
+// SystemOpener opens URLs with the commands listed in $BROWSER, or with
+// the desktop's default handler when it is unset. Open returns as soon as
+// the command has started; its output is discarded so it cannot draw over
+// a full-screen view.
+type SystemOpener struct{}
+
+func (SystemOpener) Open(target string) error {
+	if browsers := os.Getenv("BROWSER"); browsers != "" {
+		return openWithBrowserEnv(browsers, target)
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	return start(cmd, target)
+}
+
+// openWithBrowserEnv tries each command of a colon-separated $BROWSER in
+// turn.
+func openWithBrowserEnv(browsers, target string) error {
+	var errs []error
+	for _, args := range browserCommands(browsers, target) {
+		err := start(exec.Command(args[0], args[1:]...), target)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("failed to open %s: $BROWSER is empty", target)
+	}
+	return errors.Join(errs...)
+}
+
+// browserCommands splits a colon-separated $BROWSER into the commands to
+// run for target. A %s in a command is replaced by the URL, otherwise the
+// URL is appended as the last argument.
+func browserCommands(browsers, target string) [][]string {
+	var commands [][]string
+	for _, command := range strings.Split(browsers, string(os.PathListSeparator)) {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			continue
+		}
+
+		substituted := false
+		for i, field := range fields {
+			if strings.Contains(field, "%s") {
+				fields[i] = strings.ReplaceAll(field, "%s", target)
+				substituted = true
+			}
+		}
+		if !substituted {
+			fields = append(fields, target)
+		}
+		commands = append(commands, fields)
+	}
+	return commands
+}
+
+func start(cmd *exec.Cmd, target string) error {
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to open %s: %w", target, err)
+	}
+	go cmd.Wait()
+	return nil
+}
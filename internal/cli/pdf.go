@@ -0,0 +1,416 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+const (
+	pdfMargin     = 15.0
+	pdfLineHeight = 5.5
+)
+
+// pdfReport lays out the PDF report. It uses the standard Helvetica font,
+// which needs text in Windows-1252; characters outside it become "?".
+type pdfReport struct {
+	pdf     *fpdf.Fpdf
+	encoder *encoding.Encoder
+	width   float64
+}
+
+// ExportPDF writes the PDF report (see WritePDF) to path.
+func (e *Exporter) ExportPDF(path string) error {
+	return exportTo(path, "PDF", e.WritePDF)
+}
+
+// WritePDF writes a PDF report: a cover page, summary tables by status and
+// by category, the found accounts with their profile metadata and an
+// appendix of errors. The output only depends on the results and the
+// exporter's timestamp, so the same input always produces the same file.
+func (e *Exporter) WritePDF(w io.Writer) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin+5)
+	pdf.SetCreationDate(e.Timestamp)
+	pdf.SetModificationDate(e.Timestamp)
+	pdf.SetCatalogSort(true)
+	pdf.SetTitle("usrsx Results", true)
+	pdf.SetCreator("usrsx "+core.Version, true)
+	pdf.AliasNbPages("")
+
+	pageWidth, _ := pdf.GetPageSize()
+	r := &pdfReport{
+		pdf:     pdf,
+		encoder: encoding.ReplaceUnsupported(charmap.Windows1252.NewEncoder()),
+		width:   pageWidth - 2*pdfMargin,
+	}
+
+	pdf.SetFooterFunc(func() {
+		if pdf.PageNo() == 1 {
+			return
+		}
+		pdf.SetY(-pdfMargin)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.CellFormat(0, 5, r.text(fmt.Sprintf("usrsx report - page %d of {nb}", pdf.PageNo())), "", 0, "C", false, 0, "")
+	})
+
+	r.cover(e)
+	r.summary(e)
+	r.found(e)
+	r.errors(e)
+
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return nil
+}
+
+func (r *pdfReport) text(s string) string {
+	encoded, err := r.encoder.String(s)
+	if err != nil {
+		return strings.Map(func(c rune) rune {
+			if c > 127 {
+				return '?'
+			}
+			return c
+		}, s)
+	}
+	// The charmap encoder substitutes unsupported runes with SUB.
+	return strings.ReplaceAll(encoded, "\x1a", "?")
+}
+
+// fit shortens s with an ellipsis until it fits into width.
+func (r *pdfReport) fit(s string, width float64) string {
+	s = r.text(s)
+	if r.pdf.GetStringWidth(s) <= width {
+		return s
+	}
+	ellipsis := "..."
+	for len(s) > 0 && r.pdf.GetStringWidth(s+ellipsis) > width {
+		s = s[:len(s)-1]
+	}
+	return s + ellipsis
+}
+
+func (r *pdfReport) heading(title string) {
+	r.pdf.SetFont("Helvetica", "B", 14)
+	r.pdf.SetTextColor(33, 33, 33)
+	r.pdf.CellFormat(0, 10, r.text(title), "B", 1, "L", false, 0, "")
+	r.pdf.Ln(3)
+}
+
+func (r *pdfReport) subheading(title string) {
+	r.pdf.SetFont("Helvetica", "B", 11)
+	r.pdf.SetTextColor(33, 33, 33)
+	r.pdf.CellFormat(0, 8, r.text(title), "", 1, "L", false, 0, "")
+}
+
+// field writes a "label: value" line, wrapping long values.
+func (r *pdfReport) field(label, value string) {
+	if value == "" {
+		return
+	}
+	const labelWidth = 32.0
+	r.pdf.SetX(pdfMargin + 4)
+	r.pdf.SetFont("Helvetica", "", 9)
+	r.pdf.SetTextColor(110, 110, 110)
+	r.pdf.CellFormat(labelWidth, pdfLineHeight, r.text(label), "", 0, "L", false, 0, "")
+	r.pdf.SetTextColor(33, 33, 33)
+	r.pdf.MultiCell(r.width-labelWidth-4, pdfLineHeight, r.text(value), "", "L", false)
+}
+
+// table draws a table with a shaded header row. Cells that do not fit are
+// shortened.
+func (r *pdfReport) table(widths []float64, header []string, rows [][]string) {
+	drawHeader := func() {
+		r.pdf.SetFont("Helvetica", "B", 9)
+		r.pdf.SetFillColor(76, 175, 80)
+		r.pdf.SetTextColor(255, 255, 255)
+		for i, title := range header {
+			r.pdf.CellFormat(widths[i], 7, r.fit(title, widths[i]-2), "", 0, "L", true, 0, "")
+		}
+		r.pdf.Ln(-1)
+	}
+
+	drawHeader()
+	r.pdf.SetFont("Helvetica", "", 9)
+	r.pdf.SetTextColor(33, 33, 33)
+	_, pageHeight := r.pdf.GetPageSize()
+	for n, row := range rows {
+		if r.pdf.GetY()+6 > pageHeight-pdfMargin-5 {
+			r.pdf.AddPage()
+			drawHeader()
+			r.pdf.SetFont("Helvetica", "", 9)
+			r.pdf.SetTextColor(33, 33, 33)
+		}
+		r.pdf.SetFillColor(245, 245, 245)
+		for i, cell := range row {
+			align := "L"
+			if i > 0 && isNumber(cell) {
+				align = "R"
+			}
+			r.pdf.CellFormat(widths[i], 6, r.fit(cell, widths[i]-2), "", 0, align, n%2 == 1, 0, "")
+		}
+		r.pdf.Ln(-1)
+	}
+	r.pdf.Ln(4)
+}
+
+func (r *pdfReport) cover(e *Exporter) {
+	r.pdf.AddPage()
+	r.pdf.Ln(60)
+
+	r.pdf.SetFont("Helvetica", "B", 28)
+	r.pdf.SetTextColor(33, 33, 33)
+	r.pdf.CellFormat(0, 14, "usrsx Report", "", 1, "C", false, 0, "")
+	r.pdf.SetFont("Helvetica", "", 12)
+	r.pdf.SetTextColor(110, 110, 110)
+	r.pdf.CellFormat(0, 8, "Username presence across websites", "", 1, "C", false, 0, "")
+	r.pdf.Ln(20)
+
+	r.pdf.SetFont("Helvetica", "B", 12)
+	r.pdf.SetTextColor(33, 33, 33)
+	r.pdf.CellFormat(0, 8, "Usernames", "", 1, "C", false, 0, "")
+	r.pdf.SetFont("Helvetica", "", 12)
+	for _, username := range e.Usernames {
+		r.pdf.CellFormat(0, 7, r.text(username), "", 1, "C", false, 0, "")
+	}
+	r.pdf.Ln(10)
+
+	r.pdf.SetFont("Helvetica", "", 10)
+	r.pdf.SetTextColor(110, 110, 110)
+	lines := []string{
+		"Generated " + e.Timestamp.UTC().Format("2006-01-02 15:04:05 UTC"),
+		fmt.Sprintf("%d checks, %d found", len(e.Results), e.countByStatus(core.ResultStatusFound)),
+		"usrsx " + core.Version,
+	}
+	for _, line := range lines {
+		r.pdf.CellFormat(0, 6, r.text(line), "", 1, "C", false, 0, "")
+	}
+}
+
+func (r *pdfReport) summary(e *Exporter) {
+	r.pdf.AddPage()
+	r.heading("Summary")
+
+	r.subheading("By status")
+	statuses := []struct {
+		label  string
+		status core.ResultStatus
+	}{
+		{"Found", core.ResultStatusFound},
+		{"Ambiguous", core.ResultStatusAmbiguous},
+		{"Not found", core.ResultStatusNotFound},
+		{"Unknown", core.ResultStatusUnknown},
+		{"Not valid", core.ResultStatusNotValid},
+		{"Errors", core.ResultStatusError},
+		{"Cancelled", core.ResultStatusCancelled},
+	}
+	var rows [][]string
+	for _, s := range statuses {
+		rows = append(rows, []string{s.label, fmt.Sprintf("%d", e.countByStatus(s.status))})
+	}
+	rows = append(rows, []string{"Total", fmt.Sprintf("%d", len(e.Results))})
+	r.table([]float64{50, 25}, []string{"Status", "Count"}, rows)
+
+	r.subheading("By category")
+	type counts struct{ found, ambiguous, notFound, errors, total int }
+	byCategory := make(map[string]*counts)
+	for _, result := range e.Results {
+		c := byCategory[result.Category]
+		if c == nil {
+			c = &counts{}
+			byCategory[result.Category] = c
+		}
+		c.total++
+		switch result.ResultStatus {
+		case core.ResultStatusFound:
+			c.found++
+		case core.ResultStatusAmbiguous:
+			c.ambiguous++
+		case core.ResultStatusNotFound:
+			c.notFound++
+		case core.ResultStatusError:
+			c.errors++
+		}
+	}
+	categories := make([]string, 0, len(byCategory))
+	for category := range byCategory {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	rows = rows[:0]
+	for _, category := range categories {
+		c := byCategory[category]
+		name := category
+		if name == "" {
+			name = "(none)"
+		}
+		rows = append(rows, []string{name,
+			fmt.Sprintf("%d", c.found), fmt.Sprintf("%d", c.ambiguous), fmt.Sprintf("%d", c.notFound),
+			fmt.Sprintf("%d", c.errors), fmt.Sprintf("%d", c.total)})
+	}
+	r.table([]float64{55, 22, 25, 25, 22, 22}, []string{"Category", "Found", "Ambiguous", "Not found", "Errors", "Total"}, rows)
+}
+
+func (r *pdfReport) found(e *Exporter) {
+	r.pdf.AddPage()
+	r.heading("Found accounts")
+
+	byUsername := groupByUsername(e, func(result core.SiteResult) bool {
+		return result.ResultStatus == core.ResultStatusFound && !result.FalsePositive
+	})
+	if len(byUsername.order) == 0 {
+		r.pdf.SetFont("Helvetica", "I", 10)
+		r.pdf.CellFormat(0, 8, "No accounts were found.", "", 1, "L", false, 0, "")
+		return
+	}
+
+	for _, username := range byUsername.order {
+		results := byUsername.results[username]
+		r.subheading(fmt.Sprintf("%s (%d)", username, len(results)))
+
+		for _, result := range results {
+			r.pdf.SetX(pdfMargin + 2)
+			r.pdf.SetFont("Helvetica", "B", 10)
+			r.pdf.SetTextColor(33, 33, 33)
+			r.pdf.CellFormat(0, 6, r.text(fmt.Sprintf("%s  (%s)", result.SiteName, result.Category)), "", 1, "L", false, 0, "")
+			if result.ResultURL != "" {
+				r.pdf.SetX(pdfMargin + 4)
+				r.pdf.SetFont("Helvetica", "U", 9)
+				r.pdf.SetTextColor(33, 150, 243)
+				r.pdf.CellFormat(0, pdfLineHeight, r.fit(result.ResultURL, r.width-4), "", 1, "L", false, 0, result.ResultURL)
+			}
+			r.metadata(result.Metadata)
+			r.pdf.Ln(2)
+		}
+		r.pdf.Ln(3)
+	}
+}
+
+func (r *pdfReport) metadata(m *core.ProfileMetadata) {
+	if m == nil {
+		return
+	}
+
+	r.field("Name", m.DisplayName)
+	r.field("Bio", m.Bio)
+	r.field("Location", m.Location)
+	r.field("Website", m.Website)
+	r.field("Joined", m.JoinDate)
+	if m.FollowerCount > 0 {
+		r.field("Followers", fmt.Sprintf("%d", m.FollowerCount))
+	}
+	if m.FollowingCount > 0 {
+		r.field("Following", fmt.Sprintf("%d", m.FollowingCount))
+	}
+	if m.IsVerified {
+		r.field("Verified", "Yes")
+	}
+	r.field("Avatar", m.AvatarURL)
+	for _, key := range sortedKeys(m.AdditionalLinks) {
+		r.field(key, m.AdditionalLinks[key])
+	}
+	for _, key := range sortedKeys(m.CustomFields) {
+		if key != "username" {
+			r.field(key, m.CustomFields[key])
+		}
+	}
+}
+
+func (r *pdfReport) errors(e *Exporter) {
+	byUsername := groupByUsername(e, func(result core.SiteResult) bool {
+		return result.ResultStatus == core.ResultStatusError
+	})
+	if len(byUsername.order) == 0 {
+		return
+	}
+
+	r.pdf.AddPage()
+	r.heading("Appendix: errors")
+	for _, username := range byUsername.order {
+		r.subheading(username)
+		for _, result := range byUsername.results[username] {
+			r.pdf.SetX(pdfMargin + 2)
+			r.pdf.SetFont("Helvetica", "B", 9)
+			r.pdf.SetTextColor(33, 33, 33)
+			r.pdf.CellFormat(45, pdfLineHeight, r.fit(result.SiteName, 44), "", 0, "L", false, 0, "")
+			r.pdf.SetFont("Helvetica", "", 9)
+			r.pdf.SetTextColor(200, 40, 40)
+			r.pdf.MultiCell(r.width-47, pdfLineHeight, r.text(result.Error), "", "L", false)
+		}
+		r.pdf.Ln(3)
+	}
+}
+
+type usernameGroups struct {
+	order   []string
+	results map[string][]core.SiteResult
+}
+
+// groupByUsername collects the results matching keep per username, with
+// usernames in the exporter's order and results sorted by site name.
+func groupByUsername(e *Exporter, keep func(core.SiteResult) bool) usernameGroups {
+	groups := usernameGroups{results: make(map[string][]core.SiteResult)}
+	for _, result := range e.Results {
+		if keep(result) {
+			groups.results[result.Username] = append(groups.results[result.Username], result)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, username := range e.Usernames {
+		if _, ok := groups.results[username]; ok && !seen[username] {
+			seen[username] = true
+			groups.order = append(groups.order, username)
+		}
+	}
+	var rest []string
+	for username := range groups.results {
+		if !seen[username] {
+			rest = append(rest, username)
+		}
+	}
+	sort.Strings(rest)
+	groups.order = append(groups.order, rest...)
+
+	for _, results := range groups.results {
+		sort.SliceStable(results, func(i, j int) bool {
+			return strings.ToLower(results[i].SiteName) < strings.ToLower(results[j].SiteName)
+		})
+	}
+	return groups
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key, value := range m {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
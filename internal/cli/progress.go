@@ -1,7 +1,9 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
@@ -12,15 +14,6 @@ import (
 	"github.com/gnomegl/usrsx/internal/core"
 )
 
-var (
-	successStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
-	errorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
-	warningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
-	infoStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("12"))
-	subtleStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
-	headerStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
-)
-
 type ResultTracker struct {
 	Total     int
 	Found     int
@@ -28,42 +21,83 @@ type ResultTracker struct {
 	Errors    int
 	Unknown   int
 	Ambiguous int
+	Cancelled int
 	Processed int
 }
 
+type ProgressConfig struct {
+	Total       int
+	ShowDetails bool
+	Renderer    *Renderer
+
+	// Display reports whether a result is printed above the progress bar.
+	Display func(core.SiteResult) bool
+
+	// Cancel stops the scan when the user presses q or Ctrl-C. The view
+	// keeps running until the remaining checks have drained.
+	Cancel context.CancelFunc
+}
+
 type ProgressModel struct {
-	spinner       spinner.Model
-	progress      progress.Model
-	tracker       *ResultTracker
-	currentSite   string
-	done          bool
-	quitting      bool
-	noProgressbar bool
-	foundResults  []core.SiteResult
+	config       ProgressConfig
+	r            *Renderer
+	spinner      spinner.Model
+	progress     progress.Model
+	tracker      *ResultTracker
+	currentSite  string
+	started      time.Time
+	done         bool
+	cancelling   bool
+	foundResults []core.SiteResult
 }
 
-func NewProgressModel(total int, noProgressbar bool) ProgressModel {
+func NewProgressModel(config ProgressConfig) ProgressModel {
+	r := config.Renderer
+	if r == nil {
+		r = NewRenderer(os.Stdout, false)
+	}
+
 	s := spinner.New()
 	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	s.Style = r.subtle
 
 	p := progress.New(
 		progress.WithSolidFill("240"),
 		progress.WithoutPercentage(),
+		progress.WithColorProfile(r.ColorProfile()),
 	)
 	p.Width = 40
 
 	return ProgressModel{
-		spinner:       s,
-		progress:      p,
-		noProgressbar: noProgressbar,
+		config:   config,
+		r:        r,
+		spinner:  s,
+		progress: p,
+		started:  time.Now(),
 		tracker: &ResultTracker{
-			Total: total,
+			Total: config.Total,
 		},
 		foundResults: make([]core.SiteResult, 0),
 	}
 }
 
+// RunProgress shows the progress view while feed delivers messages to it,
+// and returns once feed has returned and the view has closed.
+func RunProgress(config ProgressConfig, feed func(p *tea.Program)) error {
+	p := tea.NewProgram(NewProgressModel(config), tea.WithoutSignalHandler())
+
+	fed := make(chan struct{})
+	go func() {
+		defer close(fed)
+		feed(p)
+		p.Send(DoneMsg{})
+	}()
+
+	_, err := p.Run()
+	<-fed
+	return err
+}
+
 type tickMsg struct{}
 
 func (m ProgressModel) Init() tea.Cmd {
@@ -77,8 +111,10 @@ func (m ProgressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "q", "ctrl+c":
-			m.quitting = true
-			return m, tea.Quit
+			if !m.cancelling && m.config.Cancel != nil {
+				m.cancelling = true
+				m.config.Cancel()
+			}
 		}
 
 	case spinner.TickMsg:
@@ -87,26 +123,18 @@ func (m ProgressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 
 	case ResultMsg:
-		m.tracker.Processed++
-		switch msg.Result.ResultStatus {
-		case core.ResultStatusFound:
-			m.tracker.Found++
-		case core.ResultStatusNotFound:
-			m.tracker.NotFound++
-		case core.ResultStatusError:
-			m.tracker.Errors++
-		case core.ResultStatusAmbiguous:
-			m.tracker.Ambiguous++
-		case core.ResultStatusUnknown:
-			m.tracker.Unknown++
+		m.record(msg.Result)
+		if m.config.Display != nil && m.config.Display(msg.Result) {
+			return m, tea.Println(m.r.FormatResult(msg.Result, m.config.ShowDetails))
 		}
-		m.currentSite = msg.Result.SiteName
+		return m, nil
 
-		if msg.Result.ResultStatus == core.ResultStatusFound {
-			m.foundResults = append(m.foundResults, msg.Result)
+	case SelfCheckMsg:
+		for _, result := range msg.Result.Results {
+			m.record(result)
 		}
-
-		return m, nil
+		m.currentSite = msg.Result.SiteName
+		return m, tea.Println(m.r.FormatSelfCheckResult(msg.Result, m.config.ShowDetails))
 
 	case DoneMsg:
 		m.done = true
@@ -123,18 +151,38 @@ func (m ProgressModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-func (m ProgressModel) View() string {
-	if m.quitting || m.done {
-		return ""
+func (m *ProgressModel) record(result core.SiteResult) {
+	m.tracker.Processed++
+	switch result.ResultStatus {
+	case core.ResultStatusFound:
+		m.tracker.Found++
+	case core.ResultStatusNotFound:
+		m.tracker.NotFound++
+	case core.ResultStatusError:
+		m.tracker.Errors++
+	case core.ResultStatusAmbiguous:
+		m.tracker.Ambiguous++
+	case core.ResultStatusUnknown:
+		m.tracker.Unknown++
+	case core.ResultStatusCancelled:
+		m.tracker.Cancelled++
+	}
+	m.currentSite = result.SiteName
+
+	if result.ResultStatus == core.ResultStatusFound {
+		m.foundResults = append(m.foundResults, result)
 	}
+}
 
-	if m.noProgressbar {
+func (m ProgressModel) View() string {
+	if m.done {
 		return ""
 	}
 
 	var b strings.Builder
 
-	b.WriteString("\033[K")
+	b.WriteString(m.spinner.View())
+	b.WriteString(" ")
 
 	if m.tracker.Total > 0 {
 		percent := float64(m.tracker.Processed) / float64(m.tracker.Total)
@@ -142,50 +190,85 @@ func (m ProgressModel) View() string {
 		b.WriteString(fmt.Sprintf("  %d/%d  ", m.tracker.Processed, m.tracker.Total))
 	}
 
-	b.WriteString(fmt.Sprintf("%s %d  ", successStyle.Render("✓"), m.tracker.Found))
-	b.WriteString(fmt.Sprintf("%s %d", subtleStyle.Render("✗"), m.tracker.NotFound))
+	b.WriteString(fmt.Sprintf("%s %d  ", m.r.success.Render("✓"), m.tracker.Found))
+	b.WriteString(fmt.Sprintf("%s %d", m.r.subtle.Render("✗"), m.tracker.NotFound))
 
 	if m.tracker.Errors > 0 {
-		b.WriteString(fmt.Sprintf("  %s %d", errorStyle.Render("!"), m.tracker.Errors))
+		b.WriteString(fmt.Sprintf("  %s %d", m.r.failure.Render("!"), m.tracker.Errors))
 	}
 	if m.tracker.Unknown > 0 {
-		b.WriteString(fmt.Sprintf("  %s %d", warningStyle.Render("?"), m.tracker.Unknown))
+		b.WriteString(fmt.Sprintf("  %s %d", m.r.warning.Render("?"), m.tracker.Unknown))
 	}
 	if m.tracker.Ambiguous > 0 {
-		b.WriteString(fmt.Sprintf("  %s %d", warningStyle.Render("~"), m.tracker.Ambiguous))
+		b.WriteString(fmt.Sprintf("  %s %d", m.r.warning.Render("~"), m.tracker.Ambiguous))
+	}
+	if m.tracker.Cancelled > 0 {
+		b.WriteString(fmt.Sprintf("  %s %d", m.r.subtle.Render("-"), m.tracker.Cancelled))
+	}
+
+	elapsed := time.Since(m.started)
+	if m.tracker.Processed > 0 && elapsed > 0 {
+		rate := float64(m.tracker.Processed) / elapsed.Seconds()
+		b.WriteString(m.r.subtle.Render(fmt.Sprintf("  %.1f/s", rate)))
+		if remaining := m.tracker.Total - m.tracker.Processed; remaining > 0 && !m.cancelling {
+			eta := time.Duration(float64(remaining) / rate * float64(time.Second))
+			b.WriteString(m.r.subtle.Render(fmt.Sprintf("  ETA %s", formatETA(eta))))
+		}
 	}
 
-	b.WriteString(fmt.Sprintf("  %s", subtleStyle.Render("(q: quit)")))
+	if m.cancelling {
+		b.WriteString(fmt.Sprintf("  %s", m.r.warning.Render("cancelling...")))
+	} else {
+		if m.currentSite != "" {
+			b.WriteString(fmt.Sprintf("  %s", m.r.subtle.Render(m.currentSite)))
+		}
+		b.WriteString(fmt.Sprintf("  %s", m.r.subtle.Render("(q: quit)")))
+	}
 
 	return b.String()
 }
 
+func formatETA(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d >= time.Hour {
+		return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
 type ResultMsg struct {
 	Result core.SiteResult
 }
 
+type SelfCheckMsg struct {
+	Result core.SelfCheckResult
+}
+
 type DoneMsg struct{}
 
-func formatCompactResult(result core.SiteResult) string {
+func (r *Renderer) formatCompactResult(result core.SiteResult) string {
 	var icon string
 	var style lipgloss.Style
 
 	switch result.ResultStatus {
 	case core.ResultStatusFound:
 		icon = "✓"
-		style = successStyle
+		style = r.success
 	case core.ResultStatusNotFound:
 		icon = "✗"
-		style = subtleStyle
+		style = r.subtle
 	case core.ResultStatusError:
 		icon = "!"
-		style = errorStyle
+		style = r.failure
 	case core.ResultStatusAmbiguous:
 		icon = "~"
-		style = warningStyle
+		style = r.warning
 	case core.ResultStatusUnknown:
 		icon = "?"
-		style = warningStyle
+		style = r.warning
+	case core.ResultStatusCancelled:
+		icon = "-"
+		style = r.subtle
 	}
 
 	line := fmt.Sprintf("%s %s",
@@ -193,32 +276,34 @@ func formatCompactResult(result core.SiteResult) string {
 		result.SiteName)
 
 	if result.ResultURL != "" {
-		line += fmt.Sprintf("  %s", subtleStyle.Render(result.ResultURL))
+		line += fmt.Sprintf("  %s", r.subtle.Render(result.ResultURL))
 	}
 
 	return line
 }
 
-func FormatResult(result core.SiteResult, showDetails bool) string {
+func (r *Renderer) FormatResult(result core.SiteResult, showDetails bool) string {
 	var b strings.Builder
 
 	switch result.ResultStatus {
 	case core.ResultStatusFound:
-		b.WriteString(successStyle.Render("✓ FOUND"))
+		b.WriteString(r.success.Render("✓ FOUND"))
 	case core.ResultStatusNotFound:
-		b.WriteString(subtleStyle.Render("✗ NOT FOUND"))
+		b.WriteString(r.subtle.Render("✗ NOT FOUND"))
 	case core.ResultStatusError:
-		b.WriteString(errorStyle.Render("! ERROR"))
+		b.WriteString(r.failure.Render("! ERROR"))
 	case core.ResultStatusAmbiguous:
-		b.WriteString(warningStyle.Render("~ AMBIGUOUS"))
+		b.WriteString(r.warning.Render("~ AMBIGUOUS"))
 	case core.ResultStatusUnknown:
-		b.WriteString(warningStyle.Render("? UNKNOWN"))
+		b.WriteString(r.warning.Render("? UNKNOWN"))
+	case core.ResultStatusCancelled:
+		b.WriteString(r.subtle.Render("- CANCELLED"))
 	}
 
 	b.WriteString(fmt.Sprintf(" | %s", result.SiteName))
 
 	if result.ResultURL != "" && result.ResultStatus == core.ResultStatusFound {
-		b.WriteString(fmt.Sprintf(" | %s", infoStyle.Render(result.ResultURL)))
+		b.WriteString(fmt.Sprintf(" | %s", r.info.Render(result.ResultURL)))
 	}
 
 	if showDetails {
@@ -228,13 +313,19 @@ func FormatResult(result core.SiteResult, showDetails bool) string {
 		if result.Elapsed > 0 {
 			b.WriteString(fmt.Sprintf(" | %.2fs", result.Elapsed))
 		}
+		if result.Attempts > 1 {
+			b.WriteString(fmt.Sprintf(" | %d attempts", result.Attempts))
+		}
+		if result.Proxy != "" {
+			b.WriteString(fmt.Sprintf(" | via %s", result.Proxy))
+		}
 		if result.Error != "" {
-			b.WriteString(fmt.Sprintf(" | %s", errorStyle.Render(result.Error)))
+			b.WriteString(fmt.Sprintf(" | %s", r.failure.Render(result.Error)))
 		}
 	}
 
 	if result.Metadata != nil && result.ResultStatus == core.ResultStatusFound {
-		metadata := FormatMetadata(result.Metadata)
+		metadata := r.FormatMetadata(result.Metadata)
 		if metadata != "" {
 			b.WriteString("\n")
 			b.WriteString(metadata)
@@ -244,14 +335,11 @@ func FormatResult(result core.SiteResult, showDetails bool) string {
 	return b.String()
 }
 
-func FormatMetadata(metadata *core.ProfileMetadata) string {
+func (r *Renderer) FormatMetadata(metadata *core.ProfileMetadata) string {
 	var lines []string
-	boxStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
-	labelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("243"))
-	valueStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
 
 	if metadata.DisplayName != "" {
-		lines = append(lines, boxStyle.Render("  ├─ ")+labelStyle.Render("Name: ")+valueStyle.Render(metadata.DisplayName))
+		lines = append(lines, r.box.Render("  ├─ ")+r.label.Render("Name: ")+r.value.Render(metadata.DisplayName))
 	}
 
 	if metadata.Bio != "" {
@@ -259,48 +347,48 @@ func FormatMetadata(metadata *core.ProfileMetadata) string {
 		if len(bioPreview) > 100 {
 			bioPreview = bioPreview[:97] + "..."
 		}
-		lines = append(lines, boxStyle.Render("  ├─ ")+labelStyle.Render("Bio: ")+valueStyle.Render(bioPreview))
+		lines = append(lines, r.box.Render("  ├─ ")+r.label.Render("Bio: ")+r.value.Render(bioPreview))
 	}
 
 	if metadata.AvatarURL != "" {
-		lines = append(lines, boxStyle.Render("  ├─ ")+labelStyle.Render("Avatar: ")+valueStyle.Render(metadata.AvatarURL))
+		lines = append(lines, r.box.Render("  ├─ ")+r.label.Render("Avatar: ")+r.value.Render(metadata.AvatarURL))
 	}
 
 	if metadata.Location != "" {
-		lines = append(lines, boxStyle.Render("  ├─ ")+labelStyle.Render("Location: ")+valueStyle.Render(metadata.Location))
+		lines = append(lines, r.box.Render("  ├─ ")+r.label.Render("Location: ")+r.value.Render(metadata.Location))
 	}
 
 	if metadata.Website != "" {
-		lines = append(lines, boxStyle.Render("  ├─ ")+labelStyle.Render("Website: ")+valueStyle.Render(metadata.Website))
+		lines = append(lines, r.box.Render("  ├─ ")+r.label.Render("Website: ")+r.value.Render(metadata.Website))
 	}
 
 	if metadata.JoinDate != "" {
-		lines = append(lines, boxStyle.Render("  ├─ ")+labelStyle.Render("Joined: ")+valueStyle.Render(metadata.JoinDate))
+		lines = append(lines, r.box.Render("  ├─ ")+r.label.Render("Joined: ")+r.value.Render(metadata.JoinDate))
 	}
 
 	if metadata.FollowerCount > 0 {
-		lines = append(lines, boxStyle.Render("  ├─ ")+labelStyle.Render("Followers: ")+valueStyle.Render(fmt.Sprintf("%d", metadata.FollowerCount)))
+		lines = append(lines, r.box.Render("  ├─ ")+r.label.Render("Followers: ")+r.value.Render(fmt.Sprintf("%d", metadata.FollowerCount)))
 	}
 
 	if metadata.FollowingCount > 0 {
-		lines = append(lines, boxStyle.Render("  ├─ ")+labelStyle.Render("Following: ")+valueStyle.Render(fmt.Sprintf("%d", metadata.FollowingCount)))
+		lines = append(lines, r.box.Render("  ├─ ")+r.label.Render("Following: ")+r.value.Render(fmt.Sprintf("%d", metadata.FollowingCount)))
 	}
 
 	if metadata.IsVerified {
-		lines = append(lines, boxStyle.Render("  ├─ ")+labelStyle.Render("Verified: ")+successStyle.Render("✓ Yes"))
+		lines = append(lines, r.box.Render("  ├─ ")+r.label.Render("Verified: ")+r.success.Render("✓ Yes"))
 	}
 
 	if len(metadata.AdditionalLinks) > 0 {
-		lines = append(lines, boxStyle.Render("  ├─ ")+labelStyle.Render("Links:"))
+		lines = append(lines, r.box.Render("  ├─ ")+r.label.Render("Links:"))
 		for key, value := range metadata.AdditionalLinks {
-			lines = append(lines, boxStyle.Render("  │  ├─ ")+labelStyle.Render(key+": ")+valueStyle.Render(value))
+			lines = append(lines, r.box.Render("  │  ├─ ")+r.label.Render(key+": ")+r.value.Render(value))
 		}
 	}
 
 	if len(metadata.CustomFields) > 0 && len(metadata.CustomFields) <= 5 {
 		for key, value := range metadata.CustomFields {
 			if key != "username" && value != "" && len(value) < 50 {
-				lines = append(lines, boxStyle.Render("  ├─ ")+labelStyle.Render(key+": ")+valueStyle.Render(value))
+				lines = append(lines, r.box.Render("  ├─ ")+r.label.Render(key+": ")+r.value.Render(value))
 			}
 		}
 	}
@@ -313,16 +401,18 @@ func FormatMetadata(metadata *core.ProfileMetadata) string {
 	return ""
 }
 
-func FormatSelfCheckResult(result core.SelfCheckResult, showDetails bool) string {
+func (r *Renderer) FormatSelfCheckResult(result core.SelfCheckResult, showDetails bool) string {
 	var b strings.Builder
 
 	switch result.OverallStatus {
 	case core.ResultStatusFound:
-		b.WriteString(successStyle.Render("✓ PASSED"))
+		b.WriteString(r.success.Render("✓ PASSED"))
 	case core.ResultStatusError:
-		b.WriteString(errorStyle.Render("✗ FAILED"))
+		b.WriteString(r.failure.Render("✗ FAILED"))
+	case core.ResultStatusCancelled:
+		b.WriteString(r.subtle.Render("- CANCELLED"))
 	default:
-		b.WriteString(warningStyle.Render("? PARTIAL"))
+		b.WriteString(r.warning.Render("? PARTIAL"))
 	}
 
 	b.WriteString(fmt.Sprintf(" | %s", result.SiteName))
@@ -337,7 +427,7 @@ func FormatSelfCheckResult(result core.SelfCheckResult, showDetails bool) string
 	b.WriteString(fmt.Sprintf(" | %d/%d known accounts found", foundCount, len(result.Results)))
 
 	if showDetails && result.Error != "" {
-		b.WriteString(fmt.Sprintf(" | %s", errorStyle.Render(result.Error)))
+		b.WriteString(fmt.Sprintf(" | %s", r.failure.Render(result.Error)))
 	}
 
 	return b.String()
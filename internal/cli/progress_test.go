@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+func TestProgressViewWithoutTerminal(t *testing.T) {
+	cancelled := 0
+	var m tea.Model = NewProgressModel(ProgressConfig{
+		Total:    4,
+		Renderer: plainRenderer(t),
+		Display: func(result core.SiteResult) bool {
+			return result.ResultStatus == core.ResultStatusFound
+		},
+		Cancel: func() { cancelled++ },
+	})
+
+	steps := []struct {
+		msg       tea.Msg
+		printed   bool
+		contains  []string
+		cancelled int
+	}{
+		{
+			msg:      ResultMsg{Result: core.SiteResult{SiteName: "GitHub", ResultStatus: core.ResultStatusFound}},
+			printed:  true,
+			contains: []string{"1/4", "✓ 1", "✗ 0", "GitHub", "(q: quit)"},
+		},
+		{
+			msg:      ResultMsg{Result: core.SiteResult{SiteName: "Reddit", ResultStatus: core.ResultStatusNotFound}},
+			contains: []string{"2/4", "✓ 1", "✗ 1", "Reddit"},
+		},
+		{
+			msg:      ResultMsg{Result: core.SiteResult{SiteName: "Flaky", ResultStatus: core.ResultStatusError}},
+			contains: []string{"3/4", "! 1", "Flaky"},
+		},
+		{
+			msg:       tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")},
+			contains:  []string{"cancelling..."},
+			cancelled: 1,
+		},
+		{
+			msg:       tea.KeyMsg{Type: tea.KeyCtrlC},
+			contains:  []string{"cancelling..."},
+			cancelled: 1,
+		},
+		{
+			msg:       ResultMsg{Result: core.SiteResult{SiteName: "Slow", ResultStatus: core.ResultStatusCancelled}},
+			contains:  []string{"4/4", "- 1"},
+			cancelled: 1,
+		},
+	}
+
+	for i, step := range steps {
+		var cmd tea.Cmd
+		m, cmd = m.Update(step.msg)
+		if printed := cmd != nil; printed != step.printed {
+			t.Errorf("step %d: printed = %v, want %v", i, printed, step.printed)
+		}
+		view := m.View()
+		if strings.Contains(view, "\x1b") {
+			t.Errorf("step %d: view contains escape sequences: %q", i, view)
+		}
+		for _, want := range step.contains {
+			if !strings.Contains(view, want) {
+				t.Errorf("step %d: view %q does not contain %q", i, view, want)
+			}
+		}
+		if cancelled != step.cancelled {
+			t.Errorf("step %d: Cancel called %d times, want %d", i, cancelled, step.cancelled)
+		}
+	}
+
+	m, _ = m.Update(DoneMsg{})
+	if view := m.View(); view != "" {
+		t.Errorf("view after DoneMsg = %q, want it cleared", view)
+	}
+}
+
+func TestFormatETA(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{0, "0:00"},
+		{1400 * time.Millisecond, "0:01"},
+		{75 * time.Second, "1:15"},
+		{59*time.Minute + 59*time.Second, "59:59"},
+		{2*time.Hour + 3*time.Minute + 4*time.Second, "2:03:04"},
+	}
+
+	for _, tt := range tests {
+		if got := formatETA(tt.in); got != tt.want {
+			t.Errorf("formatETA(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
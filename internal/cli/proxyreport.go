@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gnomegl/usrsx/internal/client"
+)
+
+func (r *Renderer) FormatProxyReport(stats []client.ProxyStats) string {
+	var b strings.Builder
+
+	b.WriteString("\n" + strings.Repeat("=", 50) + "\n")
+	b.WriteString("Proxy Report\n")
+	b.WriteString(strings.Repeat("=", 50) + "\n")
+
+	for _, s := range stats {
+		var status string
+		switch s.Status {
+		case client.ProxyStatusActive:
+			status = r.success.Render("active")
+		case client.ProxyStatusQuarantined:
+			status = r.warning.Render("quarantined")
+		case client.ProxyStatusEvicted:
+			status = r.failure.Render("evicted")
+		}
+
+		b.WriteString(fmt.Sprintf("%s | %s | ok %d | failed %d", s.Proxy, status, s.Successes, s.Failures))
+		if s.MedianLatencyMs > 0 {
+			b.WriteString(fmt.Sprintf(" | median %.0fms", s.MedianLatencyMs))
+		}
+		if s.Quarantines > 0 {
+			b.WriteString(fmt.Sprintf(" | quarantined %dx", s.Quarantines))
+		}
+		if s.LastError != "" && s.Status != client.ProxyStatusActive {
+			b.WriteString(fmt.Sprintf(" | %s", r.subtle.Render(s.LastError)))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString(strings.Repeat("=", 50))
+	return b.String()
+}
+
+func ExportProxyReport(path string, stats []client.ProxyStats) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create proxy report file: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(map[string]interface{}{"proxies": stats}); err != nil {
+		return fmt.Errorf("failed to encode proxy report: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported proxy report: %s\n", path)
+	return nil
+}
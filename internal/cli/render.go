@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/mattn/go-isatty"
+	"github.com/muesli/termenv"
+)
+
+// Renderer holds the styles used by every formatter. A Renderer without
+// colour renders every style as plain text, so output piped into files and
+// logs carries no escape sequences.
+type Renderer struct {
+	lg *lipgloss.Renderer
+
+	success lipgloss.Style
+	failure lipgloss.Style
+	warning lipgloss.Style
+	info    lipgloss.Style
+	subtle  lipgloss.Style
+	header  lipgloss.Style
+	bold    lipgloss.Style
+	box     lipgloss.Style
+	label   lipgloss.Style
+	value   lipgloss.Style
+}
+
+// NewRenderer returns a Renderer for output written to out. Colour is used
+// only when ColorEnabled allows it.
+func NewRenderer(out *os.File, noColor bool) *Renderer {
+	lg := lipgloss.NewRenderer(out)
+	if !ColorEnabled(out, noColor) {
+		lg.SetColorProfile(termenv.Ascii)
+	}
+
+	return &Renderer{
+		lg: lg,
+
+		success: lg.NewStyle().Foreground(lipgloss.Color("10")),
+		failure: lg.NewStyle().Foreground(lipgloss.Color("9")),
+		warning: lg.NewStyle().Foreground(lipgloss.Color("11")),
+		info:    lg.NewStyle().Foreground(lipgloss.Color("12")),
+		subtle:  lg.NewStyle().Foreground(lipgloss.Color("241")),
+		header:  lg.NewStyle().Foreground(lipgloss.Color("245")),
+		bold:    lg.NewStyle().Bold(true),
+		box:     lg.NewStyle().Foreground(lipgloss.Color("240")),
+		label:   lg.NewStyle().Foreground(lipgloss.Color("243")),
+		value:   lg.NewStyle().Foreground(lipgloss.Color("252")),
+	}
+}
+
+// ColorEnabled reports whether output written to out may be coloured:
+// not with --no-color, a non-empty NO_COLOR, TERM=dumb, or when out is
+// not a terminal.
+func ColorEnabled(out *os.File, noColor bool) bool {
+	return colorEnabled(isatty.IsTerminal(out.Fd()) || isatty.IsCygwinTerminal(out.Fd()), noColor)
+}
+
+func colorEnabled(terminal, noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return terminal
+}
+
+// ColorProfile is the profile components such as the progress bar should
+// render with.
+func (r *Renderer) ColorProfile() termenv.Profile {
+	return r.lg.ColorProfile()
+}
+
+func (r *Renderer) NewStyle() lipgloss.Style {
+	return r.lg.NewStyle()
+}
+
+func (r *Renderer) FormatSummary(results []core.SiteResult) string {
+	counts := make(map[core.ResultStatus]int)
+	for _, result := range results {
+		counts[result.ResultStatus]++
+	}
+
+	count := func(status core.ResultStatus, style lipgloss.Style) string {
+		n := fmt.Sprintf("%d", counts[status])
+		if counts[status] == 0 {
+			return n
+		}
+		return style.Render(n)
+	}
+
+	var b strings.Builder
+	b.WriteString("\n" + strings.Repeat("=", 50) + "\n")
+	b.WriteString(r.bold.Render("Summary") + "\n")
+	b.WriteString(strings.Repeat("=", 50) + "\n")
+	b.WriteString(fmt.Sprintf("Total: %d\n", len(results)))
+	b.WriteString(fmt.Sprintf("Found: %s\n", count(core.ResultStatusFound, r.success)))
+	b.WriteString(fmt.Sprintf("Not Found: %d\n", counts[core.ResultStatusNotFound]))
+	b.WriteString(fmt.Sprintf("Errors: %s\n", count(core.ResultStatusError, r.failure)))
+	b.WriteString(fmt.Sprintf("Unknown: %s\n", count(core.ResultStatusUnknown, r.warning)))
+	b.WriteString(fmt.Sprintf("Ambiguous: %s\n", count(core.ResultStatusAmbiguous, r.warning)))
+	if counts[core.ResultStatusCancelled] > 0 {
+		b.WriteString(fmt.Sprintf("Cancelled: %d\n", counts[core.ResultStatusCancelled]))
+	}
+	b.WriteString(strings.Repeat("=", 50))
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+func TestColorEnabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		terminal bool
+		noColor  bool
+		env      map[string]string
+		want     bool
+	}{
+		{name: "terminal", terminal: true, want: true},
+		{name: "not a terminal", terminal: false, want: false},
+		{name: "--no-color", terminal: true, noColor: true, want: false},
+		{name: "NO_COLOR", terminal: true, env: map[string]string{"NO_COLOR": "1"}, want: false},
+		{name: "empty NO_COLOR", terminal: true, env: map[string]string{"NO_COLOR": ""}, want: true},
+		{name: "TERM=dumb", terminal: true, env: map[string]string{"TERM": "dumb"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", "")
+			t.Setenv("TERM", "xterm-256color")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if got := colorEnabled(tt.terminal, tt.noColor); got != tt.want {
+				t.Errorf("colorEnabled(%v, %v) = %v, want %v", tt.terminal, tt.noColor, got, tt.want)
+			}
+		})
+	}
+}
+
+// plainRenderer returns a Renderer for a regular file, as used when stdout
+// is redirected.
+func plainRenderer(t *testing.T) *Renderer {
+	t.Helper()
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm-256color")
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { out.Close() })
+
+	if ColorEnabled(out, false) {
+		t.Fatal("ColorEnabled() = true for a regular file")
+	}
+	return NewRenderer(out, false)
+}
+
+func TestRendererWithoutTerminal(t *testing.T) {
+	r := plainRenderer(t)
+
+	results := []core.SiteResult{
+		{SiteName: "GitHub", ResultStatus: core.ResultStatusFound, ResultURL: "https://github.com/alice",
+			Metadata: &core.ProfileMetadata{DisplayName: "Alice", IsVerified: true}},
+		{SiteName: "Reddit", ResultStatus: core.ResultStatusNotFound},
+		{SiteName: "Flaky", ResultStatus: core.ResultStatusError, Error: "connection reset"},
+		{SiteName: "Odd", ResultStatus: core.ResultStatusUnknown},
+		{SiteName: "Vague", ResultStatus: core.ResultStatusAmbiguous},
+	}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"found", r.FormatResult(results[0], false), "✓ FOUND | GitHub | https://github.com/alice\n  ├─ Name: Alice\n  └─ Verified: ✓ Yes"},
+		{"not found", r.FormatResult(results[1], false), "✗ NOT FOUND | Reddit"},
+		{"error with details", r.FormatResult(results[2], true), "! ERROR | Flaky | connection reset"},
+		{"unknown", r.FormatResult(results[3], false), "? UNKNOWN | Odd"},
+		{"ambiguous", r.FormatResult(results[4], false), "~ AMBIGUOUS | Vague"},
+		{"self-check", r.FormatSelfCheckResult(core.SelfCheckResult{
+			SiteName: "GitHub", OverallStatus: core.ResultStatusFound, Results: results[:1],
+		}, false), "✓ PASSED | GitHub | 1/1 known accounts found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+
+	summary := r.FormatSummary(results)
+	if strings.Contains(summary, "\x1b") {
+		t.Errorf("FormatSummary() contains escape sequences: %q", summary)
+	}
+	if !strings.Contains(summary, "Found: 1\n") || !strings.Contains(summary, "Errors: 1\n") {
+		t.Errorf("FormatSummary() = %q", summary)
+	}
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// reportExporter returns an exporter over a fixed set of results at a
+// fixed time, covering every section of the reports.
+func reportExporter() *Exporter {
+	at := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	results := []core.SiteResult{
+		{
+			SiteName: "GitHub", Category: "coding", Username: "alice",
+			ResultStatus: core.ResultStatusFound, ResultURL: "https://github.com/alice",
+			ResponseCode: 200, ResponsePath: "responses/alice/GitHub.html", Elapsed: 0.42, CreatedAt: at,
+			Metadata: &core.ProfileMetadata{
+				DisplayName:    "Alice Liddell",
+				Bio:            "Curiouser and curiouser — café owner",
+				Location:       "Oxford",
+				FollowerCount:  1200,
+				FollowingCount: 12,
+				IsVerified:     true,
+				AdditionalLinks: map[string]string{
+					"blog": "https://alice.example",
+				},
+				CustomFields: map[string]string{"repositories": "42", "company": "Wonderland"},
+			},
+		},
+		{
+			SiteName: "Mastodon", Category: "social", Username: "alice",
+			ResultStatus: core.ResultStatusFound, ResultURL: "https://mastodon.social/@alice",
+			ResponseCode: 200, Elapsed: 0.8, CreatedAt: at,
+		},
+		{
+			SiteName: "Reddit", Category: "social", Username: "alice",
+			ResultStatus: core.ResultStatusNotFound, ResultURL: "https://reddit.com/user/alice",
+			ResponseCode: 404, Elapsed: 0.3, CreatedAt: at,
+		},
+		{
+			SiteName: "Flaky", Category: "misc", Username: "alice",
+			ResultStatus: core.ResultStatusError, ResultURL: "https://flaky.example/alice",
+			Error: "Network error: connection reset by peer", Attempts: 3, CreatedAt: at,
+		},
+		{
+			SiteName: "Steam", Category: "gaming", Username: "bob",
+			ResultStatus: core.ResultStatusFound, ResultURL: "https://steamcommunity.com/id/bob",
+			ResponseCode: 200, Elapsed: 1.1, CreatedAt: at, FalsePositive: true,
+		},
+		{
+			SiteName: "Odd <Site>", Category: "misc", Username: "bob",
+			ResultStatus: core.ResultStatusUnknown, ResultURL: "https://odd.example/?u=bob&x=1",
+			ResponseCode: 503, Elapsed: 2.5, CreatedAt: at,
+		},
+		{
+			SiteName: "Slow", Category: "misc", Username: "bob",
+			ResultStatus: core.ResultStatusCancelled, Error: "Check cancelled: context canceled", CreatedAt: at,
+		},
+	}
+	return &Exporter{Results: results, Usernames: []string{"alice", "bob"}, Timestamp: at}
+}
+
+// checkGolden compares got with testdata/name, or rewrites the file when
+// the tests run with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		failed := filepath.Join(t.TempDir(), name)
+		os.WriteFile(failed, got, 0o644)
+		t.Errorf("output differs from %s; got %d bytes, want %d, written to %s (run go test -update if the change is intended)",
+			path, len(got), len(want), failed)
+	}
+}
+
+func TestWritePDFGolden(t *testing.T) {
+	var first, second bytes.Buffer
+	if err := reportExporter().WritePDF(&first); err != nil {
+		t.Fatal(err)
+	}
+	if err := reportExporter().WritePDF(&second); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Fatal("two renders of the same results differ")
+	}
+	checkGolden(t, "report.golden.pdf", first.Bytes())
+}
+
+func TestWriteHTMLGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := reportExporter().WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "report.golden.html", buf.Bytes())
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+// ResponseStore saves checked responses for --save-response as
+// <dir>/<username>/<site>.html, with the status line, headers, final URL
+// and fetch time in <site>.meta.json next to it. Paths only depend on the
+// username and site name, so a rerun overwrites the previous evidence.
+type ResponseStore struct {
+	dir      string
+	statuses map[core.ResultStatus]bool
+}
+
+type responseMeta struct {
+	Site         string            `json:"site"`
+	Username     string            `json:"username"`
+	ResultStatus core.ResultStatus `json:"result_status"`
+	URL          string            `json:"url"`
+	FinalURL     string            `json:"final_url"`
+	StatusLine   string            `json:"status_line"`
+	StatusCode   int               `json:"status_code"`
+	Headers      http.Header       `json:"headers"`
+	BodyBytes    int               `json:"body_bytes"`
+	FetchedAt    time.Time         `json:"fetched_at"`
+}
+
+// NewResponseStore returns a store writing below dir. Only results with
+// one of statuses are kept; an empty list keeps all of them.
+func NewResponseStore(dir string, statuses []core.ResultStatus) *ResponseStore {
+	store := &ResponseStore{dir: dir}
+	if len(statuses) > 0 {
+		store.statuses = make(map[core.ResultStatus]bool, len(statuses))
+		for _, status := range statuses {
+			store.statuses[status] = true
+		}
+	}
+	return store
+}
+
+func (s *ResponseStore) SaveResponse(result core.SiteResult, resp *core.HTTPResponse) (string, error) {
+	if s.statuses != nil && !s.statuses[result.ResultStatus] {
+		return "", nil
+	}
+
+	userDir := filepath.Join(s.dir, safeFileName(result.Username))
+	if err := os.MkdirAll(userDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create response directory: %w", err)
+	}
+
+	base := filepath.Join(userDir, safeFileName(result.SiteName))
+	bodyPath := base + ".html"
+
+	meta := responseMeta{
+		Site:         result.SiteName,
+		Username:     result.Username,
+		ResultStatus: result.ResultStatus,
+		URL:          result.ResultURL,
+		FinalURL:     resp.URL,
+		StatusLine:   strings.TrimSpace(resp.Proto + " " + resp.Status),
+		StatusCode:   resp.StatusCode,
+		Headers:      resp.Header,
+		BodyBytes:    len(resp.Body),
+		FetchedAt:    time.Now(),
+	}
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(bodyPath, []byte(resp.Body), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write response: %w", err)
+	}
+	if err := os.WriteFile(base+".meta.json", metaData, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write response metadata: %w", err)
+	}
+	return bodyPath, nil
+}
+
+// safeFileName maps a site or user name to a file name that is valid on
+// every platform. Names that had to be changed get a short hash of the
+// original, so "Site A" and "Site_A" do not share a file. The mapping is
+// deterministic so reruns hit the same files.
+func safeFileName(name string) string {
+	mapped := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, name)
+	mapped = strings.Trim(mapped, ".")
+	if mapped == name && mapped != "" {
+		return mapped
+	}
+	sum := sha256.Sum256([]byte(name))
+	if mapped == "" {
+		mapped = "_"
+	}
+	return mapped + "-" + hex.EncodeToString(sum[:4])
+}
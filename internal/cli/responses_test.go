@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+func TestSafeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"GitHub", "GitHub"},
+		{"dev.to", "dev.to"},
+		{"Site_A", "Site_A"},
+		{"Site A", "Site_A-"},
+		{"a/b", "a_b-"},
+		{"..", "_-"},
+		{"", "_-"},
+		{"日本", "__-"},
+	}
+	for _, tt := range tests {
+		got := safeFileName(tt.name)
+		if strings.HasSuffix(tt.want, "-") {
+			if !strings.HasPrefix(got, tt.want) || len(got) != len(tt.want)+8 {
+				t.Errorf("safeFileName(%q) = %q, want %q and an 8 character hash", tt.name, got, tt.want)
+			}
+		} else if got != tt.want {
+			t.Errorf("safeFileName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if safeFileName("Site A") != safeFileName("Site A") {
+		t.Error("mapping is not deterministic")
+	}
+	seen := make(map[string]string)
+	for _, name := range []string{"Site A", "Site_A", "Site/A", "Site:A", "Site.A", ".Site A"} {
+		file := safeFileName(name)
+		if other, ok := seen[file]; ok {
+			t.Errorf("%q and %q both map to %q", other, name, file)
+		}
+		seen[file] = name
+	}
+}
+
+func TestSaveResponse(t *testing.T) {
+	dir := t.TempDir()
+	store := NewResponseStore(dir, []core.ResultStatus{core.ResultStatusFound})
+	resp := &core.HTTPResponse{StatusCode: http.StatusOK, Status: "200 OK", Proto: "HTTP/1.1", Header: http.Header{}}
+
+	paths := make(map[string]bool)
+	for _, site := range []string{"Site A", "Site_A"} {
+		resp.Body = "profile on " + site
+		result := core.SiteResult{SiteName: site, Username: "alice", ResultStatus: core.ResultStatusFound}
+		path, err := store.SaveResponse(result, resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if paths[path] {
+			t.Fatalf("%q reused the path %s", site, path)
+		}
+		paths[path] = true
+
+		body, err := os.ReadFile(path)
+		if err != nil || string(body) != resp.Body {
+			t.Errorf("%s holds %q, %v; want %q", path, body, err, resp.Body)
+		}
+		if _, err := os.Stat(strings.TrimSuffix(path, ".html") + ".meta.json"); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Other statuses are not kept.
+	path, err := store.SaveResponse(core.SiteResult{SiteName: "x", Username: "alice", ResultStatus: core.ResultStatusNotFound}, resp)
+	if err != nil || path != "" {
+		t.Errorf("not_found result saved to %q, %v", path, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "alice", "x.html")); !os.IsNotExist(err) {
+		t.Errorf("not_found response written: %v", err)
+	}
+}
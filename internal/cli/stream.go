@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/pkg/events"
+)
+
+// EventStream writes the --json event stream (see package events). It is
+// safe for concurrent use.
+type EventStream struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	now     func() time.Time
+	failed  bool
+
+	interval     time.Duration
+	started      time.Time
+	lastProgress time.Time
+	total        int
+	completed    int
+	found        int
+}
+
+// NewEventStream returns a stream writing to w that emits a progress event
+// at most once per interval.
+func NewEventStream(w io.Writer, interval time.Duration) *EventStream {
+	return &EventStream{
+		encoder:  json.NewEncoder(w),
+		now:      time.Now,
+		interval: interval,
+	}
+}
+
+// RunStarted writes run_started and a site_loaded for each site. total is
+// the number of checks the run will make.
+func (s *EventStream) RunStarted(mode events.Mode, usernames []string, sites []core.Site, total int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.started = now
+	s.lastProgress = now
+	s.total = total
+
+	if err := s.write(events.RunStarted{
+		Header:    events.NewHeader(events.TypeRunStarted, now),
+		Version:   core.Version,
+		Mode:      mode,
+		Usernames: usernames,
+		Sites:     len(sites),
+		Total:     total,
+	}); err != nil {
+		return err
+	}
+	for _, site := range sites {
+		if err := s.write(events.SiteLoaded{
+			Header:   events.NewHeader(events.TypeSiteLoaded, now),
+			SiteName: site.Name,
+			Category: site.Category,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Result counts a finished check and writes its result event when include
+// is set, followed by a progress event when one is due.
+func (s *EventStream) Result(result core.SiteResult, include bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.completed++
+	if result.ResultStatus == core.ResultStatusFound {
+		s.found++
+	}
+
+	if include {
+		if err := s.write(resultEvent(result, now)); err != nil {
+			return err
+		}
+	}
+	if now.Sub(s.lastProgress) < s.interval && s.completed < s.total {
+		return nil
+	}
+	s.lastProgress = now
+	return s.write(events.Progress{
+		Header:    events.NewHeader(events.TypeProgress, now),
+		Completed: s.completed,
+		Total:     s.total,
+		Found:     s.found,
+	})
+}
+
+// RunFinished writes the final event. runErr is the reason the run was
+// aborted, if it was.
+func (s *EventStream) RunFinished(results []core.SiteResult, runErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	event := events.RunFinished{
+		Header:  events.NewHeader(events.TypeRunFinished, now),
+		Elapsed: now.Sub(s.started).Seconds(),
+		Summary: Summarize(results),
+	}
+	if runErr != nil {
+		event.Error = runErr.Error()
+	}
+	return s.write(event)
+}
+
+// write encodes one event. After a failed write the stream stops writing,
+// so a closed stdout is reported once rather than for every event.
+func (s *EventStream) write(event interface{}) error {
+	if s.failed {
+		return nil
+	}
+	if err := s.encoder.Encode(event); err != nil {
+		s.failed = true
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
+
+// resultEvent converts a result to its result event.
+func resultEvent(result core.SiteResult, at time.Time) events.Result {
+	event := events.Result{
+		Header:        events.NewHeader(events.TypeResult, at),
+		Username:      result.Username,
+		SiteName:      result.SiteName,
+		Category:      result.Category,
+		ResultStatus:  string(result.ResultStatus),
+		ResultURL:     result.ResultURL,
+		ResponseCode:  result.ResponseCode,
+		ResponsePath:  result.ResponsePath,
+		Elapsed:       result.Elapsed,
+		Attempts:      result.Attempts,
+		Proxy:         result.Proxy,
+		Error:         result.Error,
+		CreatedAt:     result.CreatedAt,
+		FalsePositive: result.FalsePositive,
+	}
+	if m := result.Metadata; m != nil {
+		metadata := events.ProfileMetadata(*m)
+		event.Metadata = &metadata
+	}
+	return event
+}
+
+// Summarize counts results by status.
+func Summarize(results []core.SiteResult) events.Summary {
+	summary := events.Summary{Total: len(results)}
+	for _, result := range results {
+		switch result.ResultStatus {
+		case core.ResultStatusFound:
+			summary.Found++
+		case core.ResultStatusNotFound:
+			summary.NotFound++
+		case core.ResultStatusError:
+			summary.Errors++
+		case core.ResultStatusUnknown:
+			summary.Unknown++
+		case core.ResultStatusAmbiguous:
+			summary.Ambiguous++
+		case core.ResultStatusNotValid:
+			summary.NotValid++
+		case core.ResultStatusCancelled:
+			summary.Cancelled++
+		}
+	}
+	return summary
+}
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gnomegl/usrsx/internal/client"
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/internal/schema"
+	"github.com/gnomegl/usrsx/pkg/events"
+)
+
+// streamScan runs a small scan through a proxy and writes its event
+// stream. The proxy answers for every site: usernames starting with
+// "found" have a profile.
+func streamScan(t *testing.T, mode events.Mode) []byte {
+	t.Helper()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/found") {
+			fmt.Fprint(w, "<title>profile</title>")
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "nope")
+	}))
+	t.Cleanup(proxy.Close)
+
+	httpClient, err := client.NewHTTPClient(client.ClientConfig{
+		Timeout:     5,
+		Impersonate: client.BrowserNone,
+		Proxy:       strings.Replace(proxy.URL, "http://", "http://user:secret@", 1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, missing := http.StatusOK, http.StatusNotFound
+	sites := make([]core.Site, 3)
+	for i := range sites {
+		sites[i] = core.Site{
+			Name:     fmt.Sprintf("site%d", i),
+			Category: "test",
+			URICheck: fmt.Sprintf("http://site%d.example/%s", i, core.AccountPlaceholder),
+			ECode:    &found,
+			EString:  "profile",
+			MCode:    &missing,
+			MString:  "nope",
+			Known:    []string{"found_known"},
+		}
+	}
+	usernames := []string{"found_alice", "bob"}
+
+	var buf bytes.Buffer
+	stream := NewEventStream(&buf, 0)
+	checker := core.NewChecker(httpClient, nil, core.CheckerConfig{MaxTasks: 2})
+	results := make(chan core.SiteResult)
+	var all []core.SiteResult
+
+	switch mode {
+	case events.ModeUsernames:
+		if err := stream.RunStarted(mode, usernames, sites, len(usernames)*len(sites)); err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			checker.CheckUsernames(context.Background(), usernames, sites, false, results)
+			close(results)
+		}()
+	case events.ModeSelfCheck:
+		if err := stream.RunStarted(mode, nil, sites, len(sites)); err != nil {
+			t.Fatal(err)
+		}
+		selfChecks := make(chan core.SelfCheckResult)
+		go func() {
+			checker.SelfCheck(context.Background(), sites, false, selfChecks)
+			close(selfChecks)
+		}()
+		go func() {
+			for selfCheck := range selfChecks {
+				for _, result := range selfCheck.Results {
+					results <- result
+				}
+			}
+			close(results)
+		}()
+	}
+
+	for result := range results {
+		all = append(all, result)
+		if err := stream.Result(result, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.RunFinished(all, context.Canceled); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEventStreamMatchesSchema(t *testing.T) {
+	validator, err := schema.Parse(events.Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var definitions struct {
+		Definitions map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal(events.Schema, &definitions); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []events.Mode{events.ModeUsernames, events.ModeSelfCheck} {
+		t.Run(string(mode), func(t *testing.T) {
+			seen := make(map[string]int)
+			found := 0
+			scanner := bufio.NewScanner(bytes.NewReader(streamScan(t, mode)))
+			for n := 1; scanner.Scan(); n++ {
+				line := scanner.Bytes()
+				doc, err := schema.Decode(line)
+				if err != nil {
+					t.Fatalf("line %d: %v", n, err)
+				}
+				for _, v := range validator.Validate(doc) {
+					t.Errorf("line %d: %s\n%s", n, v, line)
+				}
+
+				// Every field written must be declared, so the schema
+				// cannot fall behind the Go types.
+				var event map[string]json.RawMessage
+				if err := json.Unmarshal(line, &event); err != nil {
+					t.Fatal(err)
+				}
+				var typ string
+				json.Unmarshal(event["type"], &typ)
+				seen[typ]++
+				for field := range event {
+					if _, ok := definitions.Definitions[typ].Properties[field]; !ok {
+						t.Errorf("line %d: %s field %q is not in the schema", n, typ, field)
+					}
+				}
+
+				if typ == string(events.TypeResult) {
+					var result events.Result
+					json.Unmarshal(line, &result)
+					if result.ResultStatus == string(core.ResultStatusFound) {
+						found++
+					}
+					if !strings.HasPrefix(result.Proxy, "http://user:") || strings.Contains(result.Proxy, "secret") {
+						t.Errorf("line %d: proxy = %q, want the redacted proxy URL", n, result.Proxy)
+					}
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			results := 6
+			if mode == events.ModeSelfCheck {
+				results = 3
+			}
+			want := map[string]int{"run_started": 1, "site_loaded": 3, "result": results, "progress": results, "run_finished": 1}
+			for typ, n := range want {
+				if seen[typ] != n {
+					t.Errorf("%d %s events, want %d", seen[typ], typ, n)
+				}
+			}
+			if found != 3 {
+				t.Errorf("%d found results, want 3", found)
+			}
+		})
+	}
+}
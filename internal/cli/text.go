@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ExportText writes the same report as ExportMarkdown as column-aligned
+// plain text to path, or to stdout when path is empty.
+func (e *Exporter) ExportText(path string) error {
+	return exportTo(path, "text", e.WriteText)
+}
+
+// WriteText writes the --txt-output report to w.
+func (e *Exporter) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "usrsx Results")
+	fmt.Fprintf(tw, "Usernames:\t%s\n", textCell(strings.Join(e.Usernames, ", ")))
+	fmt.Fprintf(tw, "Timestamp:\t%s\n", e.Timestamp.Format(time.RFC3339))
+
+	fmt.Fprintln(tw, "\nSUMMARY")
+	for _, count := range e.statusCounts() {
+		fmt.Fprintf(tw, "  %s\t%d\n", count.Label, count.Count)
+	}
+
+	found := groupByUsername(e, isReportedHit)
+	if len(found.order) == 0 {
+		fmt.Fprintln(tw, "\nFOUND ACCOUNTS")
+		fmt.Fprintln(tw, "  No accounts were found.")
+	}
+	for _, username := range found.order {
+		results := found.results[username]
+		// A line without tabs ends the column block, so every username gets
+		// its own alignment.
+		fmt.Fprintf(tw, "\nFOUND ACCOUNTS: %s (%d)\n", textCell(username), len(results))
+		fmt.Fprintln(tw, "  SITE\tCATEGORY\tURL\tDISPLAY NAME\tFOLLOWERS")
+		for _, result := range results {
+			name, followers := profileColumns(result)
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n",
+				textCell(result.SiteName),
+				textCell(result.Category),
+				textCell(result.ResultURL),
+				textCell(name),
+				textCell(followers))
+		}
+	}
+
+	errors := groupByUsername(e, isError)
+	if len(errors.order) > 0 {
+		fmt.Fprintln(tw, "\nERRORS")
+		fmt.Fprintln(tw, "  USERNAME\tSITE\tERROR")
+		for _, username := range errors.order {
+			for _, result := range errors.results[username] {
+				fmt.Fprintf(tw, "  %s\t%s\t%s\n",
+					textCell(username),
+					textCell(result.SiteName),
+					textCell(result.Error))
+			}
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write text report: %w", err)
+	}
+	return nil
+}
+
+// textCell keeps s on one line without tabs so it stays in its column.
+func textCell(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
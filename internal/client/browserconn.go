@@ -0,0 +1,666 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+const (
+	// maxIdleConnsPerHost matches the six connections a browser keeps per
+	// host on HTTP/1.1.
+	maxIdleConnsPerHost = 6
+	idleConnTimeout     = 90 * time.Second
+
+	// maxConnAttempts bounds how often a request moves to another
+	// connection after finding its pooled one closed.
+	maxConnAttempts = 3
+
+	// h2DefaultMaxStreams applies until the server's SETTINGS arrive.
+	h2DefaultMaxStreams = 100
+
+	// h2WriteTimeout bounds every write to an HTTP/2 connection, so a
+	// peer that stops reading cannot hold up the read loop and the
+	// requests sharing the connection. The connection is closed when it
+	// expires.
+	h2WriteTimeout = 10 * time.Second
+)
+
+// errConnUnusable reports that a request was not processed because its
+// pooled connection had gone away, so it is safe to send on another one.
+var errConnUnusable = errors.New("connection no longer usable")
+
+// h1Conn is an HTTP/1.1 connection that can be kept alive between requests.
+type h1Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// absolute is set for connections to an HTTP proxy, which expects an
+	// absolute request URI rather than a tunnel.
+	absolute bool
+	reused   bool
+	idleAt   time.Time
+}
+
+// connPool holds the connections of one browserTransport, keyed by target
+// host. A transport serves a single proxy, so connections are shared per
+// host and proxy.
+type connPool struct {
+	mu   sync.Mutex
+	idle map[string][]*h1Conn
+	h2   map[string][]*h2Conn
+
+	// dialing holds a channel for each https key with a dial in progress.
+	// Until the first connection reveals whether the host speaks h2, other
+	// requests wait for it rather than open connections of their own.
+	dialing map[string]chan struct{}
+	h1Only  map[string]bool
+}
+
+func (p *connPool) takeIdle(key string, now time.Time) *h1Conn {
+	conns := p.idle[key]
+	for len(conns) > 0 {
+		pc := conns[len(conns)-1]
+		conns = conns[:len(conns)-1]
+		if now.Sub(pc.idleAt) < idleConnTimeout {
+			p.idle[key] = conns
+			pc.reused = true
+			return pc
+		}
+		pc.conn.Close()
+	}
+	delete(p.idle, key)
+	return nil
+}
+
+func (p *connPool) putIdle(key string, pc *h1Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.idle[key]) >= maxIdleConnsPerHost {
+		pc.conn.Close()
+		return
+	}
+	if p.idle == nil {
+		p.idle = make(map[string][]*h1Conn)
+	}
+	pc.idleAt = time.Now()
+	p.idle[key] = append(p.idle[key], pc)
+}
+
+func (p *connPool) addH2(key string, cc *h2Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.h2 == nil {
+		p.h2 = make(map[string][]*h2Conn)
+	}
+	p.h2[key] = append(p.h2[key], cc)
+}
+
+func (p *connPool) removeH2(cc *h2Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conns := p.h2[cc.key]
+	for i, c := range conns {
+		if c == cc {
+			p.h2[cc.key] = append(conns[:i:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(p.h2[cc.key]) == 0 {
+		delete(p.h2, cc.key)
+	}
+}
+
+// CloseIdleConnections closes the kept-alive HTTP/1.1 connections and the
+// HTTP/2 connections with no request in flight.
+func (t *browserTransport) CloseIdleConnections() {
+	t.conns.mu.Lock()
+	idle := t.conns.idle
+	t.conns.idle = nil
+	var h2conns []*h2Conn
+	for _, conns := range t.conns.h2 {
+		h2conns = append(h2conns, conns...)
+	}
+	t.conns.mu.Unlock()
+
+	for _, conns := range idle {
+		for _, pc := range conns {
+			pc.conn.Close()
+		}
+	}
+	for _, cc := range h2conns {
+		cc.closeIfIdle()
+	}
+}
+
+// connKey identifies the connections a request to target may share.
+func (t *browserTransport) connKey(target *url.URL) string {
+	if target.Scheme == "http" && t.httpProxy() {
+		return "proxy"
+	}
+	return target.Scheme + "://" + hostPort(target)
+}
+
+// getConn returns a connection for target: a shared HTTP/2 connection with
+// room for another stream, else an idle HTTP/1.1 connection, else a new
+// one.
+func (t *browserTransport) getConn(ctx context.Context, key string, target *url.URL) (*h2Conn, *h1Conn, error) {
+	for {
+		t.conns.mu.Lock()
+		for _, cc := range t.conns.h2[key] {
+			if cc.available() {
+				t.conns.mu.Unlock()
+				return cc, nil, nil
+			}
+		}
+		if pc := t.conns.takeIdle(key, time.Now()); pc != nil {
+			t.conns.mu.Unlock()
+			return nil, pc, nil
+		}
+
+		var done chan struct{}
+		if target.Scheme == "https" && !t.conns.h1Only[key] {
+			if wait, ok := t.conns.dialing[key]; ok {
+				t.conns.mu.Unlock()
+				select {
+				case <-wait:
+					continue
+				case <-ctx.Done():
+					return nil, nil, ctx.Err()
+				}
+			}
+			done = make(chan struct{})
+			if t.conns.dialing == nil {
+				t.conns.dialing = make(map[string]chan struct{})
+			}
+			t.conns.dialing[key] = done
+		}
+		t.conns.mu.Unlock()
+
+		cc, pc, err := t.dialConn(ctx, key, target)
+		if done != nil {
+			t.conns.mu.Lock()
+			delete(t.conns.dialing, key)
+			t.conns.mu.Unlock()
+			close(done)
+		}
+		return cc, pc, err
+	}
+}
+
+// dialConn opens a new connection to target and, for https, completes the
+// browser's TLS handshake. A connection that negotiates h2 is added to the
+// pool straight away so that other requests can share it.
+func (t *browserTransport) dialConn(ctx context.Context, key string, target *url.URL) (*h2Conn, *h1Conn, error) {
+	conn, absolute, err := t.dial(ctx, target)
+	if err != nil {
+		return nil, nil, err
+	}
+	if target.Scheme != "https" {
+		return nil, &h1Conn{conn: conn, reader: bufio.NewReader(conn), absolute: absolute}, nil
+	}
+
+	tlsConn := utls.UClient(conn, &utls.Config{
+		ServerName:         target.Hostname(),
+		InsecureSkipVerify: !t.verifySSL,
+	}, t.profile.ClientHello)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	if tlsConn.ConnectionState().NegotiatedProtocol != http2.NextProtoTLS {
+		t.conns.mu.Lock()
+		if t.conns.h1Only == nil {
+			t.conns.h1Only = make(map[string]bool)
+		}
+		t.conns.h1Only[key] = true
+		t.conns.mu.Unlock()
+		return nil, &h1Conn{conn: tlsConn, reader: bufio.NewReader(tlsConn)}, nil
+	}
+
+	cc, err := t.newH2Conn(ctx, key, tlsConn)
+	if err != nil {
+		tlsConn.Close()
+		return nil, nil, err
+	}
+	t.conns.addH2(key, cc)
+	return cc, nil, nil
+}
+
+// isStaleConn reports errors that mean a kept-alive connection was closed
+// by the server, typically before it read the request.
+func isStaleConn(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// staleConnError marks err as safe to retry on another connection when it
+// means the connection was closed under the request.
+func staleConnError(err error) error {
+	if isStaleConn(err) {
+		return fmt.Errorf("%w: %w", errConnUnusable, err)
+	}
+	return err
+}
+
+// h2Conn is an HTTP/2 connection shared by concurrent requests. A single
+// goroutine reads frames and hands each stream its response.
+type h2Conn struct {
+	t    *browserTransport
+	key  string
+	conn net.Conn
+
+	// wmu serialises frame writes and the HPACK encoder, whose state
+	// depends on the order header blocks go out. It is a channel so that
+	// waiting for it can be abandoned with the request's context.
+	wmu     chan struct{}
+	writer  *bufio.Writer
+	framer  *http2.Framer
+	block   bytes.Buffer
+	encoder *hpack.Encoder
+	// writeTimeout is h2WriteTimeout, shortened by tests.
+	writeTimeout time.Duration
+
+	mu         sync.Mutex
+	streams    map[uint32]*h2Stream
+	nextID     uint32
+	maxStreams uint32
+	goAway     bool
+	err        error
+}
+
+type h2Stream struct {
+	resp *http.Response
+	data bytes.Buffer
+	err  error
+	done chan struct{}
+}
+
+// newH2Conn sends the profile's connection preface, SETTINGS and
+// WINDOW_UPDATE on conn and starts reading frames from it.
+func (t *browserTransport) newH2Conn(ctx context.Context, key string, conn net.Conn) (*h2Conn, error) {
+	cc := &h2Conn{
+		t:            t,
+		key:          key,
+		conn:         conn,
+		wmu:          make(chan struct{}, 1),
+		writeTimeout: h2WriteTimeout,
+		writer:       bufio.NewWriter(conn),
+		streams:      make(map[uint32]*h2Stream),
+		nextID:       1,
+		maxStreams:   h2DefaultMaxStreams,
+	}
+	cc.framer = http2.NewFramer(cc.writer, conn)
+	cc.framer.ReadMetaHeaders = hpack.NewDecoder(t.headerTableSize(), nil)
+	cc.encoder = hpack.NewEncoder(&cc.block)
+
+	err := cc.write(ctx, func() {
+		cc.writer.WriteString(http2.ClientPreface)
+		cc.framer.WriteSettings(t.profile.H2Settings...)
+		if t.profile.H2WindowUpdate > 0 {
+			cc.framer.WriteWindowUpdate(0, t.profile.H2WindowUpdate)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go cc.readLoop()
+	return cc, nil
+}
+
+// lockWrite takes wmu, giving up when ctx is done first.
+func (cc *h2Conn) lockWrite(ctx context.Context) error {
+	select {
+	case cc.wmu <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (cc *h2Conn) unlockWrite() {
+	<-cc.wmu
+}
+
+// flush sends the frames written since the last flush. The caller holds
+// wmu. The write deadline is h2WriteTimeout from now, or ctx's deadline
+// when that is sooner; a failed write closes the connection.
+func (cc *h2Conn) flush(ctx context.Context) error {
+	deadline := time.Now().Add(cc.writeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	cc.conn.SetWriteDeadline(deadline)
+	err := cc.writer.Flush()
+	if err != nil {
+		err = staleConnError(err)
+		cc.close(err)
+	}
+	return err
+}
+
+// write runs fn, which writes frames, and flushes them.
+func (cc *h2Conn) write(ctx context.Context, fn func()) error {
+	if err := cc.lockWrite(ctx); err != nil {
+		return err
+	}
+	defer cc.unlockWrite()
+
+	fn()
+	return cc.flush(ctx)
+}
+
+func (cc *h2Conn) available() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.availableLocked()
+}
+
+func (cc *h2Conn) availableLocked() bool {
+	return cc.err == nil && !cc.goAway && uint32(len(cc.streams)) < cc.maxStreams && cc.nextID < 1<<31
+}
+
+func (cc *h2Conn) roundTrip(ctx context.Context, req *http.Request, body []byte) (*http.Response, error) {
+	if len(body) > h2MaxRequestBody {
+		return nil, fmt.Errorf("request body of %d bytes exceeds the initial HTTP/2 window", len(body))
+	}
+
+	headers, err := cc.t.orderedHeaders(req, body, true)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &h2Stream{done: make(chan struct{})}
+
+	// Stream IDs must go out in increasing order, so the ID is taken
+	// under the write lock.
+	if err := cc.lockWrite(ctx); err != nil {
+		return nil, err
+	}
+	cc.mu.Lock()
+	if !cc.availableLocked() {
+		cc.mu.Unlock()
+		cc.unlockWrite()
+		return nil, errConnUnusable
+	}
+	streamID := cc.nextID
+	cc.nextID += 2
+	cc.streams[streamID] = stream
+	cc.mu.Unlock()
+
+	cc.writeRequest(streamID, req, headers, body)
+	err = cc.flush(ctx)
+	cc.unlockWrite()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-stream.done:
+	case <-ctx.Done():
+		cc.cancel(streamID)
+		return nil, ctx.Err()
+	}
+	if stream.err != nil {
+		return nil, stream.err
+	}
+	if stream.resp == nil {
+		return nil, errors.New("HTTP/2 stream ended without a response")
+	}
+	stream.resp.Request = req
+	return stream.resp, decodeBody(stream.resp, stream.data.Bytes())
+}
+
+// writeRequest writes the HEADERS, CONTINUATION and DATA frames of a
+// request. The caller holds wmu.
+func (cc *h2Conn) writeRequest(streamID uint32, req *http.Request, headers [][2]string, body []byte) {
+	profile := cc.t.profile
+
+	cc.block.Reset()
+	for _, name := range profile.PseudoHeaderOrder {
+		cc.encoder.WriteField(hpack.HeaderField{Name: name, Value: pseudoHeader(req, name)})
+	}
+	for _, header := range headers {
+		cc.encoder.WriteField(hpack.HeaderField{Name: strings.ToLower(header[0]), Value: header[1]})
+	}
+
+	fragment := cc.block.Bytes()
+	first := fragment[:min(len(fragment), h2MaxFrameSize)]
+	cc.framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      streamID,
+		BlockFragment: first,
+		EndStream:     len(body) == 0,
+		EndHeaders:    len(first) == len(fragment),
+		Priority:      profile.H2Priority,
+	})
+	for rest := fragment[len(first):]; len(rest) > 0; {
+		chunk := rest[:min(len(rest), h2MaxFrameSize)]
+		rest = rest[len(chunk):]
+		cc.framer.WriteContinuation(streamID, len(rest) == 0, chunk)
+	}
+	for rest := body; len(rest) > 0; {
+		chunk := rest[:min(len(rest), h2MaxFrameSize)]
+		rest = rest[len(chunk):]
+		cc.framer.WriteData(streamID, len(rest) == 0, chunk)
+	}
+}
+
+// cancel abandons a stream whose request was cancelled and tells the
+// server to stop sending it.
+func (cc *h2Conn) cancel(streamID uint32) {
+	cc.mu.Lock()
+	_, open := cc.streams[streamID]
+	delete(cc.streams, streamID)
+	drained := cc.goAway && len(cc.streams) == 0
+	cc.mu.Unlock()
+
+	if open {
+		cc.write(context.Background(), func() { cc.framer.WriteRSTStream(streamID, http2.ErrCodeCancel) })
+	}
+	if drained {
+		cc.close(errConnUnusable)
+	}
+}
+
+func (cc *h2Conn) stream(streamID uint32) *h2Stream {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.streams[streamID]
+}
+
+// endStream completes a stream with err, or successfully when err is nil.
+func (cc *h2Conn) endStream(streamID uint32, err error) {
+	cc.mu.Lock()
+	stream, open := cc.streams[streamID]
+	delete(cc.streams, streamID)
+	drained := cc.goAway && len(cc.streams) == 0
+	cc.mu.Unlock()
+
+	if open {
+		stream.err = err
+		close(stream.done)
+	}
+	if drained {
+		cc.close(errConnUnusable)
+	}
+}
+
+// close shuts the connection down and fails every stream still open on
+// it with err.
+func (cc *h2Conn) close(err error) {
+	cc.mu.Lock()
+	if cc.err != nil {
+		cc.mu.Unlock()
+		return
+	}
+	cc.err = err
+	streams := cc.streams
+	cc.streams = nil
+	cc.mu.Unlock()
+
+	cc.t.conns.removeH2(cc)
+	cc.conn.Close()
+	for _, stream := range streams {
+		stream.err = err
+		close(stream.done)
+	}
+}
+
+func (cc *h2Conn) closeIfIdle() {
+	cc.mu.Lock()
+	idle := len(cc.streams) == 0
+	cc.mu.Unlock()
+	if idle {
+		cc.close(errConnUnusable)
+	}
+}
+
+// goAwayReceived stops new streams on the connection and fails the ones
+// the server will not process, which are safe to retry elsewhere.
+func (cc *h2Conn) goAwayReceived(lastStreamID uint32) {
+	cc.mu.Lock()
+	cc.goAway = true
+	var unprocessed []uint32
+	for id := range cc.streams {
+		if id > lastStreamID {
+			unprocessed = append(unprocessed, id)
+		}
+	}
+	drained := len(cc.streams) == len(unprocessed)
+	cc.mu.Unlock()
+
+	for _, id := range unprocessed {
+		cc.endStream(id, errConnUnusable)
+	}
+	if drained {
+		cc.close(errConnUnusable)
+	}
+}
+
+func (cc *h2Conn) readLoop() {
+	for {
+		frame, err := cc.framer.ReadFrame()
+		if err != nil {
+			var streamErr http2.StreamError
+			if errors.As(err, &streamErr) {
+				cc.endStream(streamErr.StreamID, streamErr)
+				continue
+			}
+			cc.close(staleConnError(err))
+			return
+		}
+
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if f.IsAck() {
+				continue
+			}
+			if n, ok := f.Value(http2.SettingMaxConcurrentStreams); ok {
+				cc.mu.Lock()
+				cc.maxStreams = n
+				cc.mu.Unlock()
+			}
+			err = cc.write(context.Background(), func() { cc.framer.WriteSettingsAck() })
+
+		case *http2.PingFrame:
+			if !f.IsAck() {
+				err = cc.write(context.Background(), func() { cc.framer.WritePing(true, f.Data) })
+			}
+
+		case *http2.GoAwayFrame:
+			cc.goAwayReceived(f.LastStreamID)
+
+		case *http2.RSTStreamFrame:
+			cc.endStream(f.StreamID, fmt.Errorf("stream reset by server: %v", f.ErrCode))
+
+		case *http2.MetaHeadersFrame:
+			stream := cc.stream(f.StreamID)
+			if stream == nil {
+				continue
+			}
+			if stream.resp == nil {
+				code, err := strconv.Atoi(f.PseudoValue("status"))
+				if err != nil {
+					cc.endStream(f.StreamID, fmt.Errorf("malformed response status %q", f.PseudoValue("status")))
+					continue
+				}
+				if code >= 100 && code < 200 {
+					continue
+				}
+				stream.resp = &http.Response{
+					Status:     fmt.Sprintf("%d %s", code, http.StatusText(code)),
+					StatusCode: code,
+					Proto:      "HTTP/2.0",
+					ProtoMajor: 2,
+					Header:     make(http.Header),
+				}
+				for _, field := range f.RegularFields() {
+					stream.resp.Header.Add(http.CanonicalHeaderKey(field.Name), field.Value)
+				}
+			}
+			if f.StreamEnded() {
+				cc.endStream(f.StreamID, nil)
+			}
+
+		case *http2.DataFrame:
+			// The connection window is returned even for streams that
+			// were cancelled, or the connection would stall.
+			n := f.Header().Length
+			stream := cc.stream(f.StreamID)
+			reset := false
+			if stream != nil && stream.resp == nil {
+				// DATA before the response HEADERS.
+				cc.endStream(f.StreamID, http2.StreamError{StreamID: f.StreamID, Code: http2.ErrCodeProtocol,
+					Cause: errors.New("DATA frame before response headers")})
+				stream, reset = nil, true
+			}
+			ended := f.StreamEnded()
+			if stream != nil {
+				stream.data.Write(f.Data())
+				// The response is complete whatever happens to the
+				// window update below.
+				if ended {
+					cc.endStream(f.StreamID, nil)
+				}
+			}
+			if n > 0 || reset {
+				err = cc.write(context.Background(), func() {
+					if n > 0 {
+						cc.framer.WriteWindowUpdate(0, n)
+					}
+					if stream != nil && !ended {
+						cc.framer.WriteWindowUpdate(f.StreamID, n)
+					}
+					if reset {
+						cc.framer.WriteRSTStream(f.StreamID, http2.ErrCodeProtocol)
+					}
+				})
+			}
+		}
+
+		if err != nil {
+			cc.close(err)
+			return
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const proxyProbeConcurrency = 32
+
+// proxyHealth tracks the outcome of every request sent through a proxy.
+// Real checks feed it through HTTPClient.Do and the optional pre-flight
+// probe feeds it before the scan starts.
+type proxyHealth struct {
+	mu               sync.Mutex
+	successes        int
+	failures         int
+	consecutive      int
+	quarantines      int
+	lastFailure      time.Time
+	lastError        string
+	quarantinedUntil time.Time
+	latencies        []time.Duration
+}
+
+func (h *proxyHealth) recordSuccess(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.successes++
+	h.consecutive = 0
+	h.latencies = append(h.latencies, latency)
+}
+
+func (h *proxyHealth) recordFailure(now time.Time, err error, maxFailures int, cooldown time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.failures++
+	h.consecutive++
+	h.lastFailure = now
+	if err != nil {
+		h.lastError = err.Error()
+	}
+
+	if maxFailures > 0 && h.consecutive >= maxFailures {
+		h.quarantines++
+		h.quarantinedUntil = now.Add(cooldown)
+		// Coming back from quarantine is probation: one more failure
+		// sends the proxy straight back.
+		h.consecutive = maxFailures - 1
+	}
+}
+
+func (h *proxyHealth) quarantined(now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return now.Before(h.quarantinedUntil)
+}
+
+func (h *proxyHealth) failedAt() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastFailure
+}
+
+func (h *proxyHealth) releasedAt() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.quarantinedUntil
+}
+
+func (h *proxyHealth) medianLatency() time.Duration {
+	h.mu.Lock()
+	latencies := append([]time.Duration(nil), h.latencies...)
+	h.mu.Unlock()
+
+	if len(latencies) == 0 {
+		return 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	mid := len(latencies) / 2
+	if len(latencies)%2 == 0 {
+		return (latencies[mid-1] + latencies[mid]) / 2
+	}
+	return latencies[mid]
+}
+
+type ProxyStatus string
+
+const (
+	ProxyStatusActive      ProxyStatus = "active"
+	ProxyStatusQuarantined ProxyStatus = "quarantined"
+	ProxyStatusEvicted     ProxyStatus = "evicted"
+)
+
+type ProxyStats struct {
+	Proxy           string      `json:"proxy"`
+	Status          ProxyStatus `json:"status"`
+	Successes       int         `json:"successes"`
+	Failures        int         `json:"failures"`
+	Quarantines     int         `json:"quarantines"`
+	MedianLatencyMs float64     `json:"median_latency_ms"`
+	LastError       string      `json:"last_error,omitempty"`
+}
+
+// Report returns per-proxy statistics for the run, active proxies first.
+func (pp *ProxyPool) Report() []ProxyStats {
+	pp.mu.Lock()
+	active := append([]*Proxy(nil), pp.proxies...)
+	evicted := append([]*Proxy(nil), pp.evicted...)
+	pp.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]ProxyStats, 0, len(active)+len(evicted))
+	for _, p := range active {
+		status := ProxyStatusActive
+		if p.health.quarantined(now) {
+			status = ProxyStatusQuarantined
+		}
+		stats = append(stats, p.stats(status))
+	}
+	for _, p := range evicted {
+		stats = append(stats, p.stats(ProxyStatusEvicted))
+	}
+	return stats
+}
+
+func (p *Proxy) stats(status ProxyStatus) ProxyStats {
+	median := p.health.medianLatency()
+
+	p.health.mu.Lock()
+	defer p.health.mu.Unlock()
+
+	return ProxyStats{
+		Proxy:           p.String(),
+		Status:          status,
+		Successes:       p.health.successes,
+		Failures:        p.health.failures,
+		Quarantines:     p.health.quarantines,
+		MedianLatencyMs: float64(median) / float64(time.Millisecond),
+		LastError:       p.health.lastError,
+	}
+}
+
+// Probe sends a request to probeURL through every proxy and evicts the ones
+// that fail. It returns an error when no proxy survives.
+func (pp *ProxyPool) Probe(ctx context.Context, probeURL, userAgent string, timeout time.Duration) error {
+	pp.mu.Lock()
+	proxies := append([]*Proxy(nil), pp.proxies...)
+	pp.mu.Unlock()
+
+	if len(proxies) == 0 {
+		return nil
+	}
+
+	sem := make(chan struct{}, proxyProbeConcurrency)
+	var wg sync.WaitGroup
+	for _, p := range proxies {
+		wg.Add(1)
+		go func(p *Proxy) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			latency, err := probeProxy(ctx, p, probeURL, userAgent, timeout)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				pp.recordFailure(p, err)
+				pp.evict(p)
+				return
+			}
+			pp.recordSuccess(p, latency)
+		}(p)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if pp.Len() == 0 {
+		return fmt.Errorf("all %d proxies failed the health check against %s", len(proxies), probeURL)
+	}
+	return nil
+}
+
+func probeProxy(ctx context.Context, p *Proxy, probeURL, userAgent string, timeout time.Duration) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	httpClient := &http.Client{Transport: p.transport, Timeout: timeout}
+
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if err := proxyResponseError(resp); err != nil {
+		return 0, err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return 0, fmt.Errorf("probe returned %s", resp.Status)
+	}
+	return time.Since(start), nil
+}
+
+// proxyResponseError reports responses that come from the proxy itself
+// rather than the target site.
+func proxyResponseError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		return fmt.Errorf("proxy authentication required")
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var errRefused = errors.New("connection refused")
+
+func TestQuarantine(t *testing.T) {
+	pool := newTestPool(t, ProxyRoundRobin, 2)
+	bad, good := pool.proxies[0], pool.proxies[1]
+
+	pool.recordFailure(bad, errRefused)
+	if bad.health.quarantined(time.Now()) {
+		t.Fatal("quarantined after one failure, MaxFailures is 2")
+	}
+	pool.recordFailure(bad, errRefused)
+	if !bad.health.quarantined(time.Now()) {
+		t.Fatal("not quarantined after MaxFailures consecutive failures")
+	}
+
+	for i := 0; i < 4; i++ {
+		if p := pool.Select(nil); p != good {
+			t.Fatalf("selected %v while it is quarantined", hosts(p))
+		}
+	}
+
+	// Once the cooldown is over the proxy is on probation: a single
+	// failure sends it back.
+	bad.health.quarantinedUntil = time.Now().Add(-time.Second)
+	if bad.health.quarantined(time.Now()) {
+		t.Fatal("still quarantined after the cooldown")
+	}
+	pool.recordFailure(bad, errRefused)
+	if !bad.health.quarantined(time.Now()) {
+		t.Error("a failure on probation did not quarantine the proxy again")
+	}
+	if bad.health.quarantines != 2 {
+		t.Errorf("quarantines = %d, want 2", bad.health.quarantines)
+	}
+}
+
+func TestSuccessResetsFailures(t *testing.T) {
+	pool := newTestPool(t, ProxyRoundRobin, 1)
+	p := pool.proxies[0]
+
+	pool.recordFailure(p, errRefused)
+	pool.recordSuccess(p, 10*time.Millisecond)
+	pool.recordFailure(p, errRefused)
+	if p.health.quarantined(time.Now()) {
+		t.Error("failures separated by a success quarantined the proxy")
+	}
+}
+
+func TestSelectAllQuarantined(t *testing.T) {
+	pool := newTestPool(t, ProxyRoundRobin, 3)
+	now := time.Now()
+	pool.proxies[0].health.quarantinedUntil = now.Add(3 * time.Minute)
+	pool.proxies[1].health.quarantinedUntil = now.Add(time.Minute)
+	pool.proxies[2].health.quarantinedUntil = now.Add(2 * time.Minute)
+
+	// Rather than stall the scan, use the proxy released soonest.
+	if p := pool.Select(nil); p != pool.proxies[1] {
+		t.Errorf("got %v, want p1", hosts(p))
+	}
+}
+
+func TestEvict(t *testing.T) {
+	pool := newTestPool(t, ProxyRoundRobin, 3)
+	evicted := pool.proxies[1]
+	pool.evict(evicted)
+
+	if pool.Len() != 2 || pool.configured() != 3 {
+		t.Fatalf("Len = %d, configured = %d; want 2 and 3", pool.Len(), pool.configured())
+	}
+	for i := 0; i < 6; i++ {
+		if p := pool.Select(nil); p == evicted {
+			t.Fatal("selected an evicted proxy")
+		}
+	}
+
+	// Evicting twice is harmless.
+	pool.evict(evicted)
+	if pool.Len() != 2 {
+		t.Errorf("Len = %d after a second evict, want 2", pool.Len())
+	}
+}
+
+func TestReport(t *testing.T) {
+	pool := newTestPool(t, ProxyRoundRobin, 3)
+	p0, p1, p2 := pool.proxies[0], pool.proxies[1], pool.proxies[2]
+
+	pool.recordSuccess(p0, 10*time.Millisecond)
+	pool.recordSuccess(p0, 30*time.Millisecond)
+	pool.recordFailure(p1, errRefused)
+	pool.recordFailure(p1, errRefused)
+	pool.evict(p2)
+
+	report := pool.Report()
+	want := []struct {
+		proxy    string
+		status   ProxyStatus
+		failures int
+		median   float64
+	}{
+		{"http://p0:8080", ProxyStatusActive, 0, 20},
+		{"http://p1:8080", ProxyStatusQuarantined, 2, 0},
+		{"http://p2:8080", ProxyStatusEvicted, 0, 0},
+	}
+	if len(report) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(report), len(want))
+	}
+	for i, w := range want {
+		got := report[i]
+		if got.Proxy != w.proxy || got.Status != w.status || got.Failures != w.failures || got.MedianLatencyMs != w.median {
+			t.Errorf("entry %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if report[1].LastError != errRefused.Error() {
+		t.Errorf("LastError = %q", report[1].LastError)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	// An HTTP proxy receives the absolute probe URL; answering it is
+	// enough to pass.
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer working.Close()
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusProxyAuthRequired)
+	}))
+	defer auth.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := "http://" + listener.Addr().String()
+	listener.Close()
+
+	pool, err := NewProxyPool([]string{working.URL, auth.URL, dead}, ProxyPoolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Probe(context.Background(), "http://probe.test/", "test", 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if pool.Len() != 1 || pool.Select(nil).String() != working.URL {
+		t.Errorf("after probing, pool holds %v, want only %s", hosts(pool.proxies...), working.URL)
+	}
+
+	pool, err = NewProxyPool([]string{dead}, ProxyPoolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Probe(context.Background(), "http://probe.test/", "test", 2*time.Second); err == nil {
+		t.Error("no error when every proxy failed")
+	}
+}
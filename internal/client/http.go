@@ -1,19 +1,12 @@
 package client
 
 import (
-	"bufio"
-	"crypto/tls"
+	"context"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
-	"net/url"
-	"os"
 	"strings"
-	"sync"
 	"time"
-
-	"golang.org/x/net/proxy"
 )
 
 type BrowserImpersonation string
@@ -28,85 +21,12 @@ const (
 	BrowserFirefox       BrowserImpersonation = "firefox"
 )
 
-var UserAgents = map[string]string{
-	"chrome":         "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-	"chrome_android": "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
-	"firefox":        "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0",
-	"safari":         "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_1) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
-	"safari_ios":     "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
-	"edge":           "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
-}
-
-type ProxyRotator struct {
-	proxies []string
-	current int
-	mu      sync.Mutex
-}
-
-func NewProxyRotator(proxies []string) *ProxyRotator {
-	return &ProxyRotator{
-		proxies: proxies,
-		current: 0,
-	}
-}
-
-func LoadProxiesFromFile(filename string) ([]string, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open proxies file: %w", err)
-	}
-	defer file.Close()
-
-	var proxies []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		proxies = append(proxies, "socks5://"+line)
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read proxies file: %w", err)
-	}
-
-	if len(proxies) == 0 {
-		return nil, fmt.Errorf("no valid proxies found in file")
-	}
-
-	return proxies, nil
-}
-
-func (pr *ProxyRotator) Next() string {
-	pr.mu.Lock()
-	defer pr.mu.Unlock()
-
-	if len(pr.proxies) == 0 {
-		return ""
-	}
-
-	proxy := pr.proxies[pr.current]
-	pr.current = (pr.current + 1) % len(pr.proxies)
-	return proxy
-}
-
-func (pr *ProxyRotator) Random() string {
-	pr.mu.Lock()
-	defer pr.mu.Unlock()
-
-	if len(pr.proxies) == 0 {
-		return ""
-	}
-
-	return pr.proxies[rand.Intn(len(pr.proxies))]
-}
-
 type HTTPClient struct {
-	client        *http.Client
+	direct        http.RoundTripper
+	base          *http.Client
+	proxies       *ProxyPool
+	profile       *BrowserProfile
 	userAgent     string
-	proxyRotator  *ProxyRotator
-	singleProxy   string
 	timeout       time.Duration
 	verifySSL     bool
 	allowRedirect bool
@@ -119,150 +39,204 @@ type ClientConfig struct {
 	Impersonate   BrowserImpersonation
 	Proxy         string
 	ProxyFile     string
+	ProxyStrategy ProxyStrategy
+
+	ProxyMaxFailures int
+	ProxyCooldown    time.Duration
+
+	// Client, when set, sends the requests that do not go through a proxy
+	// as it is: Timeout, VerifySSL, AllowRedirect and the TLS fingerprint
+	// of Impersonate do not apply to them. Its headers are still sent, and
+	// responses compressed because of its Accept-Encoding are decoded.
+	Client *http.Client
+}
+
+// Request describes a single outgoing request. A nil Proxy means the client
+// picks one from its pool, or connects directly when no proxies are set.
+type Request struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    string
+	Proxy   *Proxy
 }
 
 func NewHTTPClient(config ClientConfig) (*HTTPClient, error) {
 	timeout := time.Duration(config.Timeout) * time.Second
 
-	userAgent := UserAgents[string(config.Impersonate)]
-	if userAgent == "" {
-		userAgent = UserAgents["chrome"]
+	// BrowserNone keeps Go's own TLS stack and headers but still sends a
+	// browser User-Agent, as sites commonly reject Go's default one.
+	profile := BrowserProfiles[config.Impersonate]
+	userAgent := BrowserProfiles[BrowserChrome].UserAgent
+	if profile != nil {
+		userAgent = profile.UserAgent
+	}
+
+	direct, err := createTransport(nil, config.VerifySSL, profile)
+	if err != nil {
+		return nil, err
 	}
 
 	client := &HTTPClient{
+		direct:        direct,
+		base:          config.Client,
+		profile:       profile,
 		userAgent:     userAgent,
 		timeout:       timeout,
 		verifySSL:     config.VerifySSL,
 		allowRedirect: config.AllowRedirect,
 	}
 
-	var transport *http.Transport
+	var proxyURLs []string
 	if config.ProxyFile != "" {
-		proxies, err := LoadProxiesFromFile(config.ProxyFile)
+		var err error
+		proxyURLs, err = LoadProxiesFromFile(config.ProxyFile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load proxies: %w", err)
 		}
-		client.proxyRotator = NewProxyRotator(proxies)
-
-		proxyURL := client.proxyRotator.Next()
-		transport, err = createTransport(proxyURL, config.VerifySSL)
-		if err != nil {
-			return nil, err
-		}
 	} else if config.Proxy != "" {
-		client.singleProxy = config.Proxy
-		var err error
-		transport, err = createTransport(config.Proxy, config.VerifySSL)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		transport = &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: !config.VerifySSL,
-			},
-		}
-	}
-
-	var checkRedirect func(req *http.Request, via []*http.Request) error
-	if !config.AllowRedirect {
-		checkRedirect = func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		}
+		proxyURLs = []string{config.Proxy}
 	}
 
-	client.client = &http.Client{
-		Transport:     transport,
-		Timeout:       timeout,
-		CheckRedirect: checkRedirect,
+	pool, err := NewProxyPool(proxyURLs, ProxyPoolConfig{
+		Strategy:    config.ProxyStrategy,
+		VerifySSL:   config.VerifySSL,
+		Profile:     profile,
+		MaxFailures: config.ProxyMaxFailures,
+		Cooldown:    config.ProxyCooldown,
+	})
+	if err != nil {
+		return nil, err
 	}
+	client.proxies = pool
 
 	return client, nil
 }
 
-func createTransport(proxyStr string, verifySSL bool) (*http.Transport, error) {
-	if proxyStr == "" {
-		return &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: !verifySSL,
-			},
-		}, nil
-	}
-
-	proxyURL, err := url.Parse(proxyStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid proxy URL: %w", err)
-	}
-
-	if proxyURL.Scheme == "socks5" {
-		dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, nil, proxy.Direct)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create SOCKS5 dialer: %w", err)
-		}
+// SelectProxy returns the proxy the next request should use, avoiding
+// exclude when another one is available. It returns nil when the client
+// connects directly.
+func (c *HTTPClient) SelectProxy(exclude *Proxy) *Proxy {
+	return c.proxies.Select(exclude)
+}
 
-		return &http.Transport{
-			Dial: dialer.Dial,
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: !verifySSL,
-			},
-		}, nil
-	}
+func (c *HTTPClient) HasProxies() bool {
+	return c.proxies.configured() > 0
+}
 
-	return &http.Transport{
-		Proxy: http.ProxyURL(proxyURL),
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: !verifySSL,
-		},
-	}, nil
+// CheckProxies runs the pre-flight health probe against probeURL and
+// evicts every proxy that fails it.
+func (c *HTTPClient) CheckProxies(ctx context.Context, probeURL string) error {
+	return c.proxies.Probe(ctx, probeURL, c.userAgent, c.timeout)
 }
 
-func (c *HTTPClient) RotateProxy() error {
-	if c.proxyRotator == nil {
-		return nil
-	}
+func (c *HTTPClient) ProxyReport() []ProxyStats {
+	return c.proxies.Report()
+}
 
-	proxyURL := c.proxyRotator.Next()
-	transport, err := createTransport(proxyURL, c.verifySSL)
-	if err != nil {
-		return err
+func (c *HTTPClient) Do(ctx context.Context, r Request) (*http.Response, error) {
+	var body io.Reader
+	if r.Body != "" {
+		body = strings.NewReader(r.Body)
 	}
 
-	c.client.Transport = transport
-	return nil
-}
-
-func (c *HTTPClient) Get(url string, headers map[string]string) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, body)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("User-Agent", c.userAgent)
-
-	for key, value := range headers {
-		req.Header.Set(key, value)
+	if c.profile != nil {
+		for key, value := range c.profile.Headers {
+			req.Header.Set(key, value)
+		}
 	}
 
-	return c.client.Do(req)
-}
+	if r.Method == http.MethodPost && r.Headers["Content-Type"] == "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
 
-func (c *HTTPClient) Post(url string, headers map[string]string, body string) (*http.Response, error) {
-	req, err := http.NewRequest("POST", url, strings.NewReader(body))
-	if err != nil {
-		return nil, err
+	for key, value := range r.Headers {
+		req.Header.Set(key, value)
 	}
 
-	req.Header.Set("User-Agent", c.userAgent)
+	httpClient := c.base
+	external := httpClient != nil && r.Proxy == nil
+	if !external {
+		transport := c.direct
+		if r.Proxy != nil {
+			transport = r.Proxy.transport
+		}
 
-	if headers["Content-Type"] == "" {
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		httpClient = &http.Client{
+			Transport: transport,
+			Timeout:   c.timeout,
+		}
+		if !c.allowRedirect {
+			httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			}
+		}
 	}
 
-	for key, value := range headers {
-		req.Header.Set(key, value)
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err == nil && external && c.profile != nil {
+		// The profile's Accept-Encoding keeps net/http from decompressing
+		// the response, so decode it the way the browser transport does.
+		err = decodeResponse(resp)
+		if err != nil {
+			resp = nil
+		}
+	}
+	if r.Proxy != nil && ctx.Err() == nil {
+		if err == nil {
+			err = proxyResponseError(resp)
+			if err != nil {
+				resp.Body.Close()
+				resp = nil
+			}
+		}
+		if err != nil {
+			c.proxies.recordFailure(r.Proxy, err)
+		} else {
+			c.proxies.recordSuccess(r.Proxy, time.Since(start))
+		}
 	}
+	return resp, err
+}
+
+func (c *HTTPClient) Get(ctx context.Context, url string, headers map[string]string) (*http.Response, error) {
+	return c.Do(ctx, Request{
+		Method:  http.MethodGet,
+		URL:     url,
+		Headers: headers,
+		Proxy:   c.SelectProxy(nil),
+	})
+}
+
+func (c *HTTPClient) Post(ctx context.Context, url string, headers map[string]string, body string) (*http.Response, error) {
+	return c.Do(ctx, Request{
+		Method:  http.MethodPost,
+		URL:     url,
+		Headers: headers,
+		Body:    body,
+		Proxy:   c.SelectProxy(nil),
+	})
+}
 
-	return c.client.Do(req)
+// decodeResponse reads resp.Body and replaces it with the decoded data,
+// if the response has a Content-Encoding.
+func decodeResponse(resp *http.Response) error {
+	if resp.Header.Get("Content-Encoding") == "" {
+		return nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	return decodeBody(resp, data)
 }
 
 func ReadResponseBody(resp *http.Response) (string, error) {
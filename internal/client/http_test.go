@@ -0,0 +1,81 @@
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// gzipServer compresses its responses whenever the request allows gzip.
+func gzipServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			fmt.Fprint(w, "<title>profile</title>")
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		fmt.Fprint(zw, "<title>profile</title>")
+		zw.Close()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPClientDecodes(t *testing.T) {
+	server := gzipServer(t)
+
+	tests := []struct {
+		name   string
+		config ClientConfig
+	}{
+		{"browser transport", ClientConfig{Timeout: 5, Impersonate: BrowserChrome}},
+		{"go transport", ClientConfig{Timeout: 5, Impersonate: BrowserNone}},
+		// The caller's client gets the profile's Accept-Encoding, which
+		// turns off net/http's own decompression.
+		{"caller's client", ClientConfig{Impersonate: BrowserChrome, Client: &http.Client{}}},
+		{"caller's client without profile", ClientConfig{Impersonate: BrowserNone, Client: &http.Client{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewHTTPClient(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := c.Get(context.Background(), server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Header.Get("Content-Encoding") != "" {
+				t.Errorf("Content-Encoding %q left on the decoded response", resp.Header.Get("Content-Encoding"))
+			}
+			body, err := ReadResponseBody(resp)
+			if err != nil || body != "<title>profile</title>" {
+				t.Errorf("body = %q, %v", body, err)
+			}
+		})
+	}
+}
+
+func TestHTTPClientDecodeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		io.WriteString(w, "not gzip")
+	}))
+	defer server.Close()
+
+	c, err := NewHTTPClient(ClientConfig{Impersonate: BrowserChrome, Client: &http.Client{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := c.Get(context.Background(), server.URL, nil); err == nil {
+		resp.Body.Close()
+		t.Error("invalid gzip body accepted")
+	}
+}
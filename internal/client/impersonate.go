@@ -0,0 +1,253 @@
+package client
+
+import (
+	"fmt"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+)
+
+// BrowserProfile describes what a browser reveals about itself on the wire:
+// its TLS ClientHello (cipher suites, extensions, ALPN), the headers it sends
+// on a top-level navigation and their order, and its HTTP/2 connection
+// preface.
+type BrowserProfile struct {
+	ClientHello utls.ClientHelloID
+	UserAgent   string
+
+	// Headers are added to every request unless the caller already set
+	// them.
+	Headers map[string]string
+
+	// HeaderOrder lists header names in the order the browser sends them,
+	// spelled as on HTTP/1.1. Headers not listed follow in sorted order.
+	HeaderOrder       []string
+	PseudoHeaderOrder []string
+
+	H2Settings     []http2.Setting
+	H2WindowUpdate uint32
+	H2Priority     http2.PriorityParam
+}
+
+var BrowserImpersonations = []BrowserImpersonation{
+	BrowserNone,
+	BrowserChrome,
+	BrowserChromeAndroid,
+	BrowserSafari,
+	BrowserSafariIOS,
+	BrowserEdge,
+	BrowserFirefox,
+}
+
+func ParseBrowserImpersonation(s string) (BrowserImpersonation, error) {
+	for _, browser := range BrowserImpersonations {
+		if string(browser) == s {
+			return browser, nil
+		}
+	}
+	return "", fmt.Errorf("unknown browser %q (valid: chrome, chrome_android, firefox, safari, safari_ios, edge, none)", s)
+}
+
+const (
+	chromeAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"
+	safariAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+)
+
+var chromeHeaderOrder = []string{
+	"Host",
+	"Connection",
+	"Content-Length",
+	"Cache-Control",
+	"sec-ch-ua",
+	"sec-ch-ua-mobile",
+	"sec-ch-ua-platform",
+	"Origin",
+	"Content-Type",
+	"Upgrade-Insecure-Requests",
+	"User-Agent",
+	"Accept",
+	"Sec-Fetch-Site",
+	"Sec-Fetch-Mode",
+	"Sec-Fetch-User",
+	"Sec-Fetch-Dest",
+	"Referer",
+	"Accept-Encoding",
+	"Accept-Language",
+	"Cookie",
+	"Priority",
+}
+
+var chromeH2Settings = []http2.Setting{
+	{ID: http2.SettingHeaderTableSize, Val: 65536},
+	{ID: http2.SettingEnablePush, Val: 0},
+	{ID: http2.SettingInitialWindowSize, Val: 6291456},
+	{ID: http2.SettingMaxHeaderListSize, Val: 262144},
+}
+
+var chromePriority = http2.PriorityParam{Exclusive: true, Weight: 255}
+
+var safariHeaderOrder = []string{
+	"Host",
+	"Content-Type",
+	"Origin",
+	"Content-Length",
+	"Accept",
+	"Sec-Fetch-Site",
+	"Cookie",
+	"Accept-Encoding",
+	"Sec-Fetch-Mode",
+	"User-Agent",
+	"Referer",
+	"Accept-Language",
+	"Sec-Fetch-Dest",
+	"Connection",
+}
+
+var safariH2Settings = []http2.Setting{
+	{ID: http2.SettingEnablePush, Val: 0},
+	{ID: http2.SettingMaxConcurrentStreams, Val: 100},
+	{ID: http2.SettingInitialWindowSize, Val: 2097152},
+}
+
+var BrowserProfiles = map[BrowserImpersonation]*BrowserProfile{
+	BrowserChrome: {
+		ClientHello: utls.HelloChrome_133,
+		UserAgent:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36",
+		Headers: map[string]string{
+			"sec-ch-ua":                 `"Not(A:Brand";v="99", "Google Chrome";v="133", "Chromium";v="133"`,
+			"sec-ch-ua-mobile":          "?0",
+			"sec-ch-ua-platform":        `"Windows"`,
+			"Upgrade-Insecure-Requests": "1",
+			"Accept":                    chromeAccept,
+			"Sec-Fetch-Site":            "none",
+			"Sec-Fetch-Mode":            "navigate",
+			"Sec-Fetch-User":            "?1",
+			"Sec-Fetch-Dest":            "document",
+			"Accept-Encoding":           "gzip, deflate, br, zstd",
+			"Accept-Language":           "en-US,en;q=0.9",
+			"Priority":                  "u=0, i",
+		},
+		HeaderOrder:       chromeHeaderOrder,
+		PseudoHeaderOrder: []string{":method", ":authority", ":scheme", ":path"},
+		H2Settings:        chromeH2Settings,
+		H2WindowUpdate:    15663105,
+		H2Priority:        chromePriority,
+	},
+	BrowserChromeAndroid: {
+		ClientHello: utls.HelloChrome_133,
+		UserAgent:   "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Mobile Safari/537.36",
+		Headers: map[string]string{
+			"sec-ch-ua":                 `"Not(A:Brand";v="99", "Google Chrome";v="133", "Chromium";v="133"`,
+			"sec-ch-ua-mobile":          "?1",
+			"sec-ch-ua-platform":        `"Android"`,
+			"Upgrade-Insecure-Requests": "1",
+			"Accept":                    chromeAccept,
+			"Sec-Fetch-Site":            "none",
+			"Sec-Fetch-Mode":            "navigate",
+			"Sec-Fetch-User":            "?1",
+			"Sec-Fetch-Dest":            "document",
+			"Accept-Encoding":           "gzip, deflate, br, zstd",
+			"Accept-Language":           "en-US,en;q=0.9",
+			"Priority":                  "u=0, i",
+		},
+		HeaderOrder:       chromeHeaderOrder,
+		PseudoHeaderOrder: []string{":method", ":authority", ":scheme", ":path"},
+		H2Settings:        chromeH2Settings,
+		H2WindowUpdate:    15663105,
+		H2Priority:        chromePriority,
+	},
+	BrowserEdge: {
+		ClientHello: utls.HelloChrome_133,
+		UserAgent:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36 Edg/133.0.0.0",
+		Headers: map[string]string{
+			"sec-ch-ua":                 `"Not(A:Brand";v="99", "Microsoft Edge";v="133", "Chromium";v="133"`,
+			"sec-ch-ua-mobile":          "?0",
+			"sec-ch-ua-platform":        `"Windows"`,
+			"Upgrade-Insecure-Requests": "1",
+			"Accept":                    chromeAccept,
+			"Sec-Fetch-Site":            "none",
+			"Sec-Fetch-Mode":            "navigate",
+			"Sec-Fetch-User":            "?1",
+			"Sec-Fetch-Dest":            "document",
+			"Accept-Encoding":           "gzip, deflate, br, zstd",
+			"Accept-Language":           "en-US,en;q=0.9",
+			"Priority":                  "u=0, i",
+		},
+		HeaderOrder:       chromeHeaderOrder,
+		PseudoHeaderOrder: []string{":method", ":authority", ":scheme", ":path"},
+		H2Settings:        chromeH2Settings,
+		H2WindowUpdate:    15663105,
+		H2Priority:        chromePriority,
+	},
+	BrowserFirefox: {
+		ClientHello: utls.HelloFirefox_120,
+		UserAgent:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:120.0) Gecko/20100101 Firefox/120.0",
+		Headers: map[string]string{
+			"Accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+			"Accept-Language":           "en-US,en;q=0.5",
+			"Accept-Encoding":           "gzip, deflate, br",
+			"Upgrade-Insecure-Requests": "1",
+			"Sec-Fetch-Dest":            "document",
+			"Sec-Fetch-Mode":            "navigate",
+			"Sec-Fetch-Site":            "none",
+			"Sec-Fetch-User":            "?1",
+			"TE":                        "trailers",
+		},
+		HeaderOrder: []string{
+			"Host",
+			"User-Agent",
+			"Accept",
+			"Accept-Language",
+			"Accept-Encoding",
+			"Content-Type",
+			"Content-Length",
+			"Origin",
+			"Connection",
+			"Referer",
+			"Cookie",
+			"Upgrade-Insecure-Requests",
+			"Sec-Fetch-Dest",
+			"Sec-Fetch-Mode",
+			"Sec-Fetch-Site",
+			"Sec-Fetch-User",
+			"Priority",
+			"TE",
+		},
+		PseudoHeaderOrder: []string{":method", ":path", ":authority", ":scheme"},
+		H2Settings: []http2.Setting{
+			{ID: http2.SettingHeaderTableSize, Val: 65536},
+			{ID: http2.SettingInitialWindowSize, Val: 131072},
+			{ID: http2.SettingMaxFrameSize, Val: 16384},
+		},
+		H2WindowUpdate: 12517377,
+		H2Priority:     http2.PriorityParam{Weight: 41},
+	},
+	BrowserSafari: {
+		ClientHello: utls.HelloSafari_16_0,
+		UserAgent:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.0 Safari/605.1.15",
+		Headers: map[string]string{
+			"Accept":          safariAccept,
+			"Accept-Language": "en-US,en;q=0.9",
+			"Accept-Encoding": "gzip, deflate, br",
+		},
+		HeaderOrder:       safariHeaderOrder,
+		PseudoHeaderOrder: []string{":method", ":scheme", ":path", ":authority"},
+		H2Settings:        safariH2Settings,
+		H2WindowUpdate:    10485760,
+		H2Priority:        http2.PriorityParam{Weight: 254},
+	},
+	BrowserSafariIOS: {
+		ClientHello: utls.HelloIOS_14,
+		UserAgent:   "Mozilla/5.0 (iPhone; CPU iPhone OS 14_8 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.2 Mobile/15E148 Safari/604.1",
+		Headers: map[string]string{
+			"Accept":          safariAccept,
+			"Accept-Language": "en-US,en;q=0.9",
+			"Accept-Encoding": "gzip, deflate, br",
+		},
+		HeaderOrder:       safariHeaderOrder,
+		PseudoHeaderOrder: []string{":method", ":scheme", ":path", ":authority"},
+		H2Settings:        safariH2Settings,
+		H2WindowUpdate:    10485760,
+		H2Priority:        http2.PriorityParam{Weight: 254},
+	},
+}
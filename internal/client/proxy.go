@@ -0,0 +1,284 @@
+package client
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ProxyStrategy string
+
+const (
+	ProxyRoundRobin          ProxyStrategy = "round-robin"
+	ProxyRandom              ProxyStrategy = "random"
+	ProxyLeastRecentlyFailed ProxyStrategy = "least-failed"
+)
+
+var ProxyStrategies = []ProxyStrategy{ProxyRoundRobin, ProxyRandom, ProxyLeastRecentlyFailed}
+
+// Proxy is a single upstream proxy with its own transport, so connections
+// are never shared between proxies.
+type Proxy struct {
+	url       *url.URL
+	transport http.RoundTripper
+	health    proxyHealth
+}
+
+// String returns the proxy URL with any password redacted.
+func (p *Proxy) String() string {
+	if p == nil {
+		return ""
+	}
+	return p.url.Redacted()
+}
+
+type ProxyPoolConfig struct {
+	Strategy  ProxyStrategy
+	VerifySSL bool
+	Profile   *BrowserProfile
+
+	// MaxFailures consecutive failures put a proxy in quarantine for
+	// Cooldown. Zero disables quarantine.
+	MaxFailures int
+	Cooldown    time.Duration
+}
+
+// ProxyPool hands out a proxy per request according to its strategy,
+// skipping proxies that are quarantined or were evicted by a probe.
+type ProxyPool struct {
+	config ProxyPoolConfig
+
+	mu      sync.Mutex
+	proxies []*Proxy
+	evicted []*Proxy
+	next    int
+}
+
+func NewProxyPool(proxyURLs []string, config ProxyPoolConfig) (*ProxyPool, error) {
+	if config.Strategy == "" {
+		config.Strategy = ProxyRoundRobin
+	}
+
+	pool := &ProxyPool{config: config}
+	for _, raw := range proxyURLs {
+		proxyURL, err := ParseProxy(raw)
+		if err != nil {
+			return nil, err
+		}
+		transport, err := createTransport(proxyURL, config.VerifySSL, config.Profile)
+		if err != nil {
+			return nil, err
+		}
+		pool.proxies = append(pool.proxies, &Proxy{url: proxyURL, transport: transport})
+	}
+
+	return pool, nil
+}
+
+func (pp *ProxyPool) Len() int {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	return len(pp.proxies)
+}
+
+func (pp *ProxyPool) configured() int {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	return len(pp.proxies) + len(pp.evicted)
+}
+
+// Select picks the proxy for the next request. When exclude is non-nil and
+// the pool has an alternative, a different proxy is returned, which lets
+// retries move away from an exit that just failed. If every proxy is
+// quarantined the one closest to the end of its cool-down is used rather
+// than failing the request outright.
+func (pp *ProxyPool) Select(exclude *Proxy) *Proxy {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	n := len(pp.proxies)
+	if n == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	candidates := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		idx := (pp.next + i) % n
+		candidate := pp.proxies[idx]
+		if candidate != exclude && !candidate.health.quarantined(now) {
+			candidates = append(candidates, idx)
+		}
+	}
+
+	if len(candidates) == 0 {
+		if exclude != nil && !exclude.health.quarantined(now) {
+			return exclude
+		}
+		return pp.soonestAvailable()
+	}
+
+	chosen := candidates[0]
+	switch pp.config.Strategy {
+	case ProxyRandom:
+		chosen = candidates[rand.Intn(len(candidates))]
+
+	case ProxyLeastRecentlyFailed:
+		oldest := pp.proxies[chosen].health.failedAt()
+		for _, idx := range candidates[1:] {
+			if failedAt := pp.proxies[idx].health.failedAt(); failedAt.Before(oldest) {
+				chosen = idx
+				oldest = failedAt
+			}
+		}
+	}
+
+	pp.next = (chosen + 1) % n
+	return pp.proxies[chosen]
+}
+
+func (pp *ProxyPool) soonestAvailable() *Proxy {
+	best := pp.proxies[0]
+	for _, p := range pp.proxies[1:] {
+		if p.health.releasedAt().Before(best.health.releasedAt()) {
+			best = p
+		}
+	}
+	return best
+}
+
+func (pp *ProxyPool) recordSuccess(p *Proxy, latency time.Duration) {
+	p.health.recordSuccess(latency)
+}
+
+func (pp *ProxyPool) recordFailure(p *Proxy, err error) {
+	p.health.recordFailure(time.Now(), err, pp.config.MaxFailures, pp.config.Cooldown)
+}
+
+// evict removes p from rotation for the rest of the run.
+func (pp *ProxyPool) evict(p *Proxy) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	for i, candidate := range pp.proxies {
+		if candidate == p {
+			pp.proxies = append(pp.proxies[:i], pp.proxies[i+1:]...)
+			pp.evicted = append(pp.evicted, p)
+			if pp.next >= len(pp.proxies) {
+				pp.next = 0
+			}
+			return
+		}
+	}
+}
+
+func (pp *ProxyPool) CloseIdleConnections() {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	for _, p := range pp.proxies {
+		closeIdleConnections(p.transport)
+	}
+}
+
+func ParseProxyStrategy(s string) (ProxyStrategy, error) {
+	for _, strategy := range ProxyStrategies {
+		if string(strategy) == s {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown proxy strategy %q (valid: round-robin, random, least-failed)", s)
+}
+
+// LoadProxiesFromFile reads one proxy specification per line, skipping
+// blank lines and # comments. Every entry is checked with ParseProxy and
+// all invalid entries are reported together with their line numbers.
+func LoadProxiesFromFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open proxies file: %w", err)
+	}
+	defer file.Close()
+
+	var proxies []string
+	var invalid []error
+	lineNo := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		proxyURL, err := ParseProxy(line)
+		if err != nil {
+			invalid = append(invalid, fmt.Errorf("%s:%d: %w", filename, lineNo, err))
+			continue
+		}
+		proxies = append(proxies, proxyURL.String())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read proxies file: %w", err)
+	}
+
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("invalid proxies in file:\n%w", errors.Join(invalid...))
+	}
+
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("no valid proxies found in file")
+	}
+
+	return proxies, nil
+}
+
+func newDirectTransport(verifySSL bool) *http.Transport {
+	return &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: !verifySSL,
+		},
+	}
+}
+
+// createTransport returns the transport for proxyURL, or for direct
+// connections when proxyURL is nil. A non-nil profile selects the browser
+// transport; otherwise the standard library's TLS stack is used.
+func createTransport(proxyURL *url.URL, verifySSL bool, profile *BrowserProfile) (http.RoundTripper, error) {
+	if profile != nil {
+		return newBrowserTransport(proxyURL, verifySSL, profile)
+	}
+
+	transport := newDirectTransport(verifySSL)
+	if proxyURL == nil {
+		return transport, nil
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https":
+		transport.Proxy = http.ProxyURL(proxyURL)
+	default:
+		dialer, err := newSOCKSDialer(proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		transport.DialContext = dialer.DialContext
+	}
+
+	return transport, nil
+}
+
+func closeIdleConnections(transport http.RoundTripper) {
+	if closer, ok := transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestPool(t *testing.T, strategy ProxyStrategy, n int) *ProxyPool {
+	t.Helper()
+	urls := []string{"http://p0:8080", "http://p1:8080", "http://p2:8080", "http://p3:8080"}[:n]
+	pool, err := NewProxyPool(urls, ProxyPoolConfig{Strategy: strategy, MaxFailures: 2, Cooldown: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func hosts(proxies ...*Proxy) []string {
+	names := make([]string, len(proxies))
+	for i, p := range proxies {
+		names[i] = p.url.Hostname()
+	}
+	return names
+}
+
+func TestSelectRoundRobin(t *testing.T) {
+	pool := newTestPool(t, ProxyRoundRobin, 3)
+
+	var got []*Proxy
+	for i := 0; i < 5; i++ {
+		got = append(got, pool.Select(nil))
+	}
+	want := []string{"p0", "p1", "p2", "p0", "p1"}
+	for i, name := range hosts(got...) {
+		if name != want[i] {
+			t.Fatalf("got %v, want %v", hosts(got...), want)
+		}
+	}
+}
+
+func TestSelectExclude(t *testing.T) {
+	pool := newTestPool(t, ProxyRoundRobin, 2)
+	first := pool.Select(nil)
+	for i := 0; i < 4; i++ {
+		if p := pool.Select(first); p == first {
+			t.Fatalf("Select(%s) returned the excluded proxy", hosts(first))
+		}
+	}
+
+	// With a single proxy there is no alternative.
+	single := newTestPool(t, ProxyRoundRobin, 1)
+	only := single.Select(nil)
+	if p := single.Select(only); p != only {
+		t.Errorf("got %v, want the only proxy", hosts(p))
+	}
+}
+
+func TestSelectRandom(t *testing.T) {
+	pool := newTestPool(t, ProxyRandom, 3)
+	seen := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		seen[pool.Select(nil).url.Hostname()] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("random selection used %v, want all three proxies", seen)
+	}
+}
+
+func TestSelectLeastRecentlyFailed(t *testing.T) {
+	pool := newTestPool(t, ProxyLeastRecentlyFailed, 3)
+	now := time.Now()
+	pool.proxies[0].health.recordFailure(now, errors.New("refused"), 0, 0)
+	pool.proxies[1].health.recordFailure(now.Add(-time.Hour), errors.New("refused"), 0, 0)
+	pool.proxies[2].health.recordFailure(now.Add(-time.Minute), errors.New("refused"), 0, 0)
+
+	if p := pool.Select(nil); p != pool.proxies[1] {
+		t.Errorf("got %v, want p1, which failed longest ago", hosts(p))
+	}
+}
+
+func TestSelectEmpty(t *testing.T) {
+	pool, err := NewProxyPool(nil, ProxyPoolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := pool.Select(nil); p != nil {
+		t.Errorf("empty pool selected %v", hosts(p))
+	}
+}
+
+func TestNewProxyPoolInvalid(t *testing.T) {
+	if _, err := NewProxyPool([]string{"ftp://p0:21"}, ProxyPoolConfig{}); err == nil {
+		t.Error("unsupported scheme accepted")
+	}
+}
+
+func TestParseProxyStrategy(t *testing.T) {
+	for _, strategy := range ProxyStrategies {
+		if got, err := ParseProxyStrategy(string(strategy)); err != nil || got != strategy {
+			t.Errorf("ParseProxyStrategy(%q) = %q, %v", strategy, got, err)
+		}
+	}
+	if _, err := ParseProxyStrategy("fastest"); err == nil {
+		t.Error("unknown strategy accepted")
+	}
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultProxyScheme is assumed for entries written without a scheme, such
+// as "host:port" or the "host:port:user:pass" shorthand used by many
+// commercial proxy lists.
+const DefaultProxyScheme = "socks5"
+
+var ProxySchemes = []string{"http", "https", "socks4", "socks4a", "socks5", "socks5h"}
+
+// ParseProxy parses a proxy specification into a URL. Accepted forms:
+//
+//	scheme://[user:pass@]host:port
+//	[user:pass@]host:port
+//	host:port:user:pass
+//
+// where scheme is one of ProxySchemes.
+func ParseProxy(spec string) (*url.URL, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty proxy specification")
+	}
+
+	if !strings.Contains(spec, "://") {
+		// A bracketed IPv6 host has colons of its own and is never the
+		// shorthand.
+		parts := strings.Split(spec, ":")
+		if len(parts) == 4 && !strings.Contains(spec, "@") && !strings.HasPrefix(spec, "[") {
+			spec = (&url.URL{
+				Scheme: DefaultProxyScheme,
+				User:   url.UserPassword(parts[2], parts[3]),
+				Host:   net.JoinHostPort(parts[0], parts[1]),
+			}).String()
+		} else {
+			spec = DefaultProxyScheme + "://" + spec
+		}
+	}
+
+	proxyURL, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+
+	proxyURL.Scheme = strings.ToLower(proxyURL.Scheme)
+	if !isProxyScheme(proxyURL.Scheme) {
+		return nil, fmt.Errorf("unsupported proxy scheme %q (supported: %s)", proxyURL.Scheme, strings.Join(ProxySchemes, ", "))
+	}
+
+	if proxyURL.Hostname() == "" {
+		return nil, fmt.Errorf("proxy %q has no host", proxyURL.Redacted())
+	}
+
+	port := proxyURL.Port()
+	if port == "" {
+		return nil, fmt.Errorf("proxy %q has no port", proxyURL.Redacted())
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return nil, fmt.Errorf("proxy %q has invalid port %q", proxyURL.Redacted(), port)
+	}
+
+	if (proxyURL.Path != "" && proxyURL.Path != "/") || proxyURL.RawQuery != "" {
+		return nil, fmt.Errorf("proxy %q must not contain a path or query", proxyURL.Redacted())
+	}
+	proxyURL.Path = ""
+
+	return proxyURL, nil
+}
+
+func isProxyScheme(scheme string) bool {
+	for _, s := range ProxySchemes {
+		if s == scheme {
+			return true
+		}
+	}
+	return false
+}
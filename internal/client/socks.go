@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// newSOCKSDialer returns a dialer tunnelling through a SOCKS proxy. The
+// "h" and "a" variants let the proxy resolve target hostnames; socks4 and
+// socks5 resolve them locally first, as curl does.
+func newSOCKSDialer(proxyURL *url.URL) (proxy.ContextDialer, error) {
+	direct := &net.Dialer{}
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+		}
+
+		dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, direct)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SOCKS5 dialer: %w", err)
+		}
+		contextDialer, ok := dialer.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("SOCKS5 dialer does not support contexts")
+		}
+		if proxyURL.Scheme == "socks5" {
+			return &localResolveDialer{dialer: contextDialer}, nil
+		}
+		return contextDialer, nil
+
+	case "socks4", "socks4a":
+		return &socks4Dialer{
+			proxyAddr:     proxyURL.Host,
+			userID:        proxyURL.User.Username(),
+			remoteResolve: proxyURL.Scheme == "socks4a",
+			forward:       direct,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported SOCKS scheme %q", proxyURL.Scheme)
+}
+
+// localResolveDialer resolves the target hostname before handing the
+// connection to the wrapped SOCKS dialer.
+type localResolveDialer struct {
+	dialer proxy.ContextDialer
+}
+
+func (d *localResolveDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) == nil {
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("no addresses found for %s", host)
+		}
+		host = ips[0].IP.String()
+	}
+	return d.dialer.DialContext(ctx, network, net.JoinHostPort(host, port))
+}
+
+type socks4Dialer struct {
+	proxyAddr     string
+	userID        string
+	remoteResolve bool
+	forward       *net.Dialer
+}
+
+const (
+	socks4Version  = 0x04
+	socks4Connect  = 0x01
+	socks4Granted  = 0x5a
+	socks4ReplyLen = 8
+)
+
+func (d *socks4Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	req := []byte{socks4Version, socks4Connect, 0, 0}
+	binary.BigEndian.PutUint16(req[2:], uint16(port))
+
+	ip := net.ParseIP(host).To4()
+	switch {
+	case ip != nil:
+		req = append(req, ip...)
+	case d.remoteResolve:
+		// SOCKS4a: an invalid IP of 0.0.0.x tells the proxy a hostname
+		// follows the user ID.
+		req = append(req, 0, 0, 0, 1)
+	default:
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+		if err != nil {
+			return nil, err
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("no IPv4 address found for %s", host)
+		}
+		req = append(req, ips[0].To4()...)
+	}
+
+	req = append(req, d.userID...)
+	req = append(req, 0)
+	if ip == nil && d.remoteResolve {
+		req = append(req, host...)
+		req = append(req, 0)
+	}
+
+	conn, err := d.forward.DialContext(ctx, "tcp", d.proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SOCKS4 request failed: %w", err)
+	}
+
+	reply := make([]byte, socks4ReplyLen)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SOCKS4 reply failed: %w", err)
+	}
+	if reply[1] != socks4Granted {
+		conn.Close()
+		return nil, fmt.Errorf("SOCKS4 proxy rejected connection to %s (code 0x%02x)", addr, reply[1])
+	}
+
+	return conn, nil
+}
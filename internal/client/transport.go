@@ -0,0 +1,434 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/proxy"
+)
+
+// h2MaxRequestBody is the largest body sent without waiting for the
+// server's flow-control window, which starts at 65535 bytes.
+const h2MaxRequestBody = 65535
+
+// h2MaxFrameSize is the frame size every HTTP/2 peer must accept.
+const h2MaxFrameSize = 16384
+
+// hopHeaders are connection-specific and must not be sent over HTTP/2.
+var hopHeaders = map[string]bool{
+	"connection":        true,
+	"host":              true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// browserTransport sends requests the way its profile's browser would: the
+// browser's TLS ClientHello, its headers in its order and, when the server
+// negotiates h2, its SETTINGS, WINDOW_UPDATE and stream priority. Like the
+// browser it keeps HTTP/1.1 connections alive and multiplexes requests to
+// a host over one h2 connection. The response body is read in full before
+// RoundTrip returns.
+type browserTransport struct {
+	profile   *BrowserProfile
+	proxyURL  *url.URL
+	dialer    proxy.ContextDialer
+	verifySSL bool
+
+	conns connPool
+}
+
+// newBrowserTransport returns a transport for profile that connects
+// directly when proxyURL is nil, through a SOCKS dialer, or through an HTTP
+// proxy using CONNECT for https targets.
+func newBrowserTransport(proxyURL *url.URL, verifySSL bool, profile *BrowserProfile) (*browserTransport, error) {
+	t := &browserTransport{
+		profile:   profile,
+		proxyURL:  proxyURL,
+		dialer:    &net.Dialer{},
+		verifySSL: verifySSL,
+	}
+
+	if proxyURL != nil && !t.httpProxy() {
+		dialer, err := newSOCKSDialer(proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		t.dialer = dialer
+	}
+
+	return t, nil
+}
+
+func (t *browserTransport) httpProxy() bool {
+	return t.proxyURL != nil && (t.proxyURL.Scheme == "http" || t.proxyURL.Scheme == "https")
+}
+
+func (t *browserTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key := t.connKey(req.URL)
+	for attempt := 1; ; attempt++ {
+		cc, pc, err := t.getConn(ctx, key, req.URL)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+
+		var resp *http.Response
+		if cc != nil {
+			resp, err = cc.roundTrip(ctx, req, body)
+		} else {
+			resp, err = t.roundTripH1(ctx, key, pc, req, body)
+		}
+		if err == nil {
+			return resp, nil
+		}
+		if !errors.Is(err, errConnUnusable) || attempt == maxConnAttempts || ctx.Err() != nil {
+			return nil, contextError(ctx, err)
+		}
+	}
+}
+
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// dial connects to target, directly or through the configured proxy. The
+// returned flag reports that the connection goes to an HTTP proxy that
+// expects an absolute request URI rather than a tunnel.
+func (t *browserTransport) dial(ctx context.Context, target *url.URL) (net.Conn, bool, error) {
+	addr := hostPort(target)
+
+	if !t.httpProxy() {
+		conn, err := t.dialer.DialContext(ctx, "tcp", addr)
+		return conn, false, err
+	}
+
+	conn, err := t.dialer.DialContext(ctx, "tcp", hostPort(t.proxyURL))
+	if err != nil {
+		return nil, false, fmt.Errorf("proxyconnect tcp: %w", err)
+	}
+
+	if t.proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         t.proxyURL.Hostname(),
+			InsecureSkipVerify: !t.verifySSL,
+		})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, false, fmt.Errorf("proxyconnect tls: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	if target.Scheme == "http" {
+		return conn, true, nil
+	}
+
+	if err := t.connect(ctx, conn, addr); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	return conn, false, nil
+}
+
+// connect opens a CONNECT tunnel to addr over an established proxy
+// connection.
+func (t *browserTransport) connect(ctx context.Context, conn net.Conn, addr string) error {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{"User-Agent": {t.profile.UserAgent}},
+	}
+	if auth := proxyAuthorization(t.proxyURL); auth != "" {
+		req.Header.Set("Proxy-Authorization", auth)
+	}
+	if err := req.Write(conn); err != nil {
+		return contextError(ctx, fmt.Errorf("proxyconnect: %w", err))
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return contextError(ctx, fmt.Errorf("proxyconnect: %w", err))
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("proxyconnect: %s", resp.Status)
+	}
+	return nil
+}
+
+func proxyAuthorization(proxyURL *url.URL) string {
+	if proxyURL.User == nil {
+		return ""
+	}
+	password, _ := proxyURL.User.Password()
+	credentials := proxyURL.User.Username() + ":" + password
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+}
+
+func hostPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// roundTripH1 sends req on pc and reads the response. The connection goes
+// back to the pool unless either side asked to close it or the request was
+// cancelled.
+func (t *browserTransport) roundTripH1(ctx context.Context, key string, pc *h1Conn, req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := t.exchangeH1(ctx, pc, req, body)
+	if err != nil {
+		pc.conn.Close()
+		if pc.reused && ctx.Err() == nil {
+			err = staleConnError(err)
+		}
+		return nil, err
+	}
+
+	if resp.Close || req.Close || strings.EqualFold(req.Header.Get("Connection"), "close") {
+		pc.conn.Close()
+	} else {
+		t.conns.putIdle(key, pc)
+	}
+	return resp, nil
+}
+
+func (t *browserTransport) exchangeH1(ctx context.Context, pc *h1Conn, req *http.Request, body []byte) (*http.Response, error) {
+	conn := pc.conn
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	target := req.URL.RequestURI()
+	if pc.absolute {
+		target = req.URL.String()
+	}
+
+	headers, err := t.orderedHeaders(req, body, false)
+	if err != nil {
+		return nil, err
+	}
+	if pc.absolute {
+		if auth := proxyAuthorization(t.proxyURL); auth != "" {
+			headers = append(headers, [2]string{"Proxy-Authorization", auth})
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s HTTP/1.1\r\n", req.Method, target)
+	for _, header := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", header[0], header[1])
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	for {
+		resp, err := http.ReadResponse(pc.reader, req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 100 && resp.StatusCode < 200 && resp.StatusCode != http.StatusSwitchingProtocols {
+			continue
+		}
+
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if !stop() {
+			return nil, ctx.Err()
+		}
+		conn.SetDeadline(time.Time{})
+		return resp, decodeBody(resp, data)
+	}
+}
+
+func (t *browserTransport) headerTableSize() uint32 {
+	for _, setting := range t.profile.H2Settings {
+		if setting.ID == http2.SettingHeaderTableSize {
+			return setting.Val
+		}
+	}
+	return 4096
+}
+
+func pseudoHeader(req *http.Request, name string) string {
+	switch name {
+	case ":method":
+		return req.Method
+	case ":authority":
+		if req.Host != "" {
+			return req.Host
+		}
+		return req.URL.Host
+	case ":scheme":
+		return req.URL.Scheme
+	case ":path":
+		return req.URL.RequestURI()
+	}
+	return ""
+}
+
+// orderedHeaders returns the request headers, plus the Host, Connection and
+// Content-Length headers the transport adds on HTTP/1.1, arranged in the
+// profile's order. Headers the profile does not know follow in sorted
+// order.
+func (t *browserTransport) orderedHeaders(req *http.Request, body []byte, h2 bool) ([][2]string, error) {
+	spelling := make(map[string]string, len(t.profile.HeaderOrder))
+	for _, name := range t.profile.HeaderOrder {
+		spelling[strings.ToLower(name)] = name
+	}
+
+	values := make(map[string][]string, len(req.Header)+3)
+	for key, vals := range req.Header {
+		name := strings.ToLower(key)
+		if !httpguts.ValidHeaderFieldName(key) {
+			return nil, fmt.Errorf("invalid header field name %q", key)
+		}
+		for _, v := range vals {
+			if !httpguts.ValidHeaderFieldValue(v) {
+				return nil, fmt.Errorf("invalid header field value for %q", key)
+			}
+		}
+		if h2 && (hopHeaders[name] || (name == "te" && strings.ToLower(req.Header.Get(key)) != "trailers")) {
+			continue
+		}
+		values[name] = vals
+	}
+
+	if !h2 {
+		host := req.Host
+		if host == "" {
+			host = req.URL.Host
+		}
+		values["host"] = []string{host}
+		if _, ok := values["connection"]; !ok {
+			values["connection"] = []string{"keep-alive"}
+		}
+	}
+	if len(body) > 0 {
+		values["content-length"] = []string{strconv.Itoa(len(body))}
+	}
+
+	headers := make([][2]string, 0, len(values))
+	add := func(name string) {
+		display, ok := spelling[name]
+		if !ok {
+			display = http.CanonicalHeaderKey(name)
+		}
+		for _, v := range values[name] {
+			headers = append(headers, [2]string{display, v})
+		}
+		delete(values, name)
+	}
+
+	for _, name := range t.profile.HeaderOrder {
+		add(strings.ToLower(name))
+	}
+	remaining := make([]string, 0, len(values))
+	for name := range values {
+		remaining = append(remaining, name)
+	}
+	sort.Strings(remaining)
+	for _, name := range remaining {
+		add(name)
+	}
+
+	return headers, nil
+}
+
+// decodeBody replaces resp.Body with data, decompressed according to the
+// Content-Encoding the browser profile advertised.
+func decodeBody(resp *http.Response, data []byte) error {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+
+	var reader io.Reader
+	if len(data) > 0 {
+		var err error
+		switch encoding {
+		case "gzip":
+			reader, err = gzip.NewReader(bytes.NewReader(data))
+		case "deflate":
+			reader, err = zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				reader, err = flate.NewReader(bytes.NewReader(data)), nil
+			}
+		case "br":
+			reader = brotli.NewReader(bytes.NewReader(data))
+		case "zstd":
+			var decoder *zstd.Decoder
+			decoder, err = zstd.NewReader(bytes.NewReader(data))
+			if err == nil {
+				defer decoder.Close()
+				reader = decoder
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode %s response: %w", encoding, err)
+		}
+	}
+
+	if reader != nil {
+		decoded, err := io.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("failed to decode %s response: %w", encoding, err)
+		}
+		data = decoded
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.Uncompressed = true
+	}
+
+	resp.ContentLength = int64(len(data))
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return nil
+}
@@ -0,0 +1,747 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// fingerprint is what a server sees of a client before the first response:
+// the ClientHello and, on h2, the connection preface and request headers.
+type fingerprint struct {
+	hello *tls.ClientHelloInfo
+
+	settings     []http2.Setting
+	windowUpdate uint32
+	priority     http2.PriorityParam
+	pseudo       []string
+	headers      []string
+}
+
+// fingerprintServer is a TLS listener that records the fingerprint of the
+// first request on every connection and answers each request with 200.
+type fingerprintServer struct {
+	listener net.Listener
+	url      string
+
+	mu           sync.Mutex
+	conns        int
+	prints       []*fingerprint
+	streams      []uint32
+	maxStreams   uint32
+	closeStreams bool
+}
+
+func newFingerprintServer(t *testing.T, protos ...string) *fingerprintServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A host name rather than an IP, which would leave out SNI.
+	port := listener.Addr().(*net.TCPAddr).Port
+	s := &fingerprintServer{listener: listener, url: fmt.Sprintf("https://localhost:%d/user/alice?x=1", port)}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{testCertificate(t)},
+		NextProtos:   protos,
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *fingerprintServer) serve(raw net.Conn, config *tls.Config) {
+	defer raw.Close()
+
+	print := &fingerprint{}
+	config = config.Clone()
+	config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		print.hello = hello
+		return nil, nil
+	}
+	conn := tls.Server(raw, config)
+	if err := conn.Handshake(); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.conns++
+	s.prints = append(s.prints, print)
+	s.mu.Unlock()
+
+	if conn.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS {
+		s.serveH2(conn, print)
+		return
+	}
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		io.Copy(io.Discard, req.Body)
+		fmt.Fprint(conn, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+	}
+}
+
+func (s *fingerprintServer) serveH2(conn net.Conn, print *fingerprint) {
+	preface := make([]byte, len(http2.ClientPreface))
+	if _, err := io.ReadFull(conn, preface); err != nil || string(preface) != http2.ClientPreface {
+		return
+	}
+
+	framer := http2.NewFramer(conn, conn)
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	s.mu.Lock()
+	var settings []http2.Setting
+	if s.maxStreams > 0 {
+		settings = append(settings, http2.Setting{ID: http2.SettingMaxConcurrentStreams, Val: s.maxStreams})
+	}
+	s.mu.Unlock()
+	framer.WriteSettings(settings...)
+
+	var block []byte
+	var encoder = hpack.NewEncoder(sliceWriter{&block})
+	first := true
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			return
+		}
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if f.IsAck() {
+				continue
+			}
+			if first {
+				f.ForeachSetting(func(setting http2.Setting) error {
+					print.settings = append(print.settings, setting)
+					return nil
+				})
+			}
+			framer.WriteSettingsAck()
+
+		case *http2.WindowUpdateFrame:
+			if first && f.StreamID == 0 && print.windowUpdate == 0 {
+				print.windowUpdate = f.Increment
+			}
+
+		case *http2.MetaHeadersFrame:
+			if first {
+				print.priority = f.Priority
+				for _, field := range f.Fields {
+					if field.IsPseudo() {
+						print.pseudo = append(print.pseudo, field.Name)
+					} else {
+						print.headers = append(print.headers, field.Name)
+					}
+				}
+				first = false
+			}
+
+			s.mu.Lock()
+			s.streams = append(s.streams, f.StreamID)
+			closeStreams := s.closeStreams
+			s.mu.Unlock()
+
+			block = block[:0]
+			encoder.WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+			framer.WriteHeaders(http2.HeadersFrameParam{
+				StreamID:      f.StreamID,
+				BlockFragment: block,
+				EndHeaders:    true,
+			})
+			framer.WriteData(f.StreamID, true, []byte("ok"))
+			if closeStreams {
+				framer.WriteGoAway(f.StreamID, http2.ErrCodeNo, nil)
+				return
+			}
+		}
+	}
+}
+
+type sliceWriter struct{ b *[]byte }
+
+func (w sliceWriter) Write(p []byte) (int, error) {
+	*w.b = append(*w.b, p...)
+	return len(p), nil
+}
+
+func testCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// browserRequest builds a request carrying the profile's headers, as
+// HTTPClient.Do sends it.
+func browserRequest(t *testing.T, profile *BrowserProfile, target string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("User-Agent", profile.UserAgent)
+	for key, value := range profile.Headers {
+		req.Header.Set(key, value)
+	}
+	return req
+}
+
+func roundTrip(t *testing.T, transport http.RoundTripper, req *http.Request) {
+	t.Helper()
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Fatalf("got %d %q, want 200 ok", resp.StatusCode, body)
+	}
+}
+
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func withoutGREASE[T ~uint16](values []T) []T {
+	var kept []T
+	for _, v := range values {
+		if !isGREASE(uint16(v)) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// expectedHello lists what the ClientHello of spec shows a server.
+type expectedHello struct {
+	ciphers    []uint16
+	extensions []uint16
+	alpn       []string
+	versions   []uint16
+	curves     []tls.CurveID
+	signatures []tls.SignatureScheme
+}
+
+func helloFromSpec(t *testing.T, id utls.ClientHelloID) expectedHello {
+	t.Helper()
+	spec, err := utls.UTLSIdToSpec(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hello := expectedHello{ciphers: withoutGREASE(spec.CipherSuites)}
+	for _, ext := range spec.Extensions {
+		switch e := ext.(type) {
+		case *utls.UtlsGREASEExtension, *utls.UtlsPaddingExtension:
+			continue
+		case *utls.SNIExtension:
+			// Empty until the handshake fills in the server name.
+			hello.extensions = append(hello.extensions, 0)
+			continue
+		case *utls.ALPNExtension:
+			hello.alpn = e.AlpnProtocols
+		case *utls.SupportedVersionsExtension:
+			hello.versions = withoutGREASE(e.Versions)
+		case *utls.SupportedCurvesExtension:
+			for _, curve := range withoutGREASE(e.Curves) {
+				hello.curves = append(hello.curves, tls.CurveID(curve))
+			}
+		case *utls.SignatureAlgorithmsExtension:
+			for _, scheme := range e.SupportedSignatureAlgorithms {
+				hello.signatures = append(hello.signatures, tls.SignatureScheme(scheme))
+			}
+		}
+
+		// Every extension starts with its two-byte type.
+		buf := make([]byte, ext.Len())
+		if _, err := ext.Read(buf); (err != nil && err != io.EOF) || len(buf) < 2 {
+			t.Fatalf("%T: cannot read extension type: %v", ext, err)
+		}
+		hello.extensions = append(hello.extensions, binary.BigEndian.Uint16(buf))
+	}
+	slices.Sort(hello.extensions)
+	return hello
+}
+
+func TestBrowserFingerprint(t *testing.T) {
+	for _, browser := range BrowserImpersonations {
+		profile := BrowserProfiles[browser]
+		if profile == nil {
+			continue
+		}
+		t.Run(string(browser), func(t *testing.T) {
+			server := newFingerprintServer(t, "h2", "http/1.1")
+			transport, err := newBrowserTransport(nil, false, profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer transport.CloseIdleConnections()
+
+			req := browserRequest(t, profile, server.url)
+			req.Header.Set("X-Extra", "1")
+			roundTrip(t, transport, req)
+
+			server.mu.Lock()
+			print := server.prints[0]
+			server.mu.Unlock()
+			want := helloFromSpec(t, profile.ClientHello)
+			got := print.hello
+			if !slices.Equal(withoutGREASE(got.CipherSuites), want.ciphers) {
+				t.Errorf("cipher suites\n got %#04x\nwant %#04x", withoutGREASE(got.CipherSuites), want.ciphers)
+			}
+			// Chrome shuffles its extensions, so only the set is fixed.
+			extensions := slices.Sorted(slices.Values(withoutGREASE(got.Extensions)))
+			extensions = slices.DeleteFunc(extensions, func(id uint16) bool { return id == 21 })
+			if !slices.Equal(extensions, want.extensions) {
+				t.Errorf("extensions\n got %v\nwant %v", extensions, want.extensions)
+			}
+			if !slices.Equal(got.SupportedProtos, want.alpn) {
+				t.Errorf("ALPN = %v, want %v", got.SupportedProtos, want.alpn)
+			}
+			if !slices.Equal(withoutGREASE(got.SupportedVersions), want.versions) {
+				t.Errorf("versions = %#04x, want %#04x", got.SupportedVersions, want.versions)
+			}
+			if !slices.Equal(withoutGREASE(got.SupportedCurves), want.curves) {
+				t.Errorf("curves = %v, want %v", got.SupportedCurves, want.curves)
+			}
+			if !slices.Equal(got.SignatureSchemes, want.signatures) {
+				t.Errorf("signature schemes = %v, want %v", got.SignatureSchemes, want.signatures)
+			}
+
+			if !slices.Equal(print.settings, profile.H2Settings) {
+				t.Errorf("SETTINGS = %v, want %v", print.settings, profile.H2Settings)
+			}
+			if print.windowUpdate != profile.H2WindowUpdate {
+				t.Errorf("WINDOW_UPDATE = %d, want %d", print.windowUpdate, profile.H2WindowUpdate)
+			}
+			if print.priority != profile.H2Priority {
+				t.Errorf("priority = %+v, want %+v", print.priority, profile.H2Priority)
+			}
+			if !slices.Equal(print.pseudo, profile.PseudoHeaderOrder) {
+				t.Errorf("pseudo-headers = %v, want %v", print.pseudo, profile.PseudoHeaderOrder)
+			}
+
+			var order []string
+			for _, name := range profile.HeaderOrder {
+				name = strings.ToLower(name)
+				if req.Header.Get(name) != "" && !hopHeaders[name] {
+					order = append(order, name)
+				}
+			}
+			order = append(order, "x-extra")
+			if !slices.Equal(print.headers, order) {
+				t.Errorf("headers\n got %v\nwant %v", print.headers, order)
+			}
+		})
+	}
+}
+
+func TestBrowserTransportReusesH2(t *testing.T) {
+	profile := BrowserProfiles[BrowserChrome]
+	server := newFingerprintServer(t, "h2", "http/1.1")
+	transport, err := newBrowserTransport(nil, false, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.CloseIdleConnections()
+
+	for i := 0; i < 3; i++ {
+		roundTrip(t, transport, browserRequest(t, profile, server.url))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			roundTrip(t, transport, browserRequest(t, profile, server.url))
+		}()
+	}
+	wg.Wait()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.conns != 1 {
+		t.Errorf("opened %d connections, want 1", server.conns)
+	}
+	if len(server.streams) != 23 || server.streams[0] != 1 || server.streams[1] != 3 {
+		t.Errorf("streams = %v, want 23 starting 1, 3", server.streams)
+	}
+}
+
+func TestBrowserTransportH2StreamLimit(t *testing.T) {
+	profile := BrowserProfiles[BrowserChrome]
+	server := newFingerprintServer(t, "h2")
+	server.maxStreams = 1
+	transport, err := newBrowserTransport(nil, false, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.CloseIdleConnections()
+
+	// Wait for the server's SETTINGS before going concurrent.
+	roundTrip(t, transport, browserRequest(t, profile, server.url))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			roundTrip(t, transport, browserRequest(t, profile, server.url))
+		}()
+	}
+	wg.Wait()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.streams) != 6 {
+		t.Errorf("served %d streams, want 6", len(server.streams))
+	}
+}
+
+func TestBrowserTransportH2GoAway(t *testing.T) {
+	profile := BrowserProfiles[BrowserChrome]
+	server := newFingerprintServer(t, "h2")
+	server.closeStreams = true
+	transport, err := newBrowserTransport(nil, false, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.CloseIdleConnections()
+
+	// Each connection serves one request and then goes away; later
+	// requests must move to a new connection.
+	for i := 0; i < 3; i++ {
+		roundTrip(t, transport, browserRequest(t, profile, server.url))
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.conns != 3 {
+		t.Errorf("opened %d connections, want 3", server.conns)
+	}
+}
+
+func TestBrowserTransportKeepAlive(t *testing.T) {
+	profile := BrowserProfiles[BrowserChrome]
+	var mu sync.Mutex
+	conns := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("close") != "" {
+			w.Header().Set("Connection", "close")
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	transport, err := newBrowserTransport(nil, false, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.CloseIdleConnections()
+
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return conns
+	}
+
+	for i := 0; i < 3; i++ {
+		roundTrip(t, transport, browserRequest(t, profile, server.URL))
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("three requests opened %d connections, want 1", n)
+	}
+
+	// A connection the server closed while idle is replaced.
+	server.CloseClientConnections()
+	roundTrip(t, transport, browserRequest(t, profile, server.URL))
+	if n := count(); n != 2 {
+		t.Fatalf("got %d connections after the server closed one, want 2", n)
+	}
+
+	// Connection: close is honoured.
+	roundTrip(t, transport, browserRequest(t, profile, server.URL+"?close=1"))
+	roundTrip(t, transport, browserRequest(t, profile, server.URL))
+	if n := count(); n != 3 {
+		t.Errorf("got %d connections, want 3", n)
+	}
+}
+
+func TestBrowserTransportKeepAliveTLS(t *testing.T) {
+	profile := BrowserProfiles[BrowserFirefox]
+	server := newFingerprintServer(t, "http/1.1")
+	transport, err := newBrowserTransport(nil, false, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.CloseIdleConnections()
+
+	for i := 0; i < 3; i++ {
+		roundTrip(t, transport, browserRequest(t, profile, server.url))
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.conns != 1 {
+		t.Errorf("opened %d connections, want 1", server.conns)
+	}
+}
+
+func TestBrowserTransportHTTPProxy(t *testing.T) {
+	profile := BrowserProfiles[BrowserChrome]
+	var mu sync.Mutex
+	var targets []string
+	conns := 0
+	proxyServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		targets = append(targets, r.RequestURI)
+		mu.Unlock()
+		fmt.Fprint(w, "ok")
+	}))
+	proxyServer.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	proxyServer.Start()
+	defer proxyServer.Close()
+
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	transport, err := newBrowserTransport(proxyURL, false, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.CloseIdleConnections()
+
+	// Plain http targets share the connection to the proxy.
+	roundTrip(t, transport, browserRequest(t, profile, "http://a.test/user/alice"))
+	roundTrip(t, transport, browserRequest(t, profile, "http://b.test/user/bob"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	if conns != 1 {
+		t.Errorf("opened %d connections to the proxy, want 1", conns)
+	}
+	want := []string{"http://a.test/user/alice", "http://b.test/user/bob"}
+	if !slices.Equal(targets, want) {
+		t.Errorf("proxy saw %v, want %v", targets, want)
+	}
+}
+
+func TestBrowserTransportCancel(t *testing.T) {
+	profile := BrowserProfiles[BrowserChrome]
+	release := make(chan struct{})
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	defer close(release)
+
+	transport, err := newBrowserTransport(nil, false, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.CloseIdleConnections()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := browserRequest(t, profile, server.URL).WithContext(ctx)
+	if _, err := transport.RoundTrip(req); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// h2Peer is the server end of an HTTP/2 connection over net.Pipe, driven
+// frame by frame by the test.
+type h2Peer struct {
+	conn   net.Conn
+	framer *http2.Framer
+	cc     *h2Conn
+}
+
+// newH2Peer connects a client h2Conn to a peer that has read the client's
+// preface, SETTINGS and WINDOW_UPDATE.
+func newH2Peer(t *testing.T) *h2Peer {
+	t.Helper()
+	client, server := net.Pipe()
+	peer := &h2Peer{conn: server, framer: http2.NewFramer(server, server)}
+
+	read := make(chan error, 1)
+	go func() {
+		preface := make([]byte, len(http2.ClientPreface))
+		if _, err := io.ReadFull(server, preface); err != nil {
+			read <- err
+			return
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := peer.framer.ReadFrame(); err != nil {
+				read <- err
+				return
+			}
+		}
+		read <- nil
+	}()
+
+	transport, err := newBrowserTransport(nil, false, BrowserProfiles[BrowserChrome])
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer.cc, err = transport.newH2Conn(context.Background(), "example.test:443", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-read; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+		peer.cc.close(errConnUnusable)
+	})
+	return peer
+}
+
+// closed waits for the client to close the connection.
+func (p *h2Peer) closed(t *testing.T, within time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(within)
+	for time.Now().Before(deadline) {
+		p.cc.mu.Lock()
+		err := p.cc.err
+		p.cc.mu.Unlock()
+		if err != nil {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("connection still open after %s", within)
+}
+
+func TestH2DataBeforeHeaders(t *testing.T) {
+	peer := newH2Peer(t)
+
+	reset := make(chan http2.ErrCode, 1)
+	go func() {
+		for {
+			frame, err := peer.framer.ReadFrame()
+			if err != nil {
+				return
+			}
+			switch f := frame.(type) {
+			case *http2.HeadersFrame:
+				peer.framer.WriteData(f.StreamID, true, []byte("body"))
+			case *http2.RSTStreamFrame:
+				reset <- f.ErrCode
+			}
+		}
+	}()
+
+	req := browserRequest(t, BrowserProfiles[BrowserChrome], "https://example.test/")
+	if resp, err := peer.cc.roundTrip(context.Background(), req, nil); err == nil {
+		t.Fatalf("got %d, want an error for DATA before HEADERS", resp.StatusCode)
+	}
+	select {
+	case code := <-reset:
+		if code != http2.ErrCodeProtocol {
+			t.Errorf("stream reset with %v, want PROTOCOL_ERROR", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("stream was not reset")
+	}
+}
+
+func TestH2WriteStall(t *testing.T) {
+	// The peer stops reading right after the preface.
+	peer := newH2Peer(t)
+	req := browserRequest(t, BrowserProfiles[BrowserChrome], "https://example.test/")
+
+	// Waiting for the write lock gives up with the request's context.
+	peer.cc.lockWrite(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := peer.cc.roundTrip(ctx, req, nil); err != context.DeadlineExceeded {
+		t.Fatalf("waiting for the write lock: %v, want the context's deadline", err)
+	}
+	peer.cc.unlockWrite()
+
+	// A write the peer does not read fails and closes the connection.
+	peer.cc.writeTimeout = 50 * time.Millisecond
+	start := time.Now()
+	if _, err := peer.cc.roundTrip(context.Background(), req, nil); err == nil {
+		t.Fatal("request written to a peer that does not read")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("write gave up after %s", elapsed)
+	}
+	peer.closed(t, time.Second)
+	if _, err := peer.cc.roundTrip(context.Background(), req, nil); err != errConnUnusable {
+		t.Errorf("request on the closed connection: %v, want errConnUnusable", err)
+	}
+}
+
+func TestH2ReadLoopWriteStall(t *testing.T) {
+	peer := newH2Peer(t)
+	peer.cc.writeTimeout = 50 * time.Millisecond
+
+	// The read loop answers the PING, which the peer never reads.
+	if err := peer.framer.WritePing(false, [8]byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	peer.closed(t, 5*time.Second)
+}
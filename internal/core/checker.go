@@ -1,32 +1,63 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/gnomegl/usrsx/internal/client"
 )
 
 type Checker struct {
-	client       *client.HTTPClient
-	wmn          *WMNData
-	maxTasks     int
-	semaphore    chan struct{}
-	progressChan chan SiteResult
+	client   *client.HTTPClient
+	wmn      *WMNData
+	maxTasks int
+	limiter  *HostLimiter
+	retry    RetryPolicy
+
+	// slots holds a token for every check in flight, so that concurrent
+	// calls on one checker stay within maxTasks together.
+	slots chan struct{}
+
+	responses ResponseSaver
+}
+
+type CheckerConfig struct {
+	MaxTasks   int
+	PerHostRPS float64
+	Retry      RetryPolicy
+
+	// Responses, when set, is given every response once its result is
+	// known.
+	Responses ResponseSaver
+}
+
+// ResponseSaver persists the response behind a result and returns where it
+// was written, or "" when it chose not to keep it.
+type ResponseSaver interface {
+	SaveResponse(result SiteResult, resp *HTTPResponse) (string, error)
 }
 
-func NewChecker(httpClient *client.HTTPClient, wmnData *WMNData, maxTasks int) *Checker {
+func NewChecker(httpClient *client.HTTPClient, wmnData *WMNData, config CheckerConfig) *Checker {
+	maxTasks := config.MaxTasks
+	if maxTasks < 1 {
+		maxTasks = 1
+	}
 	return &Checker{
-		client:    httpClient,
-		wmn:       wmnData,
-		maxTasks:  maxTasks,
-		semaphore: make(chan struct{}, maxTasks),
+		client:   httpClient,
+		wmn:      wmnData,
+		maxTasks: maxTasks,
+		limiter:  NewHostLimiter(config.PerHostRPS),
+		slots:    make(chan struct{}, maxTasks),
+		retry:    config.Retry,
+
+		responses: config.Responses,
 	}
 }
 
-func (ch *Checker) CheckSite(site Site, username string, fuzzyMode bool) SiteResult {
+func (ch *Checker) CheckSite(ctx context.Context, site Site, username string, fuzzyMode bool) SiteResult {
 	result := SiteResult{
 		SiteName:  site.Name,
 		Category:  site.Category,
@@ -34,6 +65,10 @@ func (ch *Checker) CheckSite(site Site, username string, fuzzyMode bool) SiteRes
 		CreatedAt: time.Now(),
 	}
 
+	if err := ctx.Err(); err != nil {
+		return cancelledResult(result, err)
+	}
+
 	if site.Name == "" {
 		result.ResultStatus = ResultStatusError
 		result.Error = "Site missing required field: name"
@@ -98,21 +133,19 @@ func (ch *Checker) CheckSite(site Site, username string, fuzzyMode bool) SiteRes
 	}
 	result.ResultURL = uriPretty
 
-	start := time.Now()
-	var resp *HTTPResponse
-	var err error
-
+	postBody := ""
 	if site.PostBody != "" {
-		postBody := strings.ReplaceAll(site.PostBody, AccountPlaceholder, cleanUsername)
-		resp, err = ch.makeRequest(uriCheck, site.Headers, postBody)
-	} else {
-		resp, err = ch.makeRequest(uriCheck, site.Headers, "")
+		postBody = strings.ReplaceAll(site.PostBody, AccountPlaceholder, cleanUsername)
 	}
 
-	elapsed := time.Since(start).Seconds()
-	result.Elapsed = elapsed
+	start := time.Now()
+	resp, err := ch.fetch(ctx, site, uriCheck, postBody, &result)
+	result.Elapsed = time.Since(start).Seconds()
 
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return cancelledResult(result, ctxErr)
+		}
 		result.ResultStatus = ResultStatusError
 		result.Error = fmt.Sprintf("Network error: %v", err)
 		return result
@@ -135,31 +168,86 @@ func (ch *Checker) CheckSite(site Site, username string, fuzzyMode bool) SiteRes
 		result.Metadata = ExtractMetadata(site.Name, resp.Body, resp.StatusCode)
 	}
 
+	if ch.responses != nil {
+		path, err := ch.responses.SaveResponse(result, resp)
+		if err != nil {
+			result.Error = fmt.Sprintf("Failed to save response: %v", err)
+		}
+		result.ResponsePath = path
+	}
+
+	return result
+}
+
+func cancelledResult(result SiteResult, err error) SiteResult {
+	result.ResultStatus = ResultStatusCancelled
+	result.Error = fmt.Sprintf("Check cancelled: %v", err)
 	return result
 }
 
+// fetch performs the request for a single check, retrying according to the
+// checker's RetryPolicy. The number of attempts made and the proxy that
+// served the last one are recorded on result.
+func (ch *Checker) fetch(ctx context.Context, site Site, url, postBody string, result *SiteResult) (*HTTPResponse, error) {
+	host := siteHost(site)
+	proxy := ch.client.SelectProxy(nil)
+
+	for attempt := 1; ; attempt++ {
+		result.Attempts = attempt
+		result.Proxy = proxy.String()
+
+		if err := ch.limiter.Wait(ctx, host, site.RateLimit); err != nil {
+			return nil, err
+		}
+
+		resp, err := ch.makeRequest(ctx, proxy, url, site.Headers, postBody)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		delay, retry := ch.retry.retryDelay(attempt, resp, err)
+		if !retry {
+			return resp, err
+		}
+
+		if ch.retry.RotateProxy {
+			proxy = ch.client.SelectProxy(proxy)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
 type HTTPResponse struct {
 	StatusCode int
+	Status     string
+	Proto      string
+	Header     http.Header
 	Body       string
+
+	// URL is the URL the response came from, after any redirects.
+	URL string
 }
 
-func (ch *Checker) makeRequest(url string, headers map[string]string, postBody string) (*HTTPResponse, error) {
+func (ch *Checker) makeRequest(ctx context.Context, proxy *client.Proxy, url string, headers map[string]string, postBody string) (*HTTPResponse, error) {
+	req := client.Request{
+		Method:  http.MethodGet,
+		URL:     url,
+		Headers: headers,
+		Proxy:   proxy,
+	}
 	if postBody != "" {
-		httpResp, httpErr := ch.client.Post(url, headers, postBody)
-		if httpErr != nil {
-			return nil, httpErr
-		}
-		body, readErr := client.ReadResponseBody(httpResp)
-		if readErr != nil {
-			return nil, readErr
-		}
-		return &HTTPResponse{
-			StatusCode: httpResp.StatusCode,
-			Body:       body,
-		}, nil
+		req.Method = http.MethodPost
+		req.Body = postBody
 	}
 
-	httpResp, httpErr := ch.client.Get(url, headers)
+	httpResp, httpErr := ch.client.Do(ctx, req)
 	if httpErr != nil {
 		return nil, httpErr
 	}
@@ -167,85 +255,75 @@ func (ch *Checker) makeRequest(url string, headers map[string]string, postBody s
 	if readErr != nil {
 		return nil, readErr
 	}
+	finalURL := url
+	if httpResp.Request != nil && httpResp.Request.URL != nil {
+		finalURL = httpResp.Request.URL.String()
+	}
 	return &HTTPResponse{
 		StatusCode: httpResp.StatusCode,
+		Status:     httpResp.Status,
+		Proto:      httpResp.Proto,
+		Header:     httpResp.Header,
 		Body:       body,
+		URL:        finalURL,
 	}, nil
 }
 
-func (ch *Checker) CheckUsernames(usernames []string, sites []Site, fuzzyMode bool, progressChan chan<- SiteResult) []SiteResult {
-	var wg sync.WaitGroup
-	results := make([]SiteResult, 0)
-	resultsMu := sync.Mutex{}
-
-	for _, username := range usernames {
-		for _, site := range sites {
-			wg.Add(1)
-			go func(u string, s Site) {
-				defer wg.Done()
-
-				ch.semaphore <- struct{}{}
-				defer func() { <-ch.semaphore }()
-
-				result := ch.CheckSite(s, u, fuzzyMode)
-
-				if progressChan != nil {
-					progressChan <- result
-				}
-
-				resultsMu.Lock()
-				results = append(results, result)
-				resultsMu.Unlock()
-			}(username, site)
+// CheckUsernames checks every username on every site and sends each result
+// on results as it completes, returning once all of them are sent. The
+// caller must drain results while it runs; a nil channel discards them.
+func (ch *Checker) CheckUsernames(ctx context.Context, usernames []string, sites []Site, fuzzyMode bool, results chan<- SiteResult) {
+	jobs := make(chan checkJob, ch.maxTasks)
+	go func() {
+		defer close(jobs)
+		for _, username := range usernames {
+			for _, site := range sites {
+				jobs <- checkJob{username: username, site: site}
+			}
 		}
-	}
+	}()
 
-	wg.Wait()
-	return results
+	ch.runWorkers(func() {
+		for job := range jobs {
+			result := ch.checkInSlot(ctx, job.site, job.username, fuzzyMode)
+			if results != nil {
+				results <- result
+			}
+		}
+	})
 }
 
-func (ch *Checker) SelfCheck(sites []Site, fuzzyMode bool, progressChan chan<- SelfCheckResult) []SelfCheckResult {
-	var wg sync.WaitGroup
-	results := make([]SelfCheckResult, 0)
-	resultsMu := sync.Mutex{}
-
-	for _, site := range sites {
-		if len(site.Known) == 0 {
-			continue
+// SelfCheck checks the known accounts of every site that lists some and
+// sends one result per site on results, like CheckUsernames.
+func (ch *Checker) SelfCheck(ctx context.Context, sites []Site, fuzzyMode bool, results chan<- SelfCheckResult) {
+	jobs := make(chan Site, ch.maxTasks)
+	go func() {
+		defer close(jobs)
+		for _, site := range sites {
+			if len(site.Known) > 0 {
+				jobs <- site
+			}
 		}
+	}()
 
-		wg.Add(1)
-		go func(s Site) {
-			defer wg.Done()
-
+	ch.runWorkers(func() {
+		for site := range jobs {
 			selfCheckResult := SelfCheckResult{
-				SiteName:  s.Name,
-				Category:  s.Category,
+				SiteName:  site.Name,
+				Category:  site.Category,
 				CreatedAt: time.Now(),
 			}
 
-			var siteResults []SiteResult
-			for _, knownUser := range s.Known {
-				ch.semaphore <- struct{}{}
-				result := ch.CheckSite(s, knownUser, fuzzyMode)
-				<-ch.semaphore
-
-				siteResults = append(siteResults, result)
+			siteResults := make([]SiteResult, 0, len(site.Known))
+			for _, knownUser := range site.Known {
+				siteResults = append(siteResults, ch.checkInSlot(ctx, site, knownUser, fuzzyMode))
 			}
 
 			selfCheckResult.Results = siteResults
 			selfCheckResult.OverallStatus = GetOverallStatus(siteResults, "")
-
-			if progressChan != nil {
-				progressChan <- selfCheckResult
+			if results != nil {
+				results <- selfCheckResult
 			}
-
-			resultsMu.Lock()
-			results = append(results, selfCheckResult)
-			resultsMu.Unlock()
-		}(site)
-	}
-
-	wg.Wait()
-	return results
+		}
+	})
 }
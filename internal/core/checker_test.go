@@ -0,0 +1,170 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gnomegl/usrsx/internal/client"
+)
+
+// newTestServer answers /<site>/<username> with a profile page for
+// usernames starting with "found" and a 404 for the rest.
+func newTestServer(tb testing.TB) *httptest.Server {
+	tb.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/found") {
+			fmt.Fprint(w, "<title>profile</title>")
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "nope")
+	}))
+	tb.Cleanup(server.Close)
+	return server
+}
+
+func testSites(baseURL string, n int) []Site {
+	found, missing := http.StatusOK, http.StatusNotFound
+	sites := make([]Site, n)
+	for i := range sites {
+		sites[i] = Site{
+			Name:     fmt.Sprintf("site%d", i),
+			Category: "test",
+			URICheck: fmt.Sprintf("%s/site%d/%s", baseURL, i, AccountPlaceholder),
+			ECode:    &found,
+			EString:  "profile",
+			MCode:    &missing,
+			MString:  "nope",
+		}
+	}
+	return sites
+}
+
+func newTestChecker(tb testing.TB, maxTasks int) *Checker {
+	tb.Helper()
+	httpClient, err := client.NewHTTPClient(client.ClientConfig{
+		Timeout:     5,
+		Impersonate: client.BrowserNone,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return NewChecker(httpClient, nil, CheckerConfig{MaxTasks: maxTasks})
+}
+
+func TestCheckUsernames(t *testing.T) {
+	server := newTestServer(t)
+	checker := newTestChecker(t, 4)
+	sites := testSites(server.URL, 10)
+
+	results := make(chan SiteResult)
+	go func() {
+		checker.CheckUsernames(context.Background(), []string{"found1", "missing1"}, sites, false, results)
+		close(results)
+	}()
+
+	counts := make(map[ResultStatus]int)
+	for result := range results {
+		counts[result.ResultStatus]++
+	}
+	if counts[ResultStatusFound] != 10 || counts[ResultStatusNotFound] != 10 || len(counts) != 2 {
+		t.Errorf("got %v, want 10 found and 10 not_found", counts)
+	}
+}
+
+func TestCheckUsernamesNilResults(t *testing.T) {
+	server := newTestServer(t)
+	checker := newTestChecker(t, 2)
+
+	// Returns instead of blocking on the nil channel.
+	checker.CheckUsernames(context.Background(), []string{"found1"}, testSites(server.URL, 5), false, nil)
+	checker.SelfCheck(context.Background(), testSites(server.URL, 5), false, nil)
+}
+
+func TestCheckUsernamesCancelled(t *testing.T) {
+	server := newTestServer(t)
+	checker := newTestChecker(t, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := make(chan SiteResult)
+	go func() {
+		checker.CheckUsernames(ctx, []string{"found1"}, testSites(server.URL, 5), false, results)
+		close(results)
+	}()
+	n := 0
+	for result := range results {
+		n++
+		if result.ResultStatus != ResultStatusCancelled {
+			t.Errorf("%s: got %s, want cancelled", result.SiteName, result.ResultStatus)
+		}
+	}
+	if n != 5 {
+		t.Errorf("got %d results, want 5", n)
+	}
+}
+
+// fanOut is the scheduling CheckUsernames replaced: a goroutine per check,
+// with a semaphore bounding the requests in flight.
+func fanOut(ctx context.Context, ch *Checker, usernames []string, sites []Site, results chan<- SiteResult) {
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, ch.maxTasks)
+	for _, username := range usernames {
+		for _, site := range sites {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+				results <- ch.CheckSite(ctx, site, username, false)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func BenchmarkCheckUsernames(b *testing.B) {
+	server := newTestServer(b)
+	sites := testSites(server.URL, 200)
+	usernames := []string{"found1", "missing1", "found2", "missing2", "found3"}
+
+	schedulers := []struct {
+		name string
+		run  func(context.Context, *Checker, chan<- SiteResult)
+	}{
+		{"pool", func(ctx context.Context, ch *Checker, results chan<- SiteResult) {
+			ch.CheckUsernames(ctx, usernames, sites, false, results)
+		}},
+		{"fan-out", func(ctx context.Context, ch *Checker, results chan<- SiteResult) {
+			fanOut(ctx, ch, usernames, sites, results)
+		}},
+	}
+
+	for _, scheduler := range schedulers {
+		for _, maxTasks := range []int{10, 50} {
+			b.Run(fmt.Sprintf("%s/tasks=%d", scheduler.name, maxTasks), func(b *testing.B) {
+				checker := newTestChecker(b, maxTasks)
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					results := make(chan SiteResult, maxTasks)
+					done := make(chan struct{})
+					go func() {
+						for range results {
+						}
+						close(done)
+					}()
+					scheduler.run(context.Background(), checker, results)
+					close(results)
+					<-done
+				}
+				b.ReportMetric(float64(len(usernames)*len(sites)), "checks/op")
+			})
+		}
+	}
+}
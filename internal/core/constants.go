@@ -4,12 +4,45 @@ const (
 	WMNRemoteURL = "https://raw.githubusercontent.com/WebBreacher/WhatsMyName/main/wmn-data.json"
 	WMNSchemaURL = "https://raw.githubusercontent.com/WebBreacher/WhatsMyName/main/wmn-data-schema.json"
 
+	WMNCacheMaxAgeHours = 24
+
 	HTTPRequestTimeoutSeconds = 30
 	HTTPSSLVerify             = false
 	HTTPAllowRedirects        = false
 
 	MaxConcurrentTasks = 50
 
+	RetryMaxAttempts      = 3
+	RetryBaseDelayMillis  = 500
+	RetryMaxDelaySeconds  = 30
+	MaxRetryAttemptsLimit = 10
+
+	ProxyMaxFailures     = 3
+	ProxyCooldownSeconds = 60
+
+	ResponseDir = "responses"
+
+	BrowseLimit          = 10
+	BrowseIntervalMillis = 200
+
+	ProgressEventIntervalMillis = 1000
+
+	WatchIntervalHours      = 6
+	WatchMinIntervalSeconds = 60
+
+	NotifyBatchSize         = 10
+	NotifyFlushSeconds      = 5
+	NotifyMinIntervalMillis = 1000
+	NotifyMaxAttempts       = 3
+	NotifyRetryDelayMillis  = 1000
+
+	ServeListenAddr        = "127.0.0.1:8080"
+	ServeMaxJobs           = 100
+	ServeMaxBodyBytes      = 1 << 20
+	ServeKeepAliveSeconds  = 15
+	ServeShutdownSeconds   = 10
+	ServeReadHeaderSeconds = 10
+
 	MinTasks      = 1
 	MaxTasksLimit = 1000
 	MinTimeout    = 0
@@ -13,6 +13,7 @@ const (
 	ResultStatusUnknown   ResultStatus = "unknown"
 	ResultStatusAmbiguous ResultStatus = "ambiguous"
 	ResultStatusNotValid  ResultStatus = "not_valid"
+	ResultStatusCancelled ResultStatus = "cancelled"
 )
 
 type ProfileMetadata struct {
@@ -37,10 +38,17 @@ type SiteResult struct {
 	ResultURL    string           `json:"result_url,omitempty"`
 	ResponseCode int              `json:"response_code,omitempty"`
 	ResponseText string           `json:"response_text,omitempty"`
+	ResponsePath string           `json:"response_path,omitempty"`
 	Metadata     *ProfileMetadata `json:"metadata,omitempty"`
 	Elapsed      float64          `json:"elapsed,omitempty"`
+	Attempts     int              `json:"attempts,omitempty"`
+	Proxy        string           `json:"proxy,omitempty"`
 	Error        string           `json:"error,omitempty"`
 	CreatedAt    time.Time        `json:"created_at"`
+
+	// FalsePositive is set when a reviewer has marked the result as wrong
+	// in the results browser.
+	FalsePositive bool `json:"false_positive,omitempty"`
 }
 
 type Site struct {
@@ -56,6 +64,7 @@ type Site struct {
 	PostBody     string            `json:"post_body,omitempty"`
 	Headers      map[string]string `json:"headers,omitempty"`
 	StripBadChar string            `json:"strip_bad_char,omitempty"`
+	RateLimit    float64           `json:"rate_limit,omitempty"`
 }
 
 type WMNData struct {
@@ -63,6 +72,17 @@ type WMNData struct {
 	Categories []string `json:"categories"`
 	Authors    []string `json:"authors"`
 	License    []string `json:"license"`
+
+	// Lists records which copy of each site list was loaded.
+	Lists []SiteListVersion `json:"-"`
+}
+
+// SiteListVersion identifies a site list by the hash of the copy that was
+// loaded, so runs can tell whether they used the same list.
+type SiteListVersion struct {
+	Source string `json:"source"`
+	SHA256 string `json:"sha256"`
+	Sites  int    `json:"sites"`
 }
 
 type SelfCheckResult struct {
@@ -154,6 +174,9 @@ func GetOverallStatus(results []SiteResult, err string) ResultStatus {
 	if statuses[ResultStatusError] {
 		return ResultStatusError
 	}
+	if statuses[ResultStatusCancelled] {
+		return ResultStatusCancelled
+	}
 	if len(statuses) > 1 {
 		return ResultStatusUnknown
 	}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimiter spaces out requests per host with one token bucket per host,
+// so a multi-username scan cannot hammer a single site while requests to
+// other hosts still proceed in parallel.
+type HostLimiter struct {
+	defaultRPS float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func NewHostLimiter(defaultRPS float64) *HostLimiter {
+	return &HostLimiter{
+		defaultRPS: defaultRPS,
+		buckets:    make(map[string]*tokenBucket),
+	}
+}
+
+// Wait blocks until a request to host may be sent. siteRPS overrides the
+// default rate for the whole host when positive, whether it is lower or
+// higher; if sites sharing a host set different rates, the lowest one
+// wins. A rate of zero disables limiting.
+func (l *HostLimiter) Wait(ctx context.Context, host string, siteRPS float64) error {
+	rps := l.defaultRPS
+	if siteRPS > 0 {
+		rps = siteRPS
+	}
+	if rps <= 0 || host == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	bucket, ok := l.buckets[host]
+	if !ok {
+		bucket = newTokenBucket(rps)
+		bucket.override = siteRPS > 0
+		l.buckets[host] = bucket
+	} else if siteRPS > 0 {
+		bucket.overrideRate(siteRPS)
+	}
+	l.mu.Unlock()
+
+	delay := bucket.reserve(time.Now())
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		bucket.refund()
+		return ctx.Err()
+	}
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+	// override is set once a site's own rate replaced the default.
+	override bool
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		tokens: 1,
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token and returns how long the caller has to wait before
+// it becomes valid. Tokens may go negative, which queues callers in order.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > 1 {
+			b.tokens = 1
+		}
+		b.last = now
+	}
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) refund() {
+	b.mu.Lock()
+	b.tokens++
+	b.mu.Unlock()
+}
+
+// overrideRate replaces the default rate with a site's own, or lowers the
+// rate another site already set.
+func (b *tokenBucket) overrideRate(rate float64) {
+	b.mu.Lock()
+	if !b.override || rate < b.rate {
+		b.rate = rate
+		b.override = true
+	}
+	b.mu.Unlock()
+}
+
+// siteHost returns the rate-limiting key for a site. The account placeholder
+// is dropped first so that per-user subdomains such as
+// "{account}.tumblr.com" share a bucket for their parent domain.
+func siteHost(site Site) string {
+	u, err := url.Parse(strings.ReplaceAll(site.URICheck, AccountPlaceholder, ""))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimLeft(strings.ToLower(u.Hostname()), ".")
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketReserve(t *testing.T) {
+	start := time.Now()
+	bucket := &tokenBucket{rate: 2, tokens: 1, last: start}
+
+	// The first token is free, the next ones queue up 1/rate apart.
+	want := []time.Duration{0, 500 * time.Millisecond, time.Second}
+	for i, w := range want {
+		if got := bucket.reserve(start); got != w {
+			t.Errorf("reserve %d: got %s, want %s", i, got, w)
+		}
+	}
+
+	// After the queue has drained, tokens refill up to one.
+	later := start.Add(10 * time.Second)
+	if got := bucket.reserve(later); got != 0 {
+		t.Errorf("after refill: got %s, want 0", got)
+	}
+	if got := bucket.reserve(later); got != 500*time.Millisecond {
+		t.Errorf("burst is capped at one token: got %s, want 500ms", got)
+	}
+}
+
+func TestTokenBucketOverrideRate(t *testing.T) {
+	bucket := newTokenBucket(10)
+	bucket.overrideRate(20)
+	if bucket.rate != 20 {
+		t.Errorf("got rate %v, want the site's 20 over the default", bucket.rate)
+	}
+	bucket.overrideRate(1)
+	bucket.overrideRate(5)
+	if bucket.rate != 1 {
+		t.Errorf("got rate %v, want the lowest site rate, 1", bucket.rate)
+	}
+}
+
+func TestHostLimiterSiteRate(t *testing.T) {
+	ctx := context.Background()
+
+	// A site may raise the rate of its host above the default, even after
+	// requests at the default rate created the bucket.
+	limiter := NewHostLimiter(1)
+	start := time.Now()
+	if err := limiter.Wait(ctx, "a.example", 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait(ctx, "a.example", 50); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("four requests at a site rate of 50 rps took %s", elapsed)
+	}
+
+	// And lower it, for requests at the default rate as well.
+	limiter = NewHostLimiter(50)
+	if err := limiter.Wait(ctx, "b.example", 10); err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	if err := limiter.Wait(ctx, "b.example", 0); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("second request at a site rate of 10 rps waited %s, want about 100ms", elapsed)
+	}
+}
+
+func TestHostLimiterWait(t *testing.T) {
+	limiter := NewHostLimiter(20)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait(ctx, "a.example", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two waits of 50ms each.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("three requests at 20 rps took %s, want at least 100ms", elapsed)
+	}
+
+	// Another host has its own bucket.
+	start = time.Now()
+	if err := limiter.Wait(ctx, "b.example", 0); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("first request to another host waited %s", elapsed)
+	}
+}
+
+func TestHostLimiterUnlimited(t *testing.T) {
+	limiter := NewHostLimiter(0)
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if err := limiter.Wait(context.Background(), "a.example", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("unlimited waits took %s", elapsed)
+	}
+}
+
+func TestHostLimiterCancel(t *testing.T) {
+	limiter := NewHostLimiter(0.1)
+	if err := limiter.Wait(context.Background(), "a.example", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, "a.example", 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the context's error", err)
+	}
+
+	// The cancelled wait gave its token back, so the queue is one deep
+	// again rather than two.
+	bucket := limiter.buckets["a.example"]
+	if bucket.tokens < -0.01 {
+		t.Errorf("tokens = %v after refund, want about 0", bucket.tokens)
+	}
+}
+
+func TestSiteHost(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"https://Example.com/user/{account}", "example.com"},
+		{"https://{account}.tumblr.com", "tumblr.com"},
+		{"http://127.0.0.1:8080/{account}", "127.0.0.1"},
+		{"://bad", ""},
+	}
+	for _, tt := range tests {
+		if got := siteHost(Site{URICheck: tt.uri}); got != tt.want {
+			t.Errorf("siteHost(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func response(code int, retryAfter string) *HTTPResponse {
+	header := http.Header{}
+	if retryAfter != "" {
+		header.Set("Retry-After", retryAfter)
+	}
+	return &HTTPResponse{StatusCode: code, Header: header}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    time.Second,
+		StatusCodes: DefaultRetryStatusCodes,
+	}
+
+	tests := []struct {
+		name    string
+		attempt int
+		resp    *HTTPResponse
+		err     error
+		retry   bool
+		// max bounds the jittered delay; exact is checked when set.
+		max   time.Duration
+		exact time.Duration
+	}{
+		{name: "timeout", attempt: 1, err: timeoutError{}, retry: true, max: 100 * time.Millisecond},
+		{name: "reset", attempt: 2, err: fmt.Errorf("read: %w", syscall.ECONNRESET), retry: true, max: 200 * time.Millisecond},
+		{name: "unexpected EOF", attempt: 1, err: io.ErrUnexpectedEOF, retry: true, max: 100 * time.Millisecond},
+		{name: "permanent error", attempt: 1, err: errors.New("no such host"), retry: false},
+		{name: "last attempt", attempt: 3, err: timeoutError{}, retry: false},
+		{name: "503", attempt: 1, resp: response(503, ""), retry: true, max: 100 * time.Millisecond},
+		{name: "429 with Retry-After", attempt: 1, resp: response(429, "1"), retry: true, exact: time.Second},
+		{name: "Retry-After past MaxDelay", attempt: 1, resp: response(429, "5"), retry: false},
+		{name: "404", attempt: 1, resp: response(404, ""), retry: false},
+		{name: "200", attempt: 1, resp: response(200, ""), retry: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, retry := policy.retryDelay(tt.attempt, tt.resp, tt.err)
+			if retry != tt.retry {
+				t.Fatalf("retry = %v, want %v", retry, tt.retry)
+			}
+			if tt.exact > 0 && delay != tt.exact {
+				t.Errorf("delay = %s, want %s", delay, tt.exact)
+			}
+			if tt.max > 0 && (delay < 0 || delay > tt.max) {
+				t.Errorf("delay = %s, want within [0, %s]", delay, tt.max)
+			}
+		})
+	}
+}
+
+func TestBackoffCap(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for i := 0; i < 100; i++ {
+		if delay := policy.backoff(10); delay > 300*time.Millisecond {
+			t.Fatalf("backoff(10) = %s, want at most MaxDelay", delay)
+		}
+	}
+	if delay := (RetryPolicy{}).backoff(3); delay != 0 {
+		t.Errorf("backoff without BaseDelay = %s, want 0", delay)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"120", 2 * time.Minute, true},
+		{"-5", 0, false},
+		{"soon", 0, false},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		// A date in the past means retry right away.
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %v; want %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCheckSiteRetries(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if hits < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "<title>profile</title>")
+	}))
+	defer server.Close()
+
+	checker := newTestChecker(t, 1)
+	checker.retry = RetryPolicy{MaxAttempts: 3, StatusCodes: DefaultRetryStatusCodes}
+
+	result := checker.CheckSite(context.Background(), testSites(server.URL, 1)[0], "found1", false)
+	if result.ResultStatus != ResultStatusFound || result.Attempts != 3 {
+		t.Errorf("got %s after %d attempts, want found after 3", result.ResultStatus, result.Attempts)
+	}
+}
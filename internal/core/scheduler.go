@@ -0,0 +1,42 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// checkJob is a single (username, site) pair queued for a worker. Jobs are
+// produced lazily by CheckUsernames so memory stays proportional to the
+// worker count rather than to the size of the batch.
+type checkJob struct {
+	username string
+	site     Site
+}
+
+// runWorkers starts maxTasks copies of work and blocks until all of them
+// return. Each worker is expected to drain a shared job channel.
+func (ch *Checker) runWorkers(work func()) {
+	var wg sync.WaitGroup
+	for i := 0; i < ch.maxTasks; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work()
+		}()
+	}
+	wg.Wait()
+}
+
+// checkInSlot runs CheckSite once one of the checker's maxTasks slots is
+// free. Each call starts its own workers, so without the slots two scans
+// sharing a checker would run twice as many checks at once. A check whose
+// context ends while it waits runs without a slot, which only produces
+// its cancelled result.
+func (ch *Checker) checkInSlot(ctx context.Context, site Site, username string, fuzzyMode bool) SiteResult {
+	select {
+	case ch.slots <- struct{}{}:
+		defer func() { <-ch.slots }()
+	case <-ctx.Done():
+	}
+	return ch.CheckSite(ctx, site, username, fuzzyMode)
+}
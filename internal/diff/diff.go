@@ -0,0 +1,197 @@
+// Package diff compares two scans of the same usernames. Results are
+// matched by username and site; a result marked as a false positive counts
+// as not found.
+package diff
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+type Kind string
+
+const (
+	// KindAppeared is an account that is found now and was not before,
+	// including on sites the old scan did not check.
+	KindAppeared Kind = "appeared"
+	// KindDisappeared is an account that was found before and is now
+	// reported as not found.
+	KindDisappeared Kind = "disappeared"
+	// KindStatusChanged is any other change of result status, such as a
+	// found account that now errors.
+	KindStatusChanged Kind = "status_changed"
+	// KindMetadataChanged is an account found in both scans whose profile
+	// metadata differs.
+	KindMetadataChanged Kind = "metadata_changed"
+)
+
+// kinds is the order changes are reported in.
+var kinds = []Kind{KindAppeared, KindDisappeared, KindStatusChanged, KindMetadataChanged}
+
+// Scan describes one side of a comparison.
+type Scan struct {
+	Timestamp time.Time `json:"timestamp"`
+	Usernames []string  `json:"usernames"`
+}
+
+type Report struct {
+	Old     Scan     `json:"old"`
+	New     Scan     `json:"new"`
+	Summary Summary  `json:"summary"`
+	Changes []Change `json:"changes"`
+}
+
+type Summary struct {
+	Appeared        int `json:"appeared"`
+	Disappeared     int `json:"disappeared"`
+	StatusChanged   int `json:"status_changed"`
+	MetadataChanged int `json:"metadata_changed"`
+	Unchanged       int `json:"unchanged"`
+}
+
+type Change struct {
+	Kind     Kind   `json:"kind"`
+	Username string `json:"username"`
+	SiteName string `json:"site_name"`
+	Category string `json:"category"`
+	URL      string `json:"url,omitempty"`
+
+	// OldStatus is empty when the old scan did not check the site.
+	OldStatus core.ResultStatus `json:"old_status,omitempty"`
+	NewStatus core.ResultStatus `json:"new_status"`
+
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange is a changed profile field. Delta is set for counts.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+	Delta *int   `json:"delta,omitempty"`
+}
+
+// Changed reports whether the comparison found any change.
+func (r Report) Changed() bool {
+	return len(r.Changes) > 0
+}
+
+// Compare matches the results of two scans by username and site. Sites the
+// new scan did not check are left out. Report.Old and Report.New are left
+// for the caller to fill in.
+func Compare(old, current []core.SiteResult) Report {
+	previous := make(map[string]core.SiteResult, len(old))
+	for _, result := range old {
+		previous[key(result)] = result
+	}
+
+	var report Report
+	for _, after := range current {
+		before, checked := previous[key(after)]
+		change := Change{
+			Username:  after.Username,
+			SiteName:  after.SiteName,
+			Category:  after.Category,
+			URL:       after.ResultURL,
+			NewStatus: status(after),
+		}
+		if checked {
+			change.OldStatus = status(before)
+			if change.URL == "" {
+				change.URL = before.ResultURL
+			}
+		}
+
+		switch {
+		case change.OldStatus == change.NewStatus:
+			if change.NewStatus != core.ResultStatusFound {
+				report.Summary.Unchanged++
+				continue
+			}
+			change.Fields = compareMetadata(before.Metadata, after.Metadata)
+			if len(change.Fields) == 0 {
+				report.Summary.Unchanged++
+				continue
+			}
+			change.Kind = KindMetadataChanged
+			report.Summary.MetadataChanged++
+		case change.NewStatus == core.ResultStatusFound:
+			change.Kind = KindAppeared
+			report.Summary.Appeared++
+		case change.OldStatus == core.ResultStatusFound && change.NewStatus == core.ResultStatusNotFound:
+			change.Kind = KindDisappeared
+			report.Summary.Disappeared++
+		case !checked:
+			report.Summary.Unchanged++
+			continue
+		default:
+			change.Kind = KindStatusChanged
+			report.Summary.StatusChanged++
+		}
+		report.Changes = append(report.Changes, change)
+	}
+
+	order := make(map[Kind]int, len(kinds))
+	for i, kind := range kinds {
+		order[kind] = i
+	}
+	sort.SliceStable(report.Changes, func(i, j int) bool {
+		a, b := report.Changes[i], report.Changes[j]
+		if a.Kind != b.Kind {
+			return order[a.Kind] < order[b.Kind]
+		}
+		if a.Username != b.Username {
+			return a.Username < b.Username
+		}
+		return strings.ToLower(a.SiteName) < strings.ToLower(b.SiteName)
+	})
+	return report
+}
+
+func key(result core.SiteResult) string {
+	return result.Username + "\x00" + result.SiteName
+}
+
+// status is the result's status with false positives counted as not
+// found.
+func status(result core.SiteResult) core.ResultStatus {
+	if result.FalsePositive && result.ResultStatus == core.ResultStatusFound {
+		return core.ResultStatusNotFound
+	}
+	return result.ResultStatus
+}
+
+// compareMetadata returns the profile fields that differ. Metadata missing
+// on either side is not a change: extraction fails too often for that to
+// mean anything.
+func compareMetadata(old, current *core.ProfileMetadata) []FieldChange {
+	if old == nil || current == nil {
+		return nil
+	}
+
+	var fields []FieldChange
+	text := func(field, a, b string) {
+		if a != b {
+			fields = append(fields, FieldChange{Field: field, Old: a, New: b})
+		}
+	}
+	count := func(field string, a, b int) {
+		if a != b {
+			delta := b - a
+			fields = append(fields, FieldChange{Field: field, Old: strconv.Itoa(a), New: strconv.Itoa(b), Delta: &delta})
+		}
+	}
+
+	text("display_name", old.DisplayName, current.DisplayName)
+	text("bio", old.Bio, current.Bio)
+	text("location", old.Location, current.Location)
+	text("website", old.Website, current.Website)
+	count("follower_count", old.FollowerCount, current.FollowerCount)
+	count("following_count", old.FollowingCount, current.FollowingCount)
+	text("is_verified", strconv.FormatBool(old.IsVerified), strconv.FormatBool(current.IsVerified))
+	return fields
+}
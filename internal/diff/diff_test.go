@@ -0,0 +1,174 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+func result(site string, status core.ResultStatus) core.SiteResult {
+	return core.SiteResult{SiteName: site, Category: "social", Username: "alice", ResultStatus: status}
+}
+
+func withMetadata(r core.SiteResult, md *core.ProfileMetadata) core.SiteResult {
+	r.Metadata = md
+	return r
+}
+
+func falsePositive(r core.SiteResult) core.SiteResult {
+	r.FalsePositive = true
+	return r
+}
+
+func TestCompare(t *testing.T) {
+	const (
+		found    = core.ResultStatusFound
+		notFound = core.ResultStatusNotFound
+		errored  = core.ResultStatusError
+		unknown  = core.ResultStatusUnknown
+	)
+
+	tests := []struct {
+		name    string
+		old     []core.SiteResult
+		current []core.SiteResult
+		kind    Kind // empty when no change is expected
+		oldStat core.ResultStatus
+	}{
+		{name: "still found", old: []core.SiteResult{result("A", found)}, current: []core.SiteResult{result("A", found)}},
+		{name: "still not found", old: []core.SiteResult{result("A", notFound)}, current: []core.SiteResult{result("A", notFound)}},
+		{name: "appeared", old: []core.SiteResult{result("A", notFound)}, current: []core.SiteResult{result("A", found)}, kind: KindAppeared, oldStat: notFound},
+		{name: "appeared after error", old: []core.SiteResult{result("A", errored)}, current: []core.SiteResult{result("A", found)}, kind: KindAppeared, oldStat: errored},
+		{name: "appeared on new site", current: []core.SiteResult{result("A", found)}, kind: KindAppeared},
+		{name: "not found on new site", current: []core.SiteResult{result("A", notFound)}},
+		{name: "errored on new site", current: []core.SiteResult{result("A", errored)}},
+		{name: "disappeared", old: []core.SiteResult{result("A", found)}, current: []core.SiteResult{result("A", notFound)}, kind: KindDisappeared, oldStat: found},
+		{name: "found now errors", old: []core.SiteResult{result("A", found)}, current: []core.SiteResult{result("A", errored)}, kind: KindStatusChanged, oldStat: found},
+		{name: "error to unknown", old: []core.SiteResult{result("A", errored)}, current: []core.SiteResult{result("A", unknown)}, kind: KindStatusChanged, oldStat: errored},
+		{name: "marked false positive", old: []core.SiteResult{result("A", found)}, current: []core.SiteResult{falsePositive(result("A", found))}, kind: KindDisappeared, oldStat: found},
+		{name: "false positive in both", old: []core.SiteResult{falsePositive(result("A", found))}, current: []core.SiteResult{falsePositive(result("A", found))}},
+		{name: "site dropped from new scan", old: []core.SiteResult{result("A", found)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Compare(tt.old, tt.current)
+			if tt.kind == "" {
+				if report.Changed() {
+					t.Fatalf("got changes %+v, want none", report.Changes)
+				}
+				if want := len(tt.current); report.Summary.Unchanged != want {
+					t.Errorf("Unchanged = %d, want %d", report.Summary.Unchanged, want)
+				}
+				return
+			}
+			if len(report.Changes) != 1 {
+				t.Fatalf("got changes %+v, want one %s", report.Changes, tt.kind)
+			}
+			change := report.Changes[0]
+			if change.Kind != tt.kind || change.OldStatus != tt.oldStat || change.NewStatus != status(tt.current[0]) {
+				t.Errorf("got %s %s -> %s, want %s %s -> %s",
+					change.Kind, change.OldStatus, change.NewStatus, tt.kind, tt.oldStat, status(tt.current[0]))
+			}
+		})
+	}
+}
+
+func TestCompareMetadata(t *testing.T) {
+	before := &core.ProfileMetadata{DisplayName: "Alice", Bio: "hi", FollowerCount: 10, FollowingCount: 5}
+	after := &core.ProfileMetadata{DisplayName: "Alice B", Bio: "hi", FollowerCount: 7, FollowingCount: 5, IsVerified: true,
+		AvatarURL: "https://example.com/new.png"}
+
+	report := Compare(
+		[]core.SiteResult{withMetadata(result("A", core.ResultStatusFound), before)},
+		[]core.SiteResult{withMetadata(result("A", core.ResultStatusFound), after)},
+	)
+	if len(report.Changes) != 1 || report.Changes[0].Kind != KindMetadataChanged || report.Summary.MetadataChanged != 1 {
+		t.Fatalf("got %+v, want one metadata change", report)
+	}
+
+	delta := -3
+	want := []FieldChange{
+		{Field: "display_name", Old: "Alice", New: "Alice B"},
+		{Field: "follower_count", Old: "10", New: "7", Delta: &delta},
+		{Field: "is_verified", Old: "false", New: "true"},
+	}
+	if got := report.Changes[0].Fields; !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %+v, want %+v", got, want)
+	}
+
+	// Metadata missing on one side is not a change.
+	for _, pair := range [][2]*core.ProfileMetadata{{nil, after}, {before, nil}} {
+		report := Compare(
+			[]core.SiteResult{withMetadata(result("A", core.ResultStatusFound), pair[0])},
+			[]core.SiteResult{withMetadata(result("A", core.ResultStatusFound), pair[1])},
+		)
+		if report.Changed() {
+			t.Errorf("missing metadata reported as %+v", report.Changes)
+		}
+	}
+}
+
+func TestCompareMatching(t *testing.T) {
+	bob := result("A", core.ResultStatusFound)
+	bob.Username = "bob"
+	old := []core.SiteResult{
+		result("A", core.ResultStatusNotFound),
+		bob,
+		{SiteName: "B", Username: "alice", ResultStatus: core.ResultStatusFound, ResultURL: "https://b.example/alice"},
+	}
+	// Results are matched by username and site, not by position.
+	current := []core.SiteResult{
+		{SiteName: "B", Username: "alice", ResultStatus: core.ResultStatusError},
+		{SiteName: "A", Username: "bob", ResultStatus: core.ResultStatusFound},
+		result("A", core.ResultStatusNotFound),
+	}
+
+	report := Compare(old, current)
+	if len(report.Changes) != 1 {
+		t.Fatalf("got changes %+v, want one", report.Changes)
+	}
+	change := report.Changes[0]
+	if change.SiteName != "B" || change.Kind != KindStatusChanged {
+		t.Errorf("got %+v, want B to have changed status", change)
+	}
+	if change.URL != "https://b.example/alice" {
+		t.Errorf("URL = %q, want the old URL when the new result has none", change.URL)
+	}
+	if report.Summary.Unchanged != 2 {
+		t.Errorf("Unchanged = %d, want 2", report.Summary.Unchanged)
+	}
+}
+
+func TestCompareOrder(t *testing.T) {
+	user := func(name, site string, status core.ResultStatus) core.SiteResult {
+		return core.SiteResult{SiteName: site, Username: name, ResultStatus: status}
+	}
+	old := []core.SiteResult{
+		user("alice", "gone", core.ResultStatusFound),
+		user("alice", "broken", core.ResultStatusFound),
+	}
+	current := []core.SiteResult{
+		user("alice", "broken", core.ResultStatusError),
+		user("bob", "alpha", core.ResultStatusFound),
+		user("alice", "gone", core.ResultStatusNotFound),
+		user("alice", "Zeta", core.ResultStatusFound),
+		user("alice", "beta", core.ResultStatusFound),
+	}
+
+	var got []string
+	for _, change := range Compare(old, current).Changes {
+		got = append(got, string(change.Kind)+" "+change.Username+"/"+change.SiteName)
+	}
+	want := []string{
+		"appeared alice/beta",
+		"appeared alice/Zeta",
+		"appeared bob/alpha",
+		"disappeared alice/gone",
+		"status_changed alice/broken",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("order = %q, want %q", got, want)
+	}
+}
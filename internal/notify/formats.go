@@ -0,0 +1,198 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+type Format string
+
+const (
+	// FormatJSON posts the Message as JSON.
+	FormatJSON Format = "json"
+	// FormatTemplate posts the Message rendered with a Go template.
+	FormatTemplate Format = "template"
+
+	FormatSlack      Format = "slack"
+	FormatDiscord    Format = "discord"
+	FormatMattermost Format = "mattermost"
+)
+
+var formats = []Format{FormatJSON, FormatTemplate, FormatSlack, FormatDiscord, FormatMattermost}
+
+// discordMaxContent is the most characters Discord accepts in a message.
+const discordMaxContent = 2000
+
+// ParseWebhook parses a webhook given as format=url, or as a bare URL for
+// FormatJSON, or FormatTemplate when tmpl is set. tmpl is the body
+// template of template webhooks.
+func ParseWebhook(spec string, tmpl *Template) (Webhook, error) {
+	webhook := Webhook{URL: spec, Format: FormatJSON, Template: tmpl}
+	if tmpl != nil {
+		webhook.Format = FormatTemplate
+	}
+	if name, target, ok := strings.Cut(spec, "="); ok && !strings.Contains(name, "/") {
+		webhook.Format, webhook.URL = Format(strings.ToLower(name)), target
+	}
+
+	known := false
+	for _, format := range formats {
+		known = known || webhook.Format == format
+	}
+	if !known {
+		return Webhook{}, fmt.Errorf("unknown webhook format %q (valid: json, template, slack, discord, mattermost)", webhook.Format)
+	}
+	if webhook.Format == FormatTemplate && tmpl == nil {
+		return Webhook{}, fmt.Errorf("template webhooks need a body template")
+	}
+	return webhook, nil
+}
+
+// Template is a Go text/template for webhook bodies. It is executed with a
+// Message and has a json function that encodes its argument as JSON.
+type Template struct {
+	tmpl        *template.Template
+	contentType string
+}
+
+// LoadTemplate parses the template file at path. Bodies are sent with
+// contentType, application/json when empty.
+func LoadTemplate(path, contentType string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook template: %w", err)
+	}
+	return ParseTemplate(string(data), contentType)
+}
+
+func ParseTemplate(text, contentType string) (*Template, error) {
+	tmpl, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %w", err)
+	}
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	return &Template{tmpl: tmpl, contentType: contentType}, nil
+}
+
+// render returns the body of message for webhook and its content type.
+func render(webhook Webhook, message Message) ([]byte, string, error) {
+	var payload interface{}
+	switch webhook.Format {
+	case FormatTemplate:
+		var b bytes.Buffer
+		if err := webhook.Template.tmpl.Execute(&b, message); err != nil {
+			return nil, "", fmt.Errorf("failed to render webhook template: %w", err)
+		}
+		return b.Bytes(), webhook.Template.contentType, nil
+	case FormatSlack:
+		payload = map[string]string{"text": chatText(message, slackLink, slackEscape)}
+	case FormatMattermost:
+		payload = map[string]string{"text": chatText(message, markdownLink, markdownEscape)}
+	case FormatDiscord:
+		payload = map[string]interface{}{
+			"content": truncate(chatText(message, discordLink, markdownEscape), discordMaxContent),
+			// Usernames and bios come from the scanned sites and must not
+			// ping anyone.
+			"allowed_mentions": map[string][]string{"parse": {}},
+		}
+	default:
+		payload = message
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode webhook body: %w", err)
+	}
+	return body, "application/json", nil
+}
+
+// chatText renders a message for the chat formats, which differ in how
+// links are written and what needs escaping.
+func chatText(message Message, link func(text, url string) string, escape func(string) string) string {
+	var b strings.Builder
+	switch message.Type {
+	case MessageResults:
+		fmt.Fprintf(&b, "usrsx found %d account(s):\n", len(message.Results))
+		for _, result := range message.Results {
+			site := escape(result.SiteName)
+			if result.ResultURL != "" {
+				site = link(result.SiteName, result.ResultURL)
+			}
+			fmt.Fprintf(&b, "• %s on %s (%s)", escape(result.Username), site, escape(result.Category))
+			if result.Metadata != nil && result.Metadata.DisplayName != "" {
+				fmt.Fprintf(&b, ": %s", escape(result.Metadata.DisplayName))
+			}
+			b.WriteString("\n")
+		}
+	case MessageSummary:
+		s := message.Summary
+		fmt.Fprintf(&b, "usrsx finished checking %s in %.1fs: %d found, %d not found, %d errors, %d unknown, %d ambiguous",
+			escape(strings.Join(message.Usernames, ", ")), s.Elapsed, s.Found, s.NotFound, s.Errors, s.Unknown, s.Ambiguous)
+		if s.Error != "" {
+			fmt.Fprintf(&b, "\nAborted: %s", escape(s.Error))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Slack's mrkdwn only treats &, < and > specially; | ends the text of a
+// link.
+var slackReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackEscape(s string) string {
+	return slackReplacer.Replace(s)
+}
+
+func slackLink(text, url string) string {
+	return "<" + slackEscape(url) + "|" + strings.ReplaceAll(slackEscape(text), "|", "¦") + ">"
+}
+
+var markdownReplacer = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`,
+	"[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+	// A zero-width space keeps @channel and @here from notifying anyone.
+	"@", "@\u200b",
+)
+
+func markdownEscape(s string) string {
+	return markdownReplacer.Replace(s)
+}
+
+func markdownLink(text, url string) string {
+	return "[" + markdownEscape(text) + "](" + escapeURL(url) + ")"
+}
+
+// discordLink wraps the URL in <> so Discord does not unfurl every profile
+// into an embed.
+func discordLink(text, url string) string {
+	return "[" + markdownEscape(text) + "](<" + escapeURL(url) + ">)"
+}
+
+func escapeURL(url string) string {
+	return strings.NewReplacer("(", "%28", ")", "%29", "<", "%3C", ">", "%3E", " ", "%20").Replace(url)
+}
+
+// truncate cuts s to at most limit runes at a line break, marking the cut.
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	const more = "\n…"
+	cut := string(runes[:limit-len([]rune(more))])
+	if i := strings.LastIndex(cut, "\n"); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + more
+}
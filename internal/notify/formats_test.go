@@ -0,0 +1,199 @@
+package notify
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/pkg/events"
+)
+
+func resultsMessage() Message {
+	return Message{
+		Type:      MessageResults,
+		Usernames: []string{"alice"},
+		Results: []core.SiteResult{
+			{
+				SiteName: "Git|Hub", Category: "coding", Username: "alice",
+				ResultStatus: core.ResultStatusFound, ResultURL: "https://github.com/alice_(x)",
+				Metadata: &core.ProfileMetadata{DisplayName: "@here <Alice> *bold*"},
+			},
+			{SiteName: "Forum", Category: "social", Username: "alice", ResultStatus: core.ResultStatusFound},
+		},
+	}
+}
+
+func summaryMessage() Message {
+	return Message{
+		Type:      MessageSummary,
+		Usernames: []string{"alice", "bob"},
+		Summary: &Summary{
+			Summary: events.Summary{Total: 10, Found: 2, NotFound: 5, Errors: 1, Unknown: 1, Ambiguous: 1},
+			Elapsed: 12.34,
+			Error:   "interrupted",
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		format  Format
+		message Message
+		want    map[string]interface{}
+	}{
+		{
+			format:  FormatSlack,
+			message: resultsMessage(),
+			want: map[string]interface{}{"text": "usrsx found 2 account(s):\n" +
+				"• alice on <https://github.com/alice_(x)|Git¦Hub> (coding): @here &lt;Alice&gt; *bold*\n" +
+				"• alice on Forum (social)"},
+		},
+		{
+			format:  FormatMattermost,
+			message: resultsMessage(),
+			want: map[string]interface{}{"text": "usrsx found 2 account(s):\n" +
+				`• alice on [Git\|Hub](https://github.com/alice_%28x%29) (coding): @` + "\u200b" + `here \<Alice\> \*bold\*` + "\n" +
+				"• alice on Forum (social)"},
+		},
+		{
+			format:  FormatDiscord,
+			message: resultsMessage(),
+			want: map[string]interface{}{
+				"content": "usrsx found 2 account(s):\n" +
+					`• alice on [Git\|Hub](<https://github.com/alice_%28x%29>) (coding): @` + "\u200b" + `here \<Alice\> \*bold\*` + "\n" +
+					"• alice on Forum (social)",
+				"allowed_mentions": map[string]interface{}{"parse": []interface{}{}},
+			},
+		},
+		{
+			format:  FormatSlack,
+			message: summaryMessage(),
+			want: map[string]interface{}{"text": "usrsx finished checking alice, bob in 12.3s: " +
+				"2 found, 5 not found, 1 errors, 1 unknown, 1 ambiguous\nAborted: interrupted"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format)+"/"+string(tt.message.Type), func(t *testing.T) {
+			body, contentType, err := render(Webhook{Format: tt.format}, tt.message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contentType != "application/json" {
+				t.Errorf("content type = %q", contentType)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatalf("invalid body %s: %v", body, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("body = %#v\nwant %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	body, _, err := render(Webhook{Format: FormatJSON}, summaryMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Message
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, summaryMessage()) {
+		t.Errorf("got %+v, want the message itself", got)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate(`{"n": {{len .Results}}, "first": {{json (index .Results 0).SiteName}}}`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, contentType, err := render(Webhook{Format: FormatTemplate, Template: tmpl}, resultsMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"n": 2, "first": "Git|Hub"}`; string(body) != want || contentType != "application/json" {
+		t.Errorf("got %s (%s), want %s", body, contentType, want)
+	}
+
+	tmpl, err = ParseTemplate("{{.Type}}", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, contentType, _ := render(Webhook{Format: FormatTemplate, Template: tmpl}, summaryMessage()); string(body) != "summary" || contentType != "text/plain" {
+		t.Errorf("got %s (%s), want summary (text/plain)", body, contentType)
+	}
+
+	// A summary has no results to index.
+	tmpl, _ = ParseTemplate("{{(index .Results 0).SiteName}}", "")
+	if _, _, err := render(Webhook{Format: FormatTemplate, Template: tmpl}, summaryMessage()); err == nil {
+		t.Error("failing template rendered")
+	}
+	if _, err := ParseTemplate("{{.Type", ""); err == nil {
+		t.Error("invalid template parsed")
+	}
+}
+
+func TestDiscordTruncate(t *testing.T) {
+	message := Message{Type: MessageResults}
+	for i := 0; i < 100; i++ {
+		message.Results = append(message.Results, core.SiteResult{
+			SiteName: strings.Repeat("é", 20), Username: "alice", ResultStatus: core.ResultStatusFound,
+		})
+	}
+	body, _, err := render(Webhook{Format: FormatDiscord}, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct{ Content string }
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if n := utf8.RuneCountInString(got.Content); n > discordMaxContent {
+		t.Errorf("content is %d characters, want at most %d", n, discordMaxContent)
+	}
+	if !strings.HasSuffix(got.Content, ")\n…") {
+		t.Errorf("content ends %q, want a cut at a line break", got.Content[len(got.Content)-20:])
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	tmpl, _ := ParseTemplate("{{.Type}}", "")
+	tests := []struct {
+		spec   string
+		tmpl   *Template
+		format Format
+		url    string
+		err    bool
+	}{
+		{spec: "https://example.com/hook", format: FormatJSON, url: "https://example.com/hook"},
+		{spec: "https://example.com/hook?a=b", format: FormatJSON, url: "https://example.com/hook?a=b"},
+		{spec: "Slack=https://hooks.slack.com/x", format: FormatSlack, url: "https://hooks.slack.com/x"},
+		{spec: "discord=https://discord.com/api/webhooks/1/t", format: FormatDiscord, url: "https://discord.com/api/webhooks/1/t"},
+		{spec: "mattermost=https://chat.example.com/hooks/x", format: FormatMattermost, url: "https://chat.example.com/hooks/x"},
+		{spec: "https://example.com/hook", tmpl: tmpl, format: FormatTemplate, url: "https://example.com/hook"},
+		{spec: "json=https://example.com/hook", tmpl: tmpl, format: FormatJSON, url: "https://example.com/hook"},
+		{spec: "teams=https://example.com/hook", err: true},
+		{spec: "template=https://example.com/hook", err: true},
+	}
+
+	for _, tt := range tests {
+		webhook, err := ParseWebhook(tt.spec, tt.tmpl)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseWebhook(%q) accepted", tt.spec)
+			}
+			continue
+		}
+		if err != nil || webhook.Format != tt.format || webhook.URL != tt.url {
+			t.Errorf("ParseWebhook(%q) = %s %s, %v; want %s %s", tt.spec, webhook.Format, webhook.URL, err, tt.format, tt.url)
+		}
+	}
+}
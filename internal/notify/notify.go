@@ -0,0 +1,392 @@
+// Package notify posts found results and an end-of-run summary to
+// webhooks: generic ones with a JSON or Go template body, and Slack,
+// Discord and Mattermost incoming webhooks. Results are batched into
+// messages, messages to a webhook are spaced out, and failed posts are
+// retried.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/pkg/events"
+)
+
+type Config struct {
+	Webhooks []Webhook
+
+	// BatchSize is the most found results a message carries.
+	BatchSize int
+
+	// FlushInterval is the longest a found result waits for its batch to
+	// fill up.
+	FlushInterval time.Duration
+
+	// MinInterval is the least time between two messages to the same
+	// webhook.
+	MinInterval time.Duration
+
+	// MaxAttempts is how often a message is tried. Network errors, 429 and
+	// 5xx responses are retried, waiting RetryDelay, doubled per attempt,
+	// or as long as Retry-After asks, up to MaxRetryDelay.
+	MaxAttempts   int
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+
+	// Client defaults to one with the default request timeout.
+	Client *http.Client
+}
+
+// Webhook is one destination.
+type Webhook struct {
+	URL    string
+	Format Format
+
+	// Template is the parsed body template of a FormatTemplate webhook.
+	Template *Template
+}
+
+// Message is what a webhook body is rendered from: a batch of found
+// results, or the summary at the end of the run.
+type Message struct {
+	Type      MessageType       `json:"type"`
+	Usernames []string          `json:"usernames"`
+	Results   []core.SiteResult `json:"results,omitempty"`
+	Summary   *Summary          `json:"summary,omitempty"`
+}
+
+type MessageType string
+
+const (
+	MessageResults MessageType = "results"
+	MessageSummary MessageType = "summary"
+)
+
+// Summary is the end-of-run message.
+type Summary struct {
+	events.Summary
+	Elapsed float64 `json:"elapsed"`
+	// Error is why the run was aborted, if it was.
+	Error string `json:"error,omitempty"`
+}
+
+// Notifier sends the messages of one run. Found is safe to call from the
+// goroutines reporting results and never waits for the network.
+type Notifier struct {
+	config    Config
+	usernames []string
+
+	mu      sync.Mutex
+	pending []core.SiteResult
+
+	// messages counts the messages sent to every webhook; failures and
+	// lastErr count and keep the last error of those a webhook did not
+	// take.
+	messages int
+	failures []int
+	lastErr  []error
+
+	full    chan struct{}
+	closing chan struct{}
+	stopped chan struct{}
+
+	// ctx is cancelled when Finish gives up waiting.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// posters post to each webhook. Like failures and lastErr, an entry is
+	// only touched by the goroutine posting to its webhook.
+	posters []*Poster
+}
+
+// New starts a notifier for a run over usernames.
+func New(config Config, usernames []string) *Notifier {
+	config = withDefaults(config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{
+		config:    config,
+		usernames: usernames,
+		full:      make(chan struct{}, 1),
+		closing:   make(chan struct{}),
+		stopped:   make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+		failures:  make([]int, len(config.Webhooks)),
+		lastErr:   make([]error, len(config.Webhooks)),
+		posters:   make([]*Poster, len(config.Webhooks)),
+	}
+	for i := range n.posters {
+		n.posters[i] = NewPoster(config)
+	}
+	go n.run()
+	return n
+}
+
+// withDefaults fills in the settings config leaves at zero.
+func withDefaults(config Config) Config {
+	if config.BatchSize <= 0 {
+		config.BatchSize = core.NotifyBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = core.NotifyFlushSeconds * time.Second
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = core.NotifyMaxAttempts
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = core.NotifyRetryDelayMillis * time.Millisecond
+	}
+	if config.MaxRetryDelay <= 0 {
+		config.MaxRetryDelay = core.RetryMaxDelaySeconds * time.Second
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: core.HTTPRequestTimeoutSeconds * time.Second}
+	}
+	return config
+}
+
+// Found queues result if it is a found account; other results are
+// ignored.
+func (n *Notifier) Found(result core.SiteResult) {
+	if result.ResultStatus != core.ResultStatusFound {
+		return
+	}
+	result.ResponseText = ""
+
+	n.mu.Lock()
+	n.pending = append(n.pending, result)
+	full := len(n.pending) >= n.config.BatchSize
+	n.mu.Unlock()
+
+	if full {
+		select {
+		case n.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Finish sends the results still queued and then summary, and returns an
+// error for every webhook that failed to take a message. When ctx is done
+// first, the messages not sent yet are dropped.
+func (n *Notifier) Finish(ctx context.Context, summary Summary) error {
+	close(n.closing)
+	select {
+	case <-n.stopped:
+	case <-ctx.Done():
+		n.cancel()
+		<-n.stopped
+	}
+
+	if n.ctx.Err() == nil {
+		n.send(Message{Type: MessageSummary, Usernames: n.usernames, Summary: &summary})
+	}
+	n.cancel()
+
+	var errs []error
+	for i, webhook := range n.config.Webhooks {
+		if n.failures[i] > 0 {
+			errs = append(errs, fmt.Errorf("%s webhook (%s): %d of %d messages failed, last: %w",
+				webhook.Format, host(webhook.URL), n.failures[i], n.messages, n.lastErr[i]))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) run() {
+	defer close(n.stopped)
+
+	ticker := time.NewTicker(n.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.full:
+			n.flush(false)
+		case <-ticker.C:
+			n.flush(true)
+		case <-n.closing:
+			n.flush(true)
+			return
+		}
+	}
+}
+
+// flush sends the queued results in batches. Without all, a last batch
+// that is not full stays queued.
+func (n *Notifier) flush(all bool) {
+	for {
+		n.mu.Lock()
+		size := min(len(n.pending), n.config.BatchSize)
+		if size == 0 || (!all && size < n.config.BatchSize) {
+			n.mu.Unlock()
+			return
+		}
+		batch := n.pending[:size:size]
+		n.pending = n.pending[size:]
+		n.mu.Unlock()
+
+		n.send(Message{Type: MessageResults, Usernames: n.usernames, Results: batch})
+	}
+}
+
+// send posts message to all webhooks in parallel and returns once every
+// one has taken it or given up.
+func (n *Notifier) send(message Message) {
+	n.messages++
+
+	var wg sync.WaitGroup
+	for i, webhook := range n.config.Webhooks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := n.post(i, webhook, message); err != nil {
+				n.failures[i]++
+				n.lastErr[i] = err
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// post sends message to the i-th webhook.
+func (n *Notifier) post(i int, webhook Webhook, message Message) error {
+	body, contentType, err := render(webhook, message)
+	if err != nil {
+		return err
+	}
+	return n.posters[i].Post(n.ctx, webhook.URL, contentType, body)
+}
+
+// Poster posts bodies to a webhook with the spacing and retries of a
+// Notifier: posts are at least MinInterval apart, and network errors, 429
+// and 5xx responses are retried as configured. It is not safe for
+// concurrent use.
+type Poster struct {
+	config Config
+
+	// lastSent is when the webhook was last posted to.
+	lastSent time.Time
+}
+
+// NewPoster returns a Poster using the MinInterval, retry and Client
+// settings of config.
+func NewPoster(config Config) *Poster {
+	return &Poster{config: withDefaults(config)}
+}
+
+// Post sends body to target until it is taken, the attempts run out or
+// ctx is done.
+func (p *Poster) Post(ctx context.Context, target, contentType string, body []byte) error {
+	for attempt := 1; ; attempt++ {
+		if err := p.wait(ctx); err != nil {
+			return err
+		}
+
+		retryAfter, err := p.do(ctx, target, contentType, body)
+		p.lastSent = time.Now()
+		if err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempt >= p.config.MaxAttempts {
+			return err
+		}
+
+		delay := p.config.RetryDelay << (attempt - 1)
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		delay = min(delay, p.config.MaxRetryDelay)
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// wait holds a post back until MinInterval has passed since the last one.
+func (p *Poster) wait(ctx context.Context) error {
+	if p.lastSent.IsZero() || p.config.MinInterval <= 0 {
+		return ctx.Err()
+	}
+	return sleep(ctx, time.Until(p.lastSent.Add(p.config.MinInterval)))
+}
+
+// permanentError is a response that retrying will not change.
+type permanentError struct {
+	status string
+}
+
+func (e *permanentError) Error() string {
+	return e.status
+}
+
+// do posts body once and returns how long the webhook asked to wait before
+// trying again, if it did.
+func (p *Poster) do(ctx context.Context, target, contentType string, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, &permanentError{status: "invalid webhook URL"}
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "usrsx/"+core.Version)
+
+	resp, err := p.config.Client.Do(req)
+	if err != nil {
+		// Leave out the URL, which often embeds a secret token.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		var retryAfter time.Duration
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return retryAfter, errors.New(resp.Status)
+	default:
+		return 0, &permanentError{status: resp.Status}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// host names a webhook in errors without its path, which often holds the
+// token.
+func host(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "invalid URL"
+	}
+	return u.Host
+}
@@ -0,0 +1,307 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/pkg/events"
+)
+
+type post struct {
+	at          time.Time
+	contentType string
+	body        []byte
+}
+
+// receiver is a webhook that records every post. Each post is answered
+// with the next of responses, and with 200 once they run out.
+type receiver struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	posts     []post
+	responses []response
+}
+
+type response struct {
+	status     int
+	retryAfter string
+}
+
+func newReceiver(t *testing.T, responses ...response) *receiver {
+	rec := &receiver{responses: responses}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		rec.posts = append(rec.posts, post{at: time.Now(), contentType: r.Header.Get("Content-Type"), body: body})
+		resp := response{status: http.StatusOK}
+		if len(rec.responses) > 0 {
+			resp, rec.responses = rec.responses[0], rec.responses[1:]
+		}
+		rec.mu.Unlock()
+
+		if resp.retryAfter != "" {
+			w.Header().Set("Retry-After", resp.retryAfter)
+		}
+		w.WriteHeader(resp.status)
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+func (rec *receiver) received() []post {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]post(nil), rec.posts...)
+}
+
+// messages decodes the posts of a FormatJSON webhook.
+func (rec *receiver) messages(t *testing.T) []Message {
+	t.Helper()
+	var messages []Message
+	for _, p := range rec.received() {
+		var message Message
+		if err := json.Unmarshal(p.body, &message); err != nil {
+			t.Fatalf("invalid body %s: %v", p.body, err)
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+func found(i int) core.SiteResult {
+	return core.SiteResult{
+		SiteName:     fmt.Sprintf("Site%d", i),
+		Username:     "alice",
+		ResultStatus: core.ResultStatusFound,
+		ResultURL:    fmt.Sprintf("https://site%d.example/alice", i),
+		ResponseText: "<html>",
+	}
+}
+
+func testConfig(urls ...string) Config {
+	config := Config{
+		FlushInterval: time.Hour,
+		RetryDelay:    10 * time.Millisecond,
+		MaxRetryDelay: 5 * time.Second,
+	}
+	for _, u := range urls {
+		config.Webhooks = append(config.Webhooks, Webhook{URL: u, Format: FormatJSON})
+	}
+	return config
+}
+
+func TestBatching(t *testing.T) {
+	rec := newReceiver(t)
+	config := testConfig(rec.URL)
+	config.BatchSize = 3
+
+	n := New(config, []string{"alice"})
+	for i := 0; i < 7; i++ {
+		n.Found(found(i))
+		n.Found(core.SiteResult{SiteName: "Other", ResultStatus: core.ResultStatusNotFound})
+	}
+	summary := Summary{Summary: events.Summary{Total: 14, Found: 7, NotFound: 7}, Elapsed: 1.5}
+	if err := n.Finish(context.Background(), summary); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := rec.messages(t)
+	var sizes []int
+	for _, message := range messages {
+		sizes = append(sizes, len(message.Results))
+	}
+	if fmt.Sprint(sizes) != "[3 3 1 0]" {
+		t.Fatalf("batch sizes = %v, want [3 3 1 0]", sizes)
+	}
+
+	seen := 0
+	for _, message := range messages[:3] {
+		if message.Type != MessageResults || message.Usernames[0] != "alice" {
+			t.Errorf("got %s message for %v", message.Type, message.Usernames)
+		}
+		for _, result := range message.Results {
+			if result.SiteName != fmt.Sprintf("Site%d", seen) || result.ResponseText != "" {
+				t.Errorf("result %d = %s with body %q", seen, result.SiteName, result.ResponseText)
+			}
+			seen++
+		}
+	}
+	last := messages[3]
+	if last.Type != MessageSummary || last.Summary == nil || last.Summary.Found != 7 || last.Summary.Elapsed != 1.5 {
+		t.Errorf("last message = %+v, want the summary", last)
+	}
+}
+
+func TestFlushInterval(t *testing.T) {
+	rec := newReceiver(t)
+	config := testConfig(rec.URL)
+	config.FlushInterval = 20 * time.Millisecond
+
+	n := New(config, []string{"alice"})
+	n.Found(found(0))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(rec.received()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("a batch that is not full was never flushed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := n.Finish(context.Background(), Summary{}); err != nil {
+		t.Fatal(err)
+	}
+	if messages := rec.messages(t); len(messages) != 2 || len(messages[0].Results) != 1 {
+		t.Errorf("got %+v, want the flushed batch and the summary", messages)
+	}
+}
+
+func TestSpacing(t *testing.T) {
+	rec := newReceiver(t)
+	config := testConfig(rec.URL)
+	config.BatchSize = 1
+	config.MinInterval = 50 * time.Millisecond
+
+	n := New(config, []string{"alice"})
+	for i := 0; i < 3; i++ {
+		n.Found(found(i))
+	}
+	if err := n.Finish(context.Background(), Summary{}); err != nil {
+		t.Fatal(err)
+	}
+
+	posts := rec.received()
+	if len(posts) != 4 {
+		t.Fatalf("got %d posts, want 4", len(posts))
+	}
+	for i := 1; i < len(posts); i++ {
+		// Allow for the receiver stamping a post a little after it was sent.
+		if gap := posts[i].at.Sub(posts[i-1].at); gap < config.MinInterval-5*time.Millisecond {
+			t.Errorf("post %d came %s after the previous one, want at least %s", i, gap, config.MinInterval)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	rec := newReceiver(t,
+		response{status: http.StatusTooManyRequests, retryAfter: "1"},
+		response{status: http.StatusBadGateway},
+	)
+	config := testConfig(rec.URL)
+
+	n := New(config, []string{"alice"})
+	if err := n.Finish(context.Background(), Summary{}); err != nil {
+		t.Fatal(err)
+	}
+
+	posts := rec.received()
+	if len(posts) != 3 {
+		t.Fatalf("got %d posts, want 3", len(posts))
+	}
+	if gap := posts[1].at.Sub(posts[0].at); gap < time.Second {
+		t.Errorf("retried after %s, want the 1s Retry-After", gap)
+	}
+	// Without Retry-After, the second retry waits twice RetryDelay.
+	if gap := posts[2].at.Sub(posts[1].at); gap < 2*config.RetryDelay || gap > time.Second {
+		t.Errorf("retried after %s, want about %s", gap, 2*config.RetryDelay)
+	}
+}
+
+func TestRetryAfterCapped(t *testing.T) {
+	rec := newReceiver(t, response{status: http.StatusServiceUnavailable, retryAfter: "3600"})
+	config := testConfig(rec.URL)
+	config.MaxRetryDelay = 20 * time.Millisecond
+
+	start := time.Now()
+	n := New(config, []string{"alice"})
+	if err := n.Finish(context.Background(), Summary{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.received()) != 2 || time.Since(start) > 5*time.Second {
+		t.Errorf("got %d posts in %s, want a retry after MaxRetryDelay", len(rec.received()), time.Since(start))
+	}
+}
+
+func TestFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []response
+		posts     int
+		want      string
+	}{
+		{
+			name:      "permanent",
+			responses: []response{{status: http.StatusBadRequest}, {status: http.StatusBadRequest}},
+			posts:     2,
+			want:      "2 of 2 messages failed, last: 400 Bad Request",
+		},
+		{
+			// The batch fails twice; the summary goes through on its
+			// second attempt.
+			name:      "attempts exhausted",
+			responses: []response{{status: 500}, {status: 500}, {status: 500}},
+			posts:     4,
+			want:      "1 of 2 messages failed, last: 500 Internal Server Error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newReceiver(t, tt.responses...)
+			ok := newReceiver(t)
+			config := testConfig(rec.URL+"/hooks/secret-token", ok.URL)
+			config.BatchSize = 1
+			config.MaxAttempts = 2
+
+			n := New(config, []string{"alice"})
+			n.Found(found(0))
+			err := n.Finish(context.Background(), Summary{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Finish() = %v, want %q", err, tt.want)
+			}
+			if strings.Contains(err.Error(), "secret-token") {
+				t.Errorf("error %q leaks the webhook path", err)
+			}
+			if got := len(rec.received()); got != tt.posts {
+				t.Errorf("failing webhook got %d posts, want %d", got, tt.posts)
+			}
+			// The other webhook is not held up by the failing one.
+			if got := len(ok.received()); got != 2 {
+				t.Errorf("working webhook got %d posts, want 2", got)
+			}
+		})
+	}
+}
+
+func TestFinishGivesUp(t *testing.T) {
+	rec := newReceiver(t, response{status: http.StatusServiceUnavailable, retryAfter: "3600"})
+	config := testConfig(rec.URL)
+	config.BatchSize = 1
+	config.MaxRetryDelay = time.Hour
+
+	n := New(config, []string{"alice"})
+	n.Found(found(0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := n.Finish(ctx, Summary{}); err == nil {
+		t.Error("Finish() = nil, want the dropped message reported")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Finish took %s after its context was done", elapsed)
+	}
+	// The summary is dropped along with the batch.
+	if got := len(rec.received()); got != 1 {
+		t.Errorf("got %d posts, want 1", got)
+	}
+}
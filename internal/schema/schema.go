@@ -0,0 +1,527 @@
+// Package schema validates JSON documents against a JSON Schema. It
+// implements the subset of draft-07 used by site list schemas: type, enum,
+// const, the object, array, string and numeric constraints, the allOf,
+// anyOf, oneOf and not combinators, and local $ref pointers.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+type Schema struct {
+	root interface{}
+
+	mu       sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+// Violation is a single schema failure at Path, a JSONPath-style location
+// such as $.sites[3].e_code.
+type Violation struct {
+	Path    string
+	Message string
+
+	// cycle marks a $ref cycle, a fault of the schema that is reported
+	// even from the subschemas of anyOf, oneOf and not.
+	cycle bool
+}
+
+func (v Violation) String() string {
+	return v.Path + ": " + v.Message
+}
+
+func Parse(data []byte) (*Schema, error) {
+	root, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	switch root.(type) {
+	case map[string]interface{}, bool:
+	default:
+		return nil, fmt.Errorf("invalid schema: root must be an object or a boolean")
+	}
+	return &Schema{root: root, patterns: make(map[string]*regexp.Regexp)}, nil
+}
+
+// Decode parses a JSON document the way Validate expects it, keeping
+// numbers as json.Number so integers can be told apart from floats.
+func Decode(data []byte) (interface{}, error) {
+	return decode(data)
+}
+
+func decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Validate returns every violation of the schema in doc, which must come
+// from Decode.
+func (s *Schema) Validate(doc interface{}) []Violation {
+	var violations []Violation
+	s.validate(s.root, doc, "$", &violations, nil)
+	return violations
+}
+
+// validate checks value against schema. refs are the references followed
+// since validation last moved into a child of value; meeting one of them
+// again would recurse forever.
+func (s *Schema) validate(schema, value interface{}, path string, out *[]Violation, refs []string) {
+	fail := func(format string, args ...interface{}) {
+		*out = append(*out, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	sch, ok := schema.(map[string]interface{})
+	if !ok {
+		if allowed, isBool := schema.(bool); isBool && !allowed {
+			fail("no value is allowed here")
+		}
+		return
+	}
+
+	// In draft-07 a $ref replaces every sibling keyword.
+	if ref, ok := sch["$ref"].(string); ok {
+		if slices.Contains(refs, ref) {
+			*out = append(*out, Violation{Path: path, Message: fmt.Sprintf("$ref %q refers back to itself", ref), cycle: true})
+			return
+		}
+		target, err := s.resolve(ref)
+		if err != nil {
+			fail("%v", err)
+			return
+		}
+		s.validate(target, value, path, out, append(refs[:len(refs):len(refs)], ref))
+		return
+	}
+
+	if t, ok := sch["type"]; ok && !matchesType(t, value) {
+		fail("expected %s, got %s", describeType(t), typeName(value))
+		return
+	}
+
+	if enum, ok := sch["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if equal(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value %s is not one of the allowed values", preview(value))
+		}
+	}
+
+	if c, ok := sch["const"]; ok && !equal(c, value) {
+		fail("value %s must be %s", preview(value), preview(c))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		s.validateObject(sch, v, path, out, fail)
+	case []interface{}:
+		s.validateArray(sch, v, path, out, fail)
+	case string:
+		s.validateString(sch, v, fail)
+	case json.Number:
+		validateNumber(sch, v, fail)
+	}
+
+	if all, ok := sch["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			s.validate(sub, value, path, out, refs)
+		}
+	}
+
+	if anyOf, ok := sch["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if s.matches(sub, value, path, out, refs) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("does not match any schema in anyOf")
+		}
+	}
+
+	if oneOf, ok := sch["oneOf"].([]interface{}); ok {
+		matched := 0
+		for _, sub := range oneOf {
+			if s.matches(sub, value, path, out, refs) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail("matches %d schemas in oneOf, expected exactly 1", matched)
+		}
+	}
+
+	if not, ok := sch["not"]; ok && s.matches(not, value, path, out, refs) {
+		fail("must not match the schema in not")
+	}
+}
+
+// matches reports whether value matches schema. Only $ref cycles are
+// added to out.
+func (s *Schema) matches(schema, value interface{}, path string, out *[]Violation, refs []string) bool {
+	var violations []Violation
+	s.validate(schema, value, path, &violations, refs)
+	for _, violation := range violations {
+		if violation.cycle {
+			*out = append(*out, violation)
+		}
+	}
+	return len(violations) == 0
+}
+
+func (s *Schema) validateObject(sch map[string]interface{}, obj map[string]interface{}, path string, out *[]Violation, fail func(string, ...interface{})) {
+	if required, ok := sch["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, present := obj[name]; !present {
+					fail("missing required property %q", name)
+				}
+			}
+		}
+	}
+
+	if n, ok := intKeyword(sch, "minProperties"); ok && len(obj) < n {
+		fail("has %d properties, expected at least %d", len(obj), n)
+	}
+	if n, ok := intKeyword(sch, "maxProperties"); ok && len(obj) > n {
+		fail("has %d properties, expected at most %d", len(obj), n)
+	}
+
+	properties, _ := sch["properties"].(map[string]interface{})
+	patternProperties, _ := sch["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := sch["additionalProperties"]
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := obj[name]
+		childPath := propertyPath(path, name)
+		matched := false
+
+		if sub, ok := properties[name]; ok {
+			matched = true
+			s.validate(sub, value, childPath, out, nil)
+		}
+		for pattern, sub := range patternProperties {
+			re, err := s.pattern(pattern)
+			if err != nil {
+				fail("%v", err)
+				continue
+			}
+			if re.MatchString(name) {
+				matched = true
+				s.validate(sub, value, childPath, out, nil)
+			}
+		}
+
+		if !matched && hasAdditional {
+			if allowed, ok := additional.(bool); ok {
+				if !allowed {
+					*out = append(*out, Violation{Path: childPath, Message: "property is not allowed"})
+				}
+			} else {
+				s.validate(additional, value, childPath, out, nil)
+			}
+		}
+	}
+}
+
+func (s *Schema) validateArray(sch map[string]interface{}, arr []interface{}, path string, out *[]Violation, fail func(string, ...interface{})) {
+	if n, ok := intKeyword(sch, "minItems"); ok && len(arr) < n {
+		fail("has %d items, expected at least %d", len(arr), n)
+	}
+	if n, ok := intKeyword(sch, "maxItems"); ok && len(arr) > n {
+		fail("has %d items, expected at most %d", len(arr), n)
+	}
+
+	if unique, _ := sch["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := 0; j < i; j++ {
+				if equal(arr[i], arr[j]) {
+					fail("items %d and %d are equal, expected unique items", j, i)
+				}
+			}
+		}
+	}
+
+	switch items := sch["items"].(type) {
+	case []interface{}:
+		for i, value := range arr {
+			if i < len(items) {
+				s.validate(items[i], value, indexPath(path, i), out, nil)
+			} else if additional, ok := sch["additionalItems"]; ok {
+				s.validate(additional, value, indexPath(path, i), out, nil)
+			}
+		}
+	case nil:
+	default:
+		for i, value := range arr {
+			s.validate(items, value, indexPath(path, i), out, nil)
+		}
+	}
+
+	if contains, ok := sch["contains"]; ok {
+		found := false
+		for i, value := range arr {
+			if s.matches(contains, value, indexPath(path, i), out, nil) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("no item matches the schema in contains")
+		}
+	}
+}
+
+func (s *Schema) validateString(sch map[string]interface{}, str string, fail func(string, ...interface{})) {
+	length := utf8.RuneCountInString(str)
+	if n, ok := intKeyword(sch, "minLength"); ok && length < n {
+		fail("string is %d characters long, expected at least %d", length, n)
+	}
+	if n, ok := intKeyword(sch, "maxLength"); ok && length > n {
+		fail("string is %d characters long, expected at most %d", length, n)
+	}
+	if pattern, ok := sch["pattern"].(string); ok {
+		re, err := s.pattern(pattern)
+		if err != nil {
+			fail("%v", err)
+		} else if !re.MatchString(str) {
+			fail("string %s does not match pattern %q", preview(str), pattern)
+		}
+	}
+}
+
+func validateNumber(sch map[string]interface{}, n json.Number, fail func(string, ...interface{})) {
+	value, err := n.Float64()
+	if err != nil {
+		return
+	}
+	if limit, ok := numberKeyword(sch, "minimum"); ok && value < limit {
+		fail("%s is less than the minimum %v", n, limit)
+	}
+	if limit, ok := numberKeyword(sch, "maximum"); ok && value > limit {
+		fail("%s is greater than the maximum %v", n, limit)
+	}
+	if limit, ok := numberKeyword(sch, "exclusiveMinimum"); ok && value <= limit {
+		fail("%s must be greater than %v", n, limit)
+	}
+	if limit, ok := numberKeyword(sch, "exclusiveMaximum"); ok && value >= limit {
+		fail("%s must be less than %v", n, limit)
+	}
+	if divisor, ok := numberKeyword(sch, "multipleOf"); ok && divisor > 0 {
+		if q := value / divisor; q != math.Trunc(q) {
+			fail("%s is not a multiple of %v", n, divisor)
+		}
+	}
+}
+
+// resolve follows a local reference such as #/definitions/site.
+func (s *Schema) resolve(ref string) (interface{}, error) {
+	if ref == "#" {
+		return s.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q (only local references are supported)", ref)
+	}
+
+	node := s.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch n := node.(type) {
+		case map[string]interface{}:
+			next, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("unresolvable $ref %q", ref)
+			}
+			node = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, fmt.Errorf("unresolvable $ref %q", ref)
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	return node, nil
+}
+
+func (s *Schema) pattern(pattern string) (*regexp.Regexp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if re, ok := s.patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q in schema: %w", pattern, err)
+	}
+	s.patterns[pattern] = re
+	return re, nil
+}
+
+func matchesType(t, value interface{}) bool {
+	switch t := t.(type) {
+	case string:
+		return isType(t, value)
+	case []interface{}:
+		for _, name := range t {
+			if name, ok := name.(string); ok && isType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func isType(name string, value interface{}) bool {
+	switch name {
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	default:
+		return typeName(value) == name
+	}
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func describeType(t interface{}) string {
+	if list, ok := t.([]interface{}); ok {
+		names := make([]string, 0, len(list))
+		for _, name := range list {
+			names = append(names, fmt.Sprint(name))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+// equal compares two decoded JSON values, treating numbers by value.
+func equal(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fa, errA := a.Float64()
+		fb, errB := b.Float64()
+		return errA == nil && errB == nil && fa == fb
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+func intKeyword(sch map[string]interface{}, name string) (int, bool) {
+	f, ok := numberKeyword(sch, name)
+	return int(f), ok
+}
+
+func numberKeyword(sch map[string]interface{}, name string) (float64, bool) {
+	n, ok := sch[name].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func propertyPath(path, name string) string {
+	if identifier.MatchString(name) {
+		return path + "." + name
+	}
+	return path + "[" + strconv.Quote(name) + "]"
+}
+
+func indexPath(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+func preview(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	if len(data) > 40 {
+		return string(data[:37]) + "..."
+	}
+	return string(data)
+}
@@ -0,0 +1,323 @@
+package schema
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// siteListSchema is a cut-down site list schema in the shape of the
+// WhatsMyName one.
+const siteListSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"required": ["sites"],
+	"properties": {
+		"categories": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
+		"sites": {"type": "array", "items": {"$ref": "#/definitions/site"}}
+	},
+	"definitions": {
+		"site": {
+			"type": "object",
+			"required": ["name", "uri_check", "cat"],
+			"additionalProperties": false,
+			"properties": {
+				"name": {"type": "string", "minLength": 1},
+				"uri_check": {"type": "string", "pattern": "\\{account\\}"},
+				"cat": {"enum": ["social", "gaming", "tech"]},
+				"e_code": {"$ref": "#/definitions/status"},
+				"m_code": {"$ref": "#/definitions/status"},
+				"known": {"type": "array", "minItems": 1, "items": {"type": "string"}},
+				"headers": {"type": "object", "additionalProperties": {"type": "string"}}
+			}
+		},
+		"status": {"type": "integer", "minimum": 100, "maximum": 599}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		doc    string
+		// want lists the paths of the expected violations, with a
+		// fragment of the message after a space.
+		want []string
+	}{
+		{
+			name:   "type",
+			schema: `{"type": "string"}`,
+			doc:    `1`,
+			want:   []string{"$ expected string"},
+		},
+		{
+			name:   "type list",
+			schema: `{"type": ["string", "null"]}`,
+			doc:    `null`,
+		},
+		{
+			name:   "integer",
+			schema: `{"type": "integer"}`,
+			doc:    `1.5`,
+			want:   []string{"$ expected integer, got number"},
+		},
+		{
+			name:   "integral float is an integer",
+			schema: `{"type": "integer"}`,
+			doc:    `2.0`,
+		},
+		{
+			name:   "required",
+			schema: `{"required": ["a", "b"]}`,
+			doc:    `{"a": 1}`,
+			want:   []string{`$ missing required property "b"`},
+		},
+		{
+			name:   "enum",
+			schema: `{"enum": ["x", 1]}`,
+			doc:    `"y"`,
+			want:   []string{`$ value "y" is not one of`},
+		},
+		{
+			name:   "enum compares numbers by value",
+			schema: `{"enum": [1]}`,
+			doc:    `1.0`,
+		},
+		{
+			name:   "const",
+			schema: `{"const": {"a": [1, 2]}}`,
+			doc:    `{"a": [1, 3]}`,
+			want:   []string{"$ value"},
+		},
+		{
+			name:   "additionalProperties false",
+			schema: `{"properties": {"a": {}}, "additionalProperties": false}`,
+			doc:    `{"a": 1, "b": 2, "c d": 3}`,
+			want:   []string{"$.b property is not allowed", `$["c d"] property is not allowed`},
+		},
+		{
+			name:   "additionalProperties schema",
+			schema: `{"patternProperties": {"^x-": {}}, "additionalProperties": {"type": "number"}}`,
+			doc:    `{"x-any": "ok", "n": "1"}`,
+			want:   []string{"$.n expected number"},
+		},
+		{
+			name:   "ref",
+			schema: `{"definitions": {"n": {"type": "number"}}, "items": {"$ref": "#/definitions/n"}}`,
+			doc:    `[1, "2", 3]`,
+			want:   []string{"$[1] expected number"},
+		},
+		{
+			name:   "ref replaces siblings",
+			schema: `{"definitions": {"n": {"type": "number"}}, "$ref": "#/definitions/n", "minimum": 10}`,
+			doc:    `1`,
+		},
+		{
+			name:   "escaped ref",
+			schema: `{"definitions": {"a/b": {"type": "null"}}, "$ref": "#/definitions/a~1b"}`,
+			doc:    `1`,
+			want:   []string{"$ expected null"},
+		},
+		{
+			name:   "unresolvable ref",
+			schema: `{"$ref": "#/definitions/missing"}`,
+			doc:    `1`,
+			want:   []string{"$ unresolvable $ref"},
+		},
+		{
+			name:   "remote ref",
+			schema: `{"$ref": "https://example.com/schema.json"}`,
+			doc:    `1`,
+			want:   []string{"$ unsupported $ref"},
+		},
+		{
+			name:   "recursive ref",
+			schema: `{"type": "object", "properties": {"child": {"$ref": "#"}}, "additionalProperties": false}`,
+			doc:    `{"child": {"child": {"x": 1}}}`,
+			want:   []string{"$.child.child.x property is not allowed"},
+		},
+		{
+			name:   "anyOf",
+			schema: `{"anyOf": [{"type": "string"}, {"minimum": 5}]}`,
+			doc:    `3`,
+			want:   []string{"$ does not match any schema in anyOf"},
+		},
+		{
+			name:   "anyOf matches one",
+			schema: `{"anyOf": [{"type": "string"}, {"minimum": 5}]}`,
+			doc:    `7`,
+		},
+		{
+			name:   "oneOf matches two",
+			schema: `{"oneOf": [{"type": "number"}, {"minimum": 5}]}`,
+			doc:    `7`,
+			want:   []string{"$ matches 2 schemas in oneOf"},
+		},
+		{
+			name:   "oneOf matches none",
+			schema: `{"oneOf": [{"type": "string"}, {"type": "boolean"}]}`,
+			doc:    `7`,
+			want:   []string{"$ matches 0 schemas in oneOf"},
+		},
+		{
+			name:   "oneOf matches exactly one",
+			schema: `{"oneOf": [{"type": "number"}, {"minimum": 5}]}`,
+			doc:    `3`,
+		},
+		{
+			name:   "allOf and not",
+			schema: `{"allOf": [{"minimum": 1}, {"maximum": 5}], "not": {"const": 3}}`,
+			doc:    `3`,
+			want:   []string{"$ must not match"},
+		},
+		{
+			name:   "boolean schemas",
+			schema: `{"properties": {"a": true, "b": false}}`,
+			doc:    `{"a": 1, "b": 2}`,
+			want:   []string{"$.b no value is allowed"},
+		},
+		{
+			name:   "string constraints",
+			schema: `{"items": [{"minLength": 2}, {"maxLength": 2}, {"pattern": "^a"}]}`,
+			doc:    `["é", "abc", "ba"]`,
+			want:   []string{"$[0] string is 1 characters long", "$[1] string is 3 characters long", `$[2] string "ba" does not match`},
+		},
+		{
+			name:   "numeric constraints",
+			schema: `{"items": [{"exclusiveMinimum": 1}, {"exclusiveMaximum": 1}, {"multipleOf": 0.5}, {"maximum": 10}]}`,
+			doc:    `[1, 1, 0.75, 11]`,
+			want:   []string{"$[0] 1 must be greater", "$[1] 1 must be less", "$[2] 0.75 is not a multiple", "$[3] 11 is greater"},
+		},
+		{
+			name:   "array constraints",
+			schema: `{"minItems": 3, "uniqueItems": true, "contains": {"type": "string"}}`,
+			doc:    `[1, 1.0]`,
+			want:   []string{"$ has 2 items", "$ items 0 and 1 are equal", "$ no item matches"},
+		},
+		{
+			name:   "additionalItems",
+			schema: `{"items": [{"type": "string"}], "additionalItems": {"type": "number"}}`,
+			doc:    `["a", 1, "b"]`,
+			want:   []string{"$[2] expected number"},
+		},
+		{
+			name:   "site list",
+			schema: siteListSchema,
+			doc: `{"sites": [
+				{"name": "ok", "uri_check": "https://ok.test/{account}", "cat": "social", "e_code": 200},
+				{"name": "", "uri_check": "https://bad.test/user", "cat": "food", "e_code": "200", "extra": 1},
+				{"uri_check": "https://x.test/{account}", "cat": "tech", "known": [], "headers": {"X": 1}}
+			]}`,
+			want: []string{
+				`$.sites[1].cat value "food" is not one of`,
+				"$.sites[1].e_code expected integer, got string",
+				"$.sites[1].extra property is not allowed",
+				"$.sites[1].name string is 0 characters long",
+				`$.sites[1].uri_check string "https://bad.test/user" does not match`,
+				`$.sites[2] missing required property "name"`,
+				"$.sites[2].headers.X expected string",
+				"$.sites[2].known has 0 items",
+			},
+		},
+		{
+			name:   "site list without sites",
+			schema: siteListSchema,
+			doc:    `{"categories": ["a", "a"]}`,
+			want:   []string{`$ missing required property "sites"`, "$.categories items 0 and 1 are equal"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse([]byte(tt.schema))
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := Decode([]byte(tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := s.Validate(doc)
+			var lines []string
+			for _, v := range got {
+				lines = append(lines, v.Path+" "+v.Message)
+			}
+			slices.Sort(lines)
+
+			want := slices.Clone(tt.want)
+			slices.Sort(want)
+			if len(lines) != len(want) {
+				t.Fatalf("got %d violations, want %d:\n%s", len(lines), len(want), strings.Join(lines, "\n"))
+			}
+			for i := range want {
+				if !strings.HasPrefix(lines[i], want[i]) {
+					t.Errorf("violation %d = %q, want prefix %q", i, lines[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, data := range []string{`[]`, `"x"`, `{`} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%s) succeeded", data)
+		}
+	}
+	if _, err := Parse([]byte(`true`)); err != nil {
+		t.Errorf("Parse(true): %v", err)
+	}
+}
+
+func TestInvalidPattern(t *testing.T) {
+	s, err := Parse([]byte(`{"pattern": "("}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, _ := Decode([]byte(`"x"`))
+	violations := s.Validate(doc)
+	if len(violations) != 1 || !strings.Contains(violations[0].Message, "invalid pattern") {
+		t.Errorf("got %v, want one invalid pattern violation", violations)
+	}
+}
+
+func TestRefCycle(t *testing.T) {
+	cycles := []string{
+		`{"definitions": {"a": {"$ref": "#/definitions/a"}}, "$ref": "#/definitions/a"}`,
+		`{"definitions": {"a": {"$ref": "#/definitions/b"}, "b": {"$ref": "#/definitions/a"}}, "$ref": "#/definitions/a"}`,
+		`{"$ref": "#"}`,
+		`{"definitions": {"a": {"allOf": [{"$ref": "#/definitions/a"}]}}, "$ref": "#/definitions/a"}`,
+		`{"definitions": {"a": {"anyOf": [{"$ref": "#/definitions/a"}]}}, "$ref": "#/definitions/a"}`,
+		`{"definitions": {"a": {"not": {"$ref": "#/definitions/a"}}}, "$ref": "#/definitions/a"}`,
+	}
+	for _, data := range cycles {
+		s, err := Parse([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, _ := Decode([]byte(`{"x": 1}`))
+		violations := s.Validate(doc)
+		if len(violations) == 0 || !strings.Contains(violations[0].Message, "refers back to itself") {
+			t.Errorf("schema %s: got %v, want a $ref cycle violation", data, violations)
+		}
+	}
+
+	// A schema may refer to itself for the children of a value.
+	s, err := Parse([]byte(`{
+		"definitions": {"node": {
+			"type": "object",
+			"required": ["name"],
+			"properties": {"children": {"type": "array", "items": {"$ref": "#/definitions/node"}}}
+		}},
+		"$ref": "#/definitions/node"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, _ := Decode([]byte(`{"name": "a", "children": [{"name": "b", "children": [{"children": []}]}]}`))
+	violations := s.Validate(doc)
+	if len(violations) != 1 || violations[0].Path != "$.children[0].children[0]" {
+		t.Errorf("got %v, want the nameless grandchild", violations)
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/cli"
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/pkg/events"
+)
+
+type Status string
+
+const (
+	StatusRunning  Status = "running"
+	StatusFinished Status = "finished"
+	// StatusAborted is a job cancelled through the API, stopped by its
+	// deadline or by the server shutting down. Its results are partial.
+	StatusAborted Status = "aborted"
+)
+
+// errCancelled is the cause recorded for jobs cancelled through the API.
+var errCancelled = errors.New("cancelled through the API")
+
+// JobRequest is the body of POST /api/v1/scans. The options mirror the
+// scan flags of the same name; the rest of the configuration is the
+// server's.
+type JobRequest struct {
+	Usernames         []string `json:"usernames"`
+	Sites             []string `json:"sites,omitempty"`
+	IncludeCategories []string `json:"include_categories,omitempty"`
+	ExcludeCategories []string `json:"exclude_categories,omitempty"`
+	Fuzzy             bool     `json:"fuzzy,omitempty"`
+	// Deadline is a Go duration such as "90s". It cannot exceed the
+	// server's --deadline.
+	Deadline string `json:"deadline,omitempty"`
+}
+
+// JobStatus is what the API reports about a job.
+type JobStatus struct {
+	ID         string          `json:"id"`
+	Status     Status          `json:"status"`
+	Usernames  []string        `json:"usernames"`
+	Sites      int             `json:"sites"`
+	CreatedAt  time.Time       `json:"created_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	Total      int             `json:"total"`
+	Completed  int             `json:"completed"`
+	Found      int             `json:"found"`
+	Summary    *events.Summary `json:"summary,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// job is one scan. Its events are kept in the --json event stream format
+// so that any number of clients can follow it from the start.
+type job struct {
+	id        string
+	usernames []string
+	sites     []core.Site
+	fuzzy     bool
+	created   time.Time
+	cancel    context.CancelCauseFunc
+
+	mu       sync.Mutex
+	status   Status
+	finished time.Time
+	results  []core.SiteResult
+	found    int
+	err      string
+	events   []event
+	// changed is closed and replaced whenever an event is added or the
+	// job ends, waking the clients following it.
+	changed chan struct{}
+}
+
+// event is one line of the job's event stream.
+type event struct {
+	Type string
+	Data []byte
+}
+
+// Write takes one encoded event from the job's cli.EventStream.
+func (j *job) Write(p []byte) (int, error) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(p, &header); err != nil {
+		return 0, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.events = append(j.events, event{Type: header.Type, Data: append([]byte(nil), p...)})
+	j.notify()
+	return len(p), nil
+}
+
+// notify wakes the clients following the job. j.mu must be held.
+func (j *job) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// run scans the job's usernames with checker and records the results.
+func (j *job) run(ctx context.Context, checker *core.Checker, progressInterval time.Duration) {
+	stream := cli.NewEventStream(j, progressInterval)
+	stream.RunStarted(events.ModeUsernames, j.usernames, j.sites, len(j.usernames)*len(j.sites))
+
+	progressChan := make(chan core.SiteResult, len(j.sites))
+	go func() {
+		checker.CheckUsernames(ctx, j.usernames, j.sites, j.fuzzy, progressChan)
+		close(progressChan)
+	}()
+
+	for result := range progressChan {
+		// The API serves results, not response bodies.
+		result.ResponseText = ""
+		j.mu.Lock()
+		j.results = append(j.results, result)
+		if result.ResultStatus == core.ResultStatusFound {
+			j.found++
+		}
+		j.mu.Unlock()
+		stream.Result(result, true)
+	}
+
+	var runErr error
+	if ctx.Err() != nil {
+		runErr = context.Cause(ctx)
+	}
+
+	j.mu.Lock()
+	results := j.results
+	j.mu.Unlock()
+	stream.RunFinished(results, runErr)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status = StatusFinished
+	if runErr != nil {
+		j.status = StatusAborted
+		j.err = runErr.Error()
+	}
+	j.finished = time.Now()
+	j.notify()
+}
+
+func (j *job) snapshot() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status := JobStatus{
+		ID:        j.id,
+		Status:    j.status,
+		Usernames: j.usernames,
+		Sites:     len(j.sites),
+		CreatedAt: j.created,
+		Total:     len(j.usernames) * len(j.sites),
+		Completed: len(j.results),
+		Found:     j.found,
+		Error:     j.err,
+	}
+	if j.status != StatusRunning {
+		finished := j.finished
+		status.FinishedAt = &finished
+		summary := cli.Summarize(j.results)
+		status.Summary = &summary
+	}
+	return status
+}
+
+// eventsSince returns the events after the first n, whether the job has
+// ended, and a channel that is closed when there is more to read.
+func (j *job) eventsSince(n int) ([]event, bool, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if n > len(j.events) {
+		n = len(j.events)
+	}
+	return j.events[n:], j.status != StatusRunning, j.changed
+}
+
+func (j *job) running() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status == StatusRunning
+}
@@ -0,0 +1,405 @@
+// Package server is the REST API of usrsx serve. Clients submit scan jobs,
+// poll their status, follow their events as Server-Sent Events and fetch
+// the results in any export format. All jobs run through one core.Checker,
+// so its --max-tasks is a cap across jobs.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/cli"
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/internal/utils"
+)
+
+type Config struct {
+	Checker *core.Checker
+
+	// Sites are the sites jobs can check, after the server's own filters.
+	Sites []core.Site
+
+	// Tokens are the API tokens accepted as Bearer credentials.
+	Tokens []string
+
+	// Deadline is the default and longest run time of a job; 0 for none.
+	Deadline time.Duration
+
+	// MaxJobs is how many jobs are kept. The oldest finished job makes room
+	// for a new one; new jobs are refused while all of them are running.
+	MaxJobs int
+
+	// ProgressInterval spaces out the progress events of a job.
+	ProgressInterval time.Duration
+}
+
+type Server struct {
+	config Config
+	mux    *http.ServeMux
+
+	// ctx is the parent of all jobs and is cancelled by Close.
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	// order is the job IDs from oldest to newest.
+	order []string
+}
+
+// errShutdown is the cause recorded for jobs stopped by Close.
+var errShutdown = errors.New("server shutting down")
+
+func New(config Config) *Server {
+	if config.MaxJobs <= 0 {
+		config.MaxJobs = core.ServeMaxJobs
+	}
+	if config.ProgressInterval <= 0 {
+		config.ProgressInterval = core.ProgressEventIntervalMillis * time.Millisecond
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	s := &Server{
+		config: config,
+		mux:    http.NewServeMux(),
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   make(map[string]*job),
+	}
+	s.mux.HandleFunc("POST /api/v1/scans", s.handleCreate)
+	s.mux.HandleFunc("GET /api/v1/scans", s.handleList)
+	s.mux.HandleFunc("GET /api/v1/scans/{id}", s.handleStatus)
+	s.mux.HandleFunc("DELETE /api/v1/scans/{id}", s.handleDelete)
+	s.mux.HandleFunc("GET /api/v1/scans/{id}/events", s.handleEvents)
+	s.mux.HandleFunc("GET /api/v1/scans/{id}/results", s.handleResults)
+	return s
+}
+
+// Handler returns the API, which requires one of the tokens on every
+// request.
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="usrsx"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid API token")
+			return
+		}
+		s.mux.ServeHTTP(w, r)
+	})
+}
+
+// Close aborts the running jobs and waits for them to record their
+// partial results.
+func (s *Server) Close() {
+	s.cancel(errShutdown)
+	s.wg.Wait()
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	valid := 0
+	for _, t := range s.config.Tokens {
+		valid |= subtle.ConstantTimeCompare([]byte(token), []byte(t))
+	}
+	return valid == 1
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, core.ServeMaxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	j, deadline, err := s.newJob(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithCancelCause(s.ctx)
+	j.cancel = cancel
+	if deadline > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, deadline, fmt.Errorf("deadline of %s exceeded", deadline))
+		j.cancel = func(cause error) {
+			cancel(cause)
+			cancelTimeout()
+		}
+	}
+	if !s.add(j) {
+		j.cancel(nil)
+		writeError(w, http.StatusTooManyRequests, fmt.Sprintf("%d jobs are running, try again later", s.config.MaxJobs))
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer j.cancel(nil)
+		j.run(ctx, s.config.Checker, s.config.ProgressInterval)
+	}()
+
+	w.Header().Set("Location", "/api/v1/scans/"+j.id)
+	writeJSON(w, http.StatusAccepted, j.snapshot())
+}
+
+// newJob validates req and returns the job it asks for, with its deadline.
+func (s *Server) newJob(req JobRequest) (*job, time.Duration, error) {
+	usernames, err := utils.ValidateUsernames(req.Usernames)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sites, err := utils.FilterSites(req.Sites, s.config.Sites)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(req.IncludeCategories) > 0 || len(req.ExcludeCategories) > 0 {
+		sites = cli.FilterSitesByCategory(sites, req.IncludeCategories, req.ExcludeCategories)
+	}
+	if len(sites) == 0 {
+		return nil, 0, errors.New("no sites left to check")
+	}
+
+	deadline := s.config.Deadline
+	if req.Deadline != "" {
+		deadline, err = time.ParseDuration(req.Deadline)
+		if err != nil || deadline <= 0 {
+			return nil, 0, fmt.Errorf("invalid deadline %q", req.Deadline)
+		}
+		if s.config.Deadline > 0 && deadline > s.config.Deadline {
+			return nil, 0, fmt.Errorf("deadline %s exceeds the server's %s", deadline, s.config.Deadline)
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, 0, err
+	}
+
+	return &job{
+		id:        hex.EncodeToString(id),
+		usernames: usernames,
+		sites:     sites,
+		fuzzy:     req.Fuzzy,
+		created:   time.Now(),
+		status:    StatusRunning,
+		changed:   make(chan struct{}),
+	}, deadline, nil
+}
+
+// add registers j, dropping the oldest finished job when MaxJobs are kept.
+// It reports false when all of them are still running.
+func (s *Server) add(j *job) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.order) >= s.config.MaxJobs {
+		dropped := false
+		for i, id := range s.order {
+			if !s.jobs[id].running() {
+				delete(s.jobs, id)
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			return false
+		}
+	}
+
+	s.jobs[j.id] = j
+	s.order = append(s.order, j.id)
+	return true
+}
+
+// lookup returns the job named in the request path, or writes a 404.
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) *job {
+	s.mu.Lock()
+	j := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if j == nil {
+		writeError(w, http.StatusNotFound, "no such job")
+	}
+	return j
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	jobs := make([]*job, 0, len(s.order))
+	for _, id := range s.order {
+		jobs = append(jobs, s.jobs[id])
+	}
+	s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(jobs))
+	for _, j := range jobs {
+		statuses = append(statuses, j.snapshot())
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": statuses})
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if j := s.lookup(w, r); j != nil {
+		writeJSON(w, http.StatusOK, j.snapshot())
+	}
+}
+
+// handleDelete aborts a running job, keeping its partial results, or
+// removes a job that has ended.
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	if j.running() {
+		j.cancel(errCancelled)
+		writeJSON(w, http.StatusAccepted, j.snapshot())
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.jobs, j.id)
+	for i, id := range s.order {
+		if id == j.id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleEvents streams the job's events from the start, or after the one
+// in Last-Event-ID, and follows the job until it ends. Each event is the
+// JSON of the --json event stream, with its type as the SSE event name.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	next := 0
+	if last, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil && last > 0 {
+		next = last
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	keepAlive := time.NewTicker(core.ServeKeepAliveSeconds * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		pending, ended, changed := j.eventsSince(next)
+		for _, e := range pending {
+			next++
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", next, e.Type, bytes.TrimRight(e.Data, "\n"))
+		}
+		flusher.Flush()
+		if ended {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// resultFormats maps the format parameter of the results endpoint to the
+// exporter writing it and its content type.
+var resultFormats = map[string]struct {
+	contentType string
+	write       func(*cli.Exporter, io.Writer) error
+}{
+	"json": {"application/json", (*cli.Exporter).WriteJSON},
+	"csv":  {"text/csv; charset=utf-8", (*cli.Exporter).WriteCSV},
+	"html": {"text/html; charset=utf-8", (*cli.Exporter).WriteHTML},
+	"pdf":  {"application/pdf", (*cli.Exporter).WritePDF},
+	"md":   {"text/markdown; charset=utf-8", (*cli.Exporter).WriteMarkdown},
+	"txt":  {"text/plain; charset=utf-8", (*cli.Exporter).WriteText},
+}
+
+// handleResults writes the results of an ended job in ?format=, json by
+// default.
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	j := s.lookup(w, r)
+	if j == nil {
+		return
+	}
+
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = "json"
+	}
+	format, ok := resultFormats[name]
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q (valid: json, csv, html, pdf, md, txt)", name))
+		return
+	}
+
+	j.mu.Lock()
+	running := j.status == StatusRunning
+	results := j.results
+	j.mu.Unlock()
+	if running {
+		writeError(w, http.StatusConflict, "job is still running")
+		return
+	}
+
+	exporter := cli.NewExporter(results, j.usernames)
+	exporter.Timestamp = j.created
+
+	// Rendered in full first, so that a failure is still an error response.
+	var body bytes.Buffer
+	if err := format.write(exporter, &body); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="usrsx-%s.%s"`, j.id, name))
+	w.Write(body.Bytes())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,396 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/client"
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+const testToken = "secret"
+
+// testAPI is a server whose jobs check two sites on a local httptest
+// server: "fast" answers right away, "slow" only once release is called.
+type testAPI struct {
+	*httptest.Server
+	api *Server
+
+	releaseOnce sync.Once
+	block       chan struct{}
+}
+
+func newTestAPI(t *testing.T, config Config) *testAPI {
+	t.Helper()
+	ta := &testAPI{block: make(chan struct{})}
+
+	sites := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/slow/") {
+			select {
+			case <-ta.block:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if strings.HasSuffix(r.URL.Path, "/alice") {
+			fmt.Fprint(w, "<title>profile</title>")
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "nope")
+	}))
+
+	httpClient, err := client.NewHTTPClient(client.ClientConfig{Timeout: 5, Impersonate: client.BrowserNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, missing := http.StatusOK, http.StatusNotFound
+	for _, name := range []string{"fast", "slow"} {
+		config.Sites = append(config.Sites, core.Site{
+			Name:     name,
+			Category: "test",
+			URICheck: sites.URL + "/" + name + "/" + core.AccountPlaceholder,
+			ECode:    &found,
+			EString:  "profile",
+			MCode:    &missing,
+			MString:  "nope",
+		})
+	}
+	config.Checker = core.NewChecker(httpClient, nil, core.CheckerConfig{MaxTasks: 4})
+	if config.Tokens == nil {
+		config.Tokens = []string{"other", testToken}
+	}
+
+	ta.api = New(config)
+	ta.Server = httptest.NewServer(ta.api.Handler())
+	t.Cleanup(func() {
+		ta.release()
+		ta.Server.Close()
+		ta.api.Close()
+		sites.Close()
+	})
+	return ta
+}
+
+func (ta *testAPI) release() {
+	ta.releaseOnce.Do(func() { close(ta.block) })
+}
+
+func (ta *testAPI) do(t *testing.T, method, path string, body interface{}, header ...string) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, ta.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func decode[T any](t *testing.T, resp *http.Response) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// create submits a job and returns its status.
+func (ta *testAPI) create(t *testing.T, req JobRequest) JobStatus {
+	t.Helper()
+	resp := ta.do(t, http.MethodPost, "/api/v1/scans", req)
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("create: %s %s", resp.Status, body)
+	}
+	status := decode[JobStatus](t, resp)
+	if loc := resp.Header.Get("Location"); loc != "/api/v1/scans/"+status.ID {
+		t.Errorf("Location = %q", loc)
+	}
+	return status
+}
+
+// wait polls a job until it has ended.
+func (ta *testAPI) wait(t *testing.T, id string) JobStatus {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		status := decode[JobStatus](t, ta.do(t, http.MethodGet, "/api/v1/scans/"+id, nil))
+		if status.Status != StatusRunning {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not end", id)
+	return JobStatus{}
+}
+
+func TestAuth(t *testing.T) {
+	ta := newTestAPI(t, Config{})
+
+	tests := []struct {
+		header string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer ", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Bearer secretsecret", http.StatusUnauthorized},
+		{"Basic " + testToken, http.StatusUnauthorized},
+		{"bearer " + testToken, http.StatusUnauthorized},
+		{"Bearer " + testToken, http.StatusOK},
+		{"Bearer other", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, ta.URL+"/api/v1/scans", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("Authorization %q: %s, want %d", tt.header, resp.Status, tt.status)
+		}
+		if tt.status == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("Authorization %q: no WWW-Authenticate challenge", tt.header)
+		}
+	}
+
+	// Without tokens nothing is accepted, not even an empty one.
+	none := newTestAPI(t, Config{Tokens: []string{}})
+	if resp := none.do(t, http.MethodGet, "/api/v1/scans", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("server without tokens: %s", resp.Status)
+	}
+}
+
+func TestCreateInvalid(t *testing.T) {
+	ta := newTestAPI(t, Config{Deadline: time.Minute})
+
+	tests := []struct {
+		name string
+		body interface{}
+	}{
+		{"unknown field", map[string]interface{}{"usernames": []string{"alice"}, "threads": 4}},
+		{"no usernames", JobRequest{}},
+		{"unknown site", JobRequest{Usernames: []string{"alice"}, Sites: []string{"nowhere"}}},
+		{"no sites left", JobRequest{Usernames: []string{"alice"}, ExcludeCategories: []string{"test"}}},
+		{"invalid deadline", JobRequest{Usernames: []string{"alice"}, Deadline: "soon"}},
+		{"deadline too long", JobRequest{Usernames: []string{"alice"}, Deadline: "2m"}},
+	}
+	for _, tt := range tests {
+		resp := ta.do(t, http.MethodPost, "/api/v1/scans", tt.body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: %s, want 400", tt.name, resp.Status)
+		}
+	}
+}
+
+func TestJob(t *testing.T) {
+	ta := newTestAPI(t, Config{})
+	job := ta.create(t, JobRequest{Usernames: []string{"alice", "bob"}, Sites: []string{"fast"}})
+	if job.Status != StatusRunning || job.Sites != 1 || job.Total != 2 {
+		t.Errorf("new job = %+v", job)
+	}
+
+	status := ta.wait(t, job.ID)
+	if status.Status != StatusFinished || status.Completed != 2 || status.Found != 1 ||
+		status.Summary == nil || status.FinishedAt == nil {
+		t.Errorf("finished job = %+v", status)
+	}
+
+	resp := ta.do(t, http.MethodGet, "/api/v1/scans/"+job.ID+"/results", nil)
+	var results struct {
+		Results []core.SiteResult `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 2 {
+		t.Errorf("got %d results, want 2", len(results.Results))
+	}
+
+	resp = ta.do(t, http.MethodGet, "/api/v1/scans/"+job.ID+"/results?format=csv", nil)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("csv results served as %q", ct)
+	}
+	if resp := ta.do(t, http.MethodGet, "/api/v1/scans/"+job.ID+"/results?format=xml", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown format: %s, want 400", resp.Status)
+	}
+
+	if resp := ta.do(t, http.MethodDelete, "/api/v1/scans/"+job.ID, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("deleting a finished job: %s, want 204", resp.Status)
+	}
+	if resp := ta.do(t, http.MethodGet, "/api/v1/scans/"+job.ID, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted job: %s, want 404", resp.Status)
+	}
+}
+
+func TestJobLimits(t *testing.T) {
+	ta := newTestAPI(t, Config{MaxJobs: 2})
+	slow := JobRequest{Usernames: []string{"alice"}, Sites: []string{"slow"}}
+
+	first := ta.create(t, slow)
+	second := ta.create(t, slow)
+	if resp := ta.do(t, http.MethodPost, "/api/v1/scans", slow); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("third job: %s, want 429 while two are running", resp.Status)
+	}
+	if resp := ta.do(t, http.MethodGet, "/api/v1/scans/"+first.ID+"/results", nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("results of a running job: %s, want 409", resp.Status)
+	}
+
+	// Cancelling the first job keeps its partial results and makes room.
+	if resp := ta.do(t, http.MethodDelete, "/api/v1/scans/"+first.ID, nil); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("cancelling a running job: %s, want 202", resp.Status)
+	}
+	status := ta.wait(t, first.ID)
+	if status.Status != StatusAborted || status.Error != errCancelled.Error() {
+		t.Errorf("cancelled job = %s (%s)", status.Status, status.Error)
+	}
+
+	third := ta.create(t, slow)
+	list := decode[struct{ Jobs []JobStatus }](t, ta.do(t, http.MethodGet, "/api/v1/scans", nil))
+	var ids []string
+	for _, job := range list.Jobs {
+		ids = append(ids, job.ID)
+	}
+	if want := []string{second.ID, third.ID}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("jobs = %v, want %v with the ended one dropped", ids, want)
+	}
+
+	ta.release()
+	if status := ta.wait(t, third.ID); status.Status != StatusFinished || status.Found != 1 {
+		t.Errorf("released job = %+v", status)
+	}
+}
+
+func TestJobDeadline(t *testing.T) {
+	ta := newTestAPI(t, Config{})
+	job := ta.create(t, JobRequest{Usernames: []string{"alice"}, Sites: []string{"slow"}, Deadline: "50ms"})
+	status := ta.wait(t, job.ID)
+	if status.Status != StatusAborted || !strings.Contains(status.Error, "deadline of 50ms exceeded") {
+		t.Errorf("job = %s (%s), want aborted by its deadline", status.Status, status.Error)
+	}
+}
+
+type sseEvent struct {
+	id, name, data string
+}
+
+// readEvents reads an event stream to its end.
+func readEvents(t *testing.T, r io.Reader) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if current != (sseEvent{}) {
+				events = append(events, current)
+			}
+			current = sseEvent{}
+		case strings.HasPrefix(line, "id: "):
+			current.id = line[len("id: "):]
+		case strings.HasPrefix(line, "event: "):
+			current.name = line[len("event: "):]
+		case strings.HasPrefix(line, "data: "):
+			current.data = line[len("data: "):]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestEvents(t *testing.T) {
+	ta := newTestAPI(t, Config{})
+	job := ta.create(t, JobRequest{Usernames: []string{"alice"}, Sites: []string{"fast", "slow"}})
+
+	// Follow the job while it runs; the stream ends with the job.
+	resp := ta.do(t, http.MethodGet, "/api/v1/scans/"+job.ID+"/events", nil)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	reader := bufio.NewReader(resp.Body)
+	first, err := reader.ReadString('\n')
+	if err != nil || first != "id: 1\n" {
+		t.Fatalf("first line = %q, %v", first, err)
+	}
+	ta.release()
+	live := readEvents(t, io.MultiReader(strings.NewReader(first), reader))
+
+	if len(live) < 4 {
+		t.Fatalf("got %d events, want at least run_started, two results and run_finished", len(live))
+	}
+	for i, e := range live {
+		if e.id != fmt.Sprint(i+1) {
+			t.Errorf("event %d has id %q", i, e.id)
+		}
+		var data struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal([]byte(e.data), &data); err != nil || data.Type != e.name {
+			t.Errorf("event %s: name %q, data %s", e.id, e.name, e.data)
+		}
+	}
+	if live[0].name != "run_started" || live[len(live)-1].name != "run_finished" {
+		t.Errorf("events run from %s to %s", live[0].name, live[len(live)-1].name)
+	}
+
+	// A finished job replays all of its events, or those after
+	// Last-Event-ID.
+	replay := readEvents(t, ta.do(t, http.MethodGet, "/api/v1/scans/"+job.ID+"/events", nil).Body)
+	if !reflect.DeepEqual(replay, live) {
+		t.Errorf("replay differs from the live stream:\n%v\n%v", replay, live)
+	}
+	resumed := readEvents(t, ta.do(t, http.MethodGet, "/api/v1/scans/"+job.ID+"/events", nil, "Last-Event-ID", "2").Body)
+	if !reflect.DeepEqual(resumed, live[2:]) {
+		t.Errorf("resumed after 2:\n%v\nwant\n%v", resumed, live[2:])
+	}
+	past := readEvents(t, ta.do(t, http.MethodGet, "/api/v1/scans/"+job.ID+"/events", nil, "Last-Event-ID", "999").Body)
+	if len(past) != 0 {
+		t.Errorf("resumed past the end: %v", past)
+	}
+}
+
+func TestClose(t *testing.T) {
+	ta := newTestAPI(t, Config{})
+	job := ta.create(t, JobRequest{Usernames: []string{"alice"}, Sites: []string{"fast", "slow"}})
+
+	ta.api.Close()
+	status := decode[JobStatus](t, ta.do(t, http.MethodGet, "/api/v1/scans/"+job.ID, nil))
+	if status.Status != StatusAborted || status.Error != errShutdown.Error() {
+		t.Errorf("job = %s (%s), want aborted by the shutdown", status.Status, status.Error)
+	}
+}
@@ -0,0 +1,354 @@
+// Package store keeps the history of scans in an SQLite database: one row
+// per run with its configuration and the site lists it used, and every
+// result of the run including profile metadata. Response bodies are not
+// stored; --save-response keeps them on disk and the results point to
+// them.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+// schemaVersion is stored in PRAGMA user_version. Open upgrades older
+// databases step by step through migrations.
+const schemaVersion = 1
+
+var migrations = []string{
+	`CREATE TABLE runs (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		started_at  TEXT NOT NULL,
+		finished_at TEXT NOT NULL,
+		version     TEXT NOT NULL,
+		mode        TEXT NOT NULL,
+		usernames   TEXT NOT NULL,
+		config      TEXT NOT NULL,
+		error       TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE site_lists (
+		run_id INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+		source TEXT NOT NULL,
+		sha256 TEXT NOT NULL,
+		sites  INTEGER NOT NULL
+	);
+	CREATE TABLE results (
+		id             INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id         INTEGER NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+		username       TEXT NOT NULL,
+		site_name      TEXT NOT NULL,
+		category       TEXT NOT NULL,
+		result_status  TEXT NOT NULL,
+		result_url     TEXT NOT NULL,
+		response_code  INTEGER NOT NULL,
+		response_path  TEXT NOT NULL,
+		elapsed        REAL NOT NULL,
+		attempts       INTEGER NOT NULL,
+		proxy          TEXT NOT NULL,
+		error          TEXT NOT NULL,
+		created_at     TEXT NOT NULL,
+		false_positive INTEGER NOT NULL,
+		metadata       TEXT
+	);
+	CREATE INDEX site_lists_run ON site_lists(run_id);
+	CREATE INDEX results_run ON results(run_id);
+	CREATE INDEX results_username_site ON results(username, site_name);`,
+}
+
+// Store is an open history database.
+type Store struct {
+	db *sql.DB
+}
+
+// Run is one recorded scan.
+type Run struct {
+	ID         int64
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Version    string
+	Mode       string
+	Usernames  []string
+
+	// Config is the scan configuration as a JSON object.
+	Config json.RawMessage
+
+	Lists []core.SiteListVersion
+
+	// Error is why the run was aborted, if it was.
+	Error string
+
+	// Total and Found count the run's results. They are filled in when
+	// reading runs back and ignored by SaveRun.
+	Total int
+	Found int
+}
+
+// DefaultPath returns history.db under usrsx's directory in the user's
+// data directory ($XDG_DATA_HOME or ~/.local/share on Linux).
+func DefaultPath() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "usrsx", "history.db")
+}
+
+// Open opens the database at path, creating it and its directory if
+// needed.
+func Open(path string) (*Store, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no history database path")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	// SQLite allows one writer at a time; a single connection serialises
+	// this process's statements instead of failing them with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read history database version: %w", err)
+	}
+	if version > schemaVersion {
+		return fmt.Errorf("history database version %d is newer than this usrsx supports (%d)", version, schemaVersion)
+	}
+
+	for ; version < schemaVersion; version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to migrate history database to version %d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveRun records run and its results in one transaction and returns the
+// new run's ID.
+func (s *Store) SaveRun(ctx context.Context, run Run, results []core.SiteResult) (int64, error) {
+	usernames, err := json.Marshal(run.Usernames)
+	if err != nil {
+		return 0, err
+	}
+	config := run.Config
+	if len(config) == 0 {
+		config = json.RawMessage("{}")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record run: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO runs (started_at, finished_at, version, mode, usernames, config, error) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		formatTime(run.StartedAt), formatTime(run.FinishedAt), run.Version, run.Mode, string(usernames), string(config), run.Error)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record run: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, list := range run.Lists {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO site_lists (run_id, source, sha256, sites) VALUES (?, ?, ?, ?)`,
+			id, list.Source, list.SHA256, list.Sites); err != nil {
+			return 0, fmt.Errorf("failed to record site list: %w", err)
+		}
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO results (
+		run_id, username, site_name, category, result_status, result_url, response_code, response_path,
+		elapsed, attempts, proxy, error, created_at, false_positive, metadata
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for _, result := range results {
+		var metadata interface{}
+		if result.Metadata != nil {
+			data, err := json.Marshal(result.Metadata)
+			if err != nil {
+				return 0, err
+			}
+			metadata = string(data)
+		}
+		if _, err := stmt.ExecContext(ctx,
+			id, result.Username, result.SiteName, result.Category, string(result.ResultStatus), result.ResultURL,
+			result.ResponseCode, result.ResponsePath, result.Elapsed, result.Attempts, result.Proxy, result.Error,
+			formatTime(result.CreatedAt), result.FalsePositive, metadata); err != nil {
+			return 0, fmt.Errorf("failed to record result: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to record run: %w", err)
+	}
+	return id, nil
+}
+
+const runColumns = `r.id, r.started_at, r.finished_at, r.version, r.mode, r.usernames, r.config, r.error,
+	(SELECT COUNT(*) FROM results WHERE run_id = r.id),
+	(SELECT COUNT(*) FROM results WHERE run_id = r.id AND result_status = 'found')`
+
+// Runs returns the most recent runs first. limit <= 0 returns all of them.
+func (s *Store) Runs(ctx context.Context, limit int) ([]Run, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+runColumns+` FROM runs r ORDER BY r.id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// ErrRunNotFound is returned by Run for an unknown ID.
+var ErrRunNotFound = errors.New("run not found")
+
+// Run returns the run with the given ID, including its site lists.
+func (s *Store) Run(ctx context.Context, id int64) (Run, error) {
+	run, err := scanRun(s.db.QueryRowContext(ctx, `SELECT `+runColumns+` FROM runs r WHERE r.id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Run{}, fmt.Errorf("%w: %d", ErrRunNotFound, id)
+	}
+	if err != nil {
+		return Run{}, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT source, sha256, sites FROM site_lists WHERE run_id = ? ORDER BY rowid`, id)
+	if err != nil {
+		return Run{}, fmt.Errorf("failed to read site lists: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var list core.SiteListVersion
+		if err := rows.Scan(&list.Source, &list.SHA256, &list.Sites); err != nil {
+			return Run{}, err
+		}
+		run.Lists = append(run.Lists, list)
+	}
+	return run, rows.Err()
+}
+
+// Results returns the results of a run in the order they were recorded.
+func (s *Store) Results(ctx context.Context, runID int64) ([]core.SiteResult, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT
+		username, site_name, category, result_status, result_url, response_code, response_path,
+		elapsed, attempts, proxy, error, created_at, false_positive, metadata
+	FROM results WHERE run_id = ? ORDER BY id`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results: %w", err)
+	}
+	defer rows.Close()
+
+	var results []core.SiteResult
+	for rows.Next() {
+		var result core.SiteResult
+		var status, createdAt string
+		var metadata sql.NullString
+		if err := rows.Scan(&result.Username, &result.SiteName, &result.Category, &status, &result.ResultURL,
+			&result.ResponseCode, &result.ResponsePath, &result.Elapsed, &result.Attempts, &result.Proxy,
+			&result.Error, &createdAt, &result.FalsePositive, &metadata); err != nil {
+			return nil, fmt.Errorf("failed to read result: %w", err)
+		}
+		result.ResultStatus = core.ResultStatus(status)
+		result.CreatedAt = parseTime(createdAt)
+		if metadata.Valid {
+			result.Metadata = &core.ProfileMetadata{}
+			if err := json.Unmarshal([]byte(metadata.String), result.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to read metadata of %s on %s: %w", result.Username, result.SiteName, err)
+			}
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRun(row scanner) (Run, error) {
+	var run Run
+	var startedAt, finishedAt, usernames, config string
+	if err := row.Scan(&run.ID, &startedAt, &finishedAt, &run.Version, &run.Mode, &usernames, &config,
+		&run.Error, &run.Total, &run.Found); err != nil {
+		return Run{}, err
+	}
+	run.StartedAt = parseTime(startedAt)
+	run.FinishedAt = parseTime(finishedAt)
+	run.Config = json.RawMessage(config)
+	if err := json.Unmarshal([]byte(usernames), &run.Usernames); err != nil {
+		return Run{}, fmt.Errorf("failed to read usernames of run %d: %w", run.ID, err)
+	}
+	return run, nil
+}
+
+// Times are stored as RFC 3339 text in UTC so they sort and read well in
+// the sqlite3 shell.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
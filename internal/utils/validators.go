@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
+	"github.com/gnomegl/usrsx/internal/client"
 	"github.com/gnomegl/usrsx/internal/core"
 )
 
@@ -36,27 +38,95 @@ func ValidateNumericValues(maxTasks, timeout int) error {
 	return nil
 }
 
-func ValidateProxy(proxy string) error {
-	if proxy == "" {
-		return nil
+func ValidatePerHostRPS(rps float64) error {
+	if rps < 0 {
+		return core.NewConfigurationError(
+			fmt.Sprintf("Invalid per-host-rps: %g must not be negative", rps),
+			nil,
+		)
+	}
+	return nil
+}
+
+func ValidateRetryAttempts(attempts int) error {
+	if attempts < 1 || attempts > core.MaxRetryAttemptsLimit {
+		return core.NewConfigurationError(
+			fmt.Sprintf("Invalid retries: %d must be between 1 and %d", attempts, core.MaxRetryAttemptsLimit),
+			nil,
+		)
 	}
+	return nil
+}
 
-	if !strings.HasPrefix(proxy, "http://") &&
-		!strings.HasPrefix(proxy, "https://") &&
-		!strings.HasPrefix(proxy, "socks5://") {
+func ValidateBrowseLimit(limit int) error {
+	if limit < 0 {
 		return core.NewConfigurationError(
-			"Invalid proxy: must be http://, https://, or socks5:// URL",
+			fmt.Sprintf("Invalid browse-limit: %d must not be negative", limit),
 			nil,
 		)
 	}
+	return nil
+}
 
-	_, err := url.Parse(proxy)
-	if err != nil {
+func ValidateWatchInterval(interval time.Duration) error {
+	if interval < core.WatchMinIntervalSeconds*time.Second {
 		return core.NewConfigurationError(
-			fmt.Sprintf("Invalid proxy URL: %v", err),
-			err,
+			fmt.Sprintf("Invalid interval: %s must be at least %ds", interval, core.WatchMinIntervalSeconds),
+			nil,
 		)
 	}
+	return nil
+}
+
+func ValidateWebhookURL(webhook string) error {
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		// The URL is left out: webhook URLs often embed a secret token.
+		return core.NewConfigurationError("Invalid webhook: must be an http or https URL", nil)
+	}
+	return nil
+}
+
+// ParseResultStatuses validates the statuses given to --save-status.
+func ParseResultStatuses(values []string) ([]core.ResultStatus, error) {
+	known := []core.ResultStatus{
+		core.ResultStatusFound,
+		core.ResultStatusNotFound,
+		core.ResultStatusError,
+		core.ResultStatusUnknown,
+		core.ResultStatusAmbiguous,
+		core.ResultStatusNotValid,
+	}
+
+	statuses := make([]core.ResultStatus, 0, len(values))
+	for _, value := range values {
+		status := core.ResultStatus(strings.ToLower(strings.TrimSpace(value)))
+		valid := false
+		for _, k := range known {
+			if status == k {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, core.NewConfigurationError(
+				fmt.Sprintf("Invalid save-status: %q (valid: found, not_found, error, unknown, ambiguous, not_valid)", value),
+				nil,
+			)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func ValidateProxy(proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+
+	if _, err := client.ParseProxy(proxy); err != nil {
+		return core.NewConfigurationError("Invalid proxy", err)
+	}
 
 	return nil
 }
@@ -0,0 +1,67 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/diff"
+	"github.com/gnomegl/usrsx/internal/notify"
+)
+
+// Event is one line of a JSONLines sink: a change and when the scan that
+// found it ran.
+type Event struct {
+	Time time.Time `json:"time"`
+	diff.Change
+}
+
+// JSONLines writes every change as a line of JSON.
+type JSONLines struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewJSONLines(w io.Writer) *JSONLines {
+	return &JSONLines{w: w}
+}
+
+func (s *JSONLines) Send(ctx context.Context, report diff.Report) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	encoder := json.NewEncoder(s.w)
+	for _, change := range report.Changes {
+		if err := encoder.Encode(Event{Time: report.New.Timestamp, Change: change}); err != nil {
+			return fmt.Errorf("failed to write change: %w", err)
+		}
+	}
+	return nil
+}
+
+// Webhook POSTs the changes of a scan as one JSON report, in the format of
+// usrsx diff --diff-json. Posts are retried like --notify messages.
+type Webhook struct {
+	url    string
+	poster *notify.Poster
+}
+
+// NewWebhook returns a sink that posts to target with the spacing, retry
+// and client settings of config.
+func NewWebhook(target string, config notify.Config) *Webhook {
+	return &Webhook{url: target, poster: notify.NewPoster(config)}
+}
+
+func (s *Webhook) Send(ctx context.Context, report diff.Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode changes: %w", err)
+	}
+	if err := s.poster.Post(ctx, s.url, "application/json", body); err != nil {
+		return fmt.Errorf("webhook failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+// State is the last scan of a watcher.
+type State struct {
+	Usernames []string          `json:"usernames"`
+	LastScan  time.Time         `json:"last_scan"`
+	Results   []core.SiteResult `json:"results"`
+}
+
+// LoadState reads the state file at path. It returns nil without an error
+// when the file does not exist yet.
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watch state: %w", err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode watch state %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// SaveState replaces the state file at path, creating its directory if
+// needed. The file is written next to path and renamed over it, so a
+// watcher killed while saving leaves the previous state intact.
+func SaveState(path string, state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode watch state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create watch state directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
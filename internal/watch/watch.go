@@ -0,0 +1,235 @@
+// Package watch rescans usernames on a schedule and reports what changed
+// since the previous scan: accounts that appeared, accounts that
+// disappeared, and changed profile metadata. The last scan is kept in a
+// state file, so a restarted watcher compares against it and keeps to the
+// schedule instead of starting over.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/internal/diff"
+)
+
+// Clock is the time source of the scheduler. Tests can drive the watcher
+// with a fake one instead of waiting for real intervals.
+type Clock interface {
+	Now() time.Time
+	// After delivers the time on the channel once d has passed, like
+	// time.After.
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the wall clock.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Sink receives the changes of every scan that found any.
+type Sink interface {
+	Send(ctx context.Context, report diff.Report) error
+}
+
+type Config struct {
+	Usernames []string
+	Interval  time.Duration
+
+	// StatePath is the file the last scan is kept in.
+	StatePath string
+
+	// Scan checks Usernames once. Results it could not settle (errors,
+	// unknown and cancelled checks) keep the previous scan's result, so a
+	// flaky site does not show up as an account that disappeared and came
+	// back.
+	Scan func(ctx context.Context) ([]core.SiteResult, error)
+
+	// Sinks receive the changes of each scan. When one fails the scan is
+	// not saved, so its changes are sent again with the next scan, also to
+	// the sinks that took them.
+	Sinks []Sink
+
+	// Clock defaults to SystemClock.
+	Clock Clock
+
+	// OnScan is called after each scan with the changes that were sent, if
+	// any, and when the next scan is due.
+	OnScan func(report diff.Report, next time.Time)
+
+	// OnError is called for a scan, sink or state file that failed. The
+	// watcher carries on with the next scan.
+	OnError func(err error)
+}
+
+// reported are the kinds of change a watcher sends. Other status changes,
+// such as a found account that is ambiguous this time, are too noisy to
+// alert on and are only counted in the summary.
+var reported = map[diff.Kind]bool{
+	diff.KindAppeared:        true,
+	diff.KindDisappeared:     true,
+	diff.KindMetadataChanged: true,
+}
+
+// Run scans every Interval until ctx is done. The first scan is due
+// Interval after the one recorded in the state file, or right away
+// without one; every account the first scan without a state file finds
+// is reported as new.
+func Run(ctx context.Context, config Config) error {
+	if config.Interval <= 0 {
+		return fmt.Errorf("watch interval must be positive")
+	}
+	if config.Scan == nil {
+		return fmt.Errorf("watch needs a scan function")
+	}
+	if config.Clock == nil {
+		config.Clock = SystemClock
+	}
+
+	state, err := LoadState(config.StatePath)
+	if err != nil {
+		return err
+	}
+
+	next := config.Clock.Now()
+	if state != nil {
+		next = state.LastScan.Add(config.Interval)
+	}
+
+	for {
+		if wait := next.Sub(config.Clock.Now()); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-config.Clock.After(wait):
+			}
+		}
+
+		started := config.Clock.Now()
+		state, err = scan(ctx, config, state, started)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil && config.OnError != nil {
+			config.OnError(err)
+		}
+
+		// A scan that overran the interval is followed by the next one
+		// right away.
+		next = started.Add(config.Interval)
+	}
+}
+
+// scan runs one scan, sends its changes and saves it as the new state. It
+// returns the state to compare the next scan with, which is the old one
+// when the scan or a sink failed: the next scan then reports the changes
+// that were not delivered again.
+func scan(ctx context.Context, config Config, state *State, started time.Time) (*State, error) {
+	results, err := config.Scan(ctx)
+	if ctx.Err() != nil {
+		// Stopped mid-scan: the partial scan is dropped.
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("scan failed: %w", err)
+	}
+
+	var previous State
+	if state != nil {
+		previous = *state
+	}
+	current := &State{
+		Usernames: config.Usernames,
+		LastScan:  started,
+		Results:   merge(previous.Results, results, config.Usernames),
+	}
+
+	report := compare(previous, *current)
+
+	var errs []error
+	if report.Changed() {
+		for _, sink := range config.Sinks {
+			if err := sink.Send(ctx, report); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	next := current
+	if len(errs) > 0 {
+		next = state
+	} else if err := SaveState(config.StatePath, current); err != nil {
+		errs = append(errs, err)
+	}
+
+	if config.OnScan != nil {
+		config.OnScan(report, started.Add(config.Interval))
+	}
+	return next, errors.Join(errs...)
+}
+
+// compare compares two scans and keeps the changes a watcher reports.
+func compare(previous, current State) diff.Report {
+	report := diff.Compare(previous.Results, current.Results)
+	report.Old = diff.Scan{Timestamp: previous.LastScan, Usernames: previous.Usernames}
+	report.New = diff.Scan{Timestamp: current.LastScan, Usernames: current.Usernames}
+
+	changes := report.Changes[:0]
+	for _, change := range report.Changes {
+		if reported[change.Kind] {
+			changes = append(changes, change)
+		}
+	}
+	report.Changes = changes
+	return report
+}
+
+// merge returns the results of a scan with the checks it did not settle
+// replaced by their previous result, if there is one. Checks the scan did
+// not get to are carried over the same way, for usernames still watched.
+func merge(previous, current []core.SiteResult, usernames []string) []core.SiteResult {
+	before := make(map[string]core.SiteResult, len(previous))
+	for _, result := range previous {
+		before[key(result)] = result
+	}
+
+	merged := make([]core.SiteResult, 0, len(current))
+	seen := make(map[string]bool, len(current))
+	for _, result := range current {
+		k := key(result)
+		seen[k] = true
+		if old, ok := before[k]; ok && !settled(result.ResultStatus) {
+			result = old
+		}
+		// The state file keeps what the comparison needs, not the bodies.
+		result.ResponseText = ""
+		merged = append(merged, result)
+	}
+
+	watched := make(map[string]bool, len(usernames))
+	for _, username := range usernames {
+		watched[username] = true
+	}
+	for _, result := range previous {
+		if !seen[key(result)] && watched[result.Username] {
+			merged = append(merged, result)
+		}
+	}
+	return merged
+}
+
+func settled(status core.ResultStatus) bool {
+	switch status {
+	case core.ResultStatusError, core.ResultStatusUnknown, core.ResultStatusCancelled:
+		return false
+	}
+	return true
+}
+
+func key(result core.SiteResult) string {
+	return result.Username + "\x00" + result.SiteName
+}
@@ -0,0 +1,388 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/internal/diff"
+	"github.com/gnomegl/usrsx/internal/notify"
+)
+
+// fakeClock moves time forward only when the watcher waits or a scan
+// calls advance, so tests run without sleeping.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits = append(c.waits, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// recordingSink keeps the reports it takes. It refuses the first fail
+// reports it is sent.
+type recordingSink struct {
+	reports []diff.Report
+	fail    int
+}
+
+func (s *recordingSink) Send(ctx context.Context, report diff.Report) error {
+	if s.fail > 0 {
+		s.fail--
+		return errors.New("sink unavailable")
+	}
+	s.reports = append(s.reports, report)
+	return nil
+}
+
+func result(site string, status core.ResultStatus) core.SiteResult {
+	return core.SiteResult{SiteName: site, Username: "alice", ResultStatus: status}
+}
+
+// watcher runs a watcher through a list of scans and records when each
+// one started, the next scan it announced and what it sent.
+type watcher struct {
+	clock    *fakeClock
+	sink     *recordingSink
+	started  []time.Time
+	next     []time.Time
+	errs     []error
+	interval time.Duration
+	path     string
+}
+
+type step struct {
+	results  []core.SiteResult
+	err      error
+	duration time.Duration
+}
+
+func newWatcher(t *testing.T, interval time.Duration) *watcher {
+	return &watcher{
+		clock:    newFakeClock(),
+		sink:     &recordingSink{},
+		interval: interval,
+		path:     filepath.Join(t.TempDir(), "state.json"),
+	}
+}
+
+func (w *watcher) run(t *testing.T, steps ...step) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	scans := 0
+	stopAfter := func() {
+		if scans == len(steps) {
+			cancel()
+		}
+	}
+	err := Run(ctx, Config{
+		Usernames: []string{"alice"},
+		Interval:  w.interval,
+		StatePath: w.path,
+		Clock:     w.clock,
+		Sinks:     []Sink{w.sink},
+		Scan: func(ctx context.Context) ([]core.SiteResult, error) {
+			s := steps[scans]
+			scans++
+			w.started = append(w.started, w.clock.Now())
+			w.clock.advance(s.duration)
+			return s.results, s.err
+		},
+		OnScan: func(report diff.Report, next time.Time) {
+			w.next = append(w.next, next)
+			stopAfter()
+		},
+		OnError: func(err error) {
+			w.errs = append(w.errs, err)
+			stopAfter()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scans != len(steps) {
+		t.Fatalf("ran %d scans, want %d", scans, len(steps))
+	}
+}
+
+func TestRunFirstScan(t *testing.T) {
+	w := newWatcher(t, time.Hour)
+	start := w.clock.Now()
+	w.run(t, step{results: []core.SiteResult{
+		result("A", core.ResultStatusFound),
+		result("B", core.ResultStatusNotFound),
+	}})
+
+	if len(w.clock.waits) != 0 || !w.started[0].Equal(start) {
+		t.Errorf("first scan at %s after waiting %v, want right away", w.started[0], w.clock.waits)
+	}
+	if len(w.next) != 1 || !w.next[0].Equal(start.Add(time.Hour)) {
+		t.Errorf("next scan = %v, want %s", w.next, start.Add(time.Hour))
+	}
+	if len(w.sink.reports) != 1 {
+		t.Fatalf("sent %d reports, want 1", len(w.sink.reports))
+	}
+	changes := w.sink.reports[0].Changes
+	if len(changes) != 1 || changes[0].Kind != diff.KindAppeared || changes[0].SiteName != "A" {
+		t.Errorf("changes = %+v, want A as appeared", changes)
+	}
+
+	state, err := LoadState(w.path)
+	if err != nil || state == nil {
+		t.Fatalf("LoadState = %v, %v", state, err)
+	}
+	if !state.LastScan.Equal(start) || len(state.Results) != 2 {
+		t.Errorf("state = %+v, want the first scan", state)
+	}
+}
+
+func TestRunResumes(t *testing.T) {
+	tests := []struct {
+		name     string
+		lastScan time.Duration // before now
+		wait     []time.Duration
+	}{
+		{name: "within interval", lastScan: 20 * time.Minute, wait: []time.Duration{40 * time.Minute}},
+		{name: "overdue", lastScan: 3 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newWatcher(t, time.Hour)
+			last := w.clock.Now().Add(-tt.lastScan)
+			previous := &State{
+				Usernames: []string{"alice"},
+				LastScan:  last,
+				Results:   []core.SiteResult{result("A", core.ResultStatusFound)},
+			}
+			if err := SaveState(w.path, previous); err != nil {
+				t.Fatal(err)
+			}
+
+			w.run(t, step{results: []core.SiteResult{result("A", core.ResultStatusFound)}})
+
+			if !reflect.DeepEqual(w.clock.waits, tt.wait) {
+				t.Errorf("waited %v, want %v", w.clock.waits, tt.wait)
+			}
+			if want := last.Add(time.Hour); tt.wait != nil && !w.started[0].Equal(want) {
+				t.Errorf("scan started at %s, want %s", w.started[0], want)
+			}
+			// The state file is compared against, so nothing is new.
+			if len(w.sink.reports) != 0 {
+				t.Errorf("sent %+v after a restart, want nothing", w.sink.reports)
+			}
+		})
+	}
+}
+
+func TestRunOverrun(t *testing.T) {
+	w := newWatcher(t, 10*time.Minute)
+	start := w.clock.Now()
+	found := []core.SiteResult{result("A", core.ResultStatusFound)}
+	w.run(t,
+		step{results: found, duration: 15 * time.Minute},
+		step{results: found, duration: time.Minute},
+		step{results: found},
+	)
+
+	want := []time.Time{start, start.Add(15 * time.Minute), start.Add(25 * time.Minute)}
+	if !reflect.DeepEqual(w.started, want) {
+		t.Errorf("scans started at %v, want %v", w.started, want)
+	}
+	// Only the scan that finished early waits for the rest of its interval.
+	if !reflect.DeepEqual(w.clock.waits, []time.Duration{9 * time.Minute}) {
+		t.Errorf("waited %v, want [9m]", w.clock.waits)
+	}
+}
+
+func TestRunCarriesForwardUnsettled(t *testing.T) {
+	w := newWatcher(t, time.Hour)
+	w.run(t,
+		step{results: []core.SiteResult{
+			result("A", core.ResultStatusFound),
+			result("B", core.ResultStatusFound),
+			result("C", core.ResultStatusFound),
+		}},
+		// B fails and C is not checked at all: neither has disappeared.
+		step{results: []core.SiteResult{
+			result("A", core.ResultStatusFound),
+			result("B", core.ResultStatusError),
+		}},
+		step{err: errors.New("list unavailable")},
+		step{results: []core.SiteResult{
+			result("A", core.ResultStatusFound),
+			result("B", core.ResultStatusUnknown),
+			result("C", core.ResultStatusFound),
+		}},
+		// Only a settled not found counts as disappeared.
+		step{results: []core.SiteResult{
+			result("A", core.ResultStatusFound),
+			result("B", core.ResultStatusNotFound),
+			result("C", core.ResultStatusFound),
+		}},
+	)
+
+	if len(w.errs) != 1 {
+		t.Errorf("errors = %v, want the failed scan", w.errs)
+	}
+	if len(w.sink.reports) != 2 {
+		t.Fatalf("sent %d reports, want the first scan and B disappearing", len(w.sink.reports))
+	}
+	changes := w.sink.reports[1].Changes
+	if len(changes) != 1 || changes[0].Kind != diff.KindDisappeared || changes[0].SiteName != "B" {
+		t.Errorf("changes = %+v, want B as disappeared", changes)
+	}
+
+	state, err := LoadState(w.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[string]core.ResultStatus)
+	for _, r := range state.Results {
+		statuses[r.SiteName] = r.ResultStatus
+	}
+	want := map[string]core.ResultStatus{"A": "found", "B": "not_found", "C": "found"}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("state = %v, want %v", statuses, want)
+	}
+}
+
+func TestRunSinkFailure(t *testing.T) {
+	w := newWatcher(t, time.Hour)
+	w.sink.fail = 1
+	last := w.clock.Now().Add(-2 * time.Hour)
+	previous := &State{
+		Usernames: []string{"alice"},
+		LastScan:  last,
+		Results:   []core.SiteResult{result("A", core.ResultStatusNotFound)},
+	}
+	if err := SaveState(w.path, previous); err != nil {
+		t.Fatal(err)
+	}
+
+	found := []core.SiteResult{result("A", core.ResultStatusFound)}
+	w.run(t, step{results: found}, step{results: found}, step{results: found})
+
+	if len(w.errs) != 1 {
+		t.Errorf("errors = %v, want the failed sink", w.errs)
+	}
+	// The change the sink refused is reported again by the next scan,
+	// still against the saved scan, and only once delivered is it saved.
+	if len(w.sink.reports) != 1 {
+		t.Fatalf("delivered %d reports, want 1", len(w.sink.reports))
+	}
+	report := w.sink.reports[0]
+	if len(report.Changes) != 1 || report.Changes[0].Kind != diff.KindAppeared || report.Changes[0].SiteName != "A" {
+		t.Errorf("changes = %+v, want A as appeared", report.Changes)
+	}
+	if !report.Old.Timestamp.Equal(last) || !report.New.Timestamp.Equal(w.started[1]) {
+		t.Errorf("report compares %s with %s, want %s with %s", report.Old.Timestamp, report.New.Timestamp, last, w.started[1])
+	}
+
+	state, err := LoadState(w.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.LastScan.Equal(w.started[2]) {
+		t.Errorf("state saved at %s, want the last scan at %s", state.LastScan, w.started[2])
+	}
+}
+
+func TestMerge(t *testing.T) {
+	bob := result("A", core.ResultStatusFound)
+	bob.Username = "bob"
+	previous := []core.SiteResult{result("A", core.ResultStatusFound), result("B", core.ResultStatusFound), bob}
+	current := []core.SiteResult{result("A", core.ResultStatusCancelled)}
+	current[0].ResponseText = "<html>"
+
+	got := merge(previous, current, []string{"alice"})
+	want := []core.SiteResult{result("A", core.ResultStatusFound), result("B", core.ResultStatusFound)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merge = %+v, want %+v without the unwatched bob", got, want)
+	}
+}
+
+func TestRunConfig(t *testing.T) {
+	scan := func(ctx context.Context) ([]core.SiteResult, error) { return nil, nil }
+	if err := Run(context.Background(), Config{Scan: scan}); err == nil {
+		t.Error("zero interval accepted")
+	}
+	if err := Run(context.Background(), Config{Interval: time.Hour}); err == nil {
+		t.Error("missing scan function accepted")
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	var mu sync.Mutex
+	var statuses []int
+	var received []diff.Report
+	respond := []int{http.StatusServiceUnavailable, http.StatusOK, http.StatusBadRequest}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		status := respond[min(len(statuses), len(respond)-1)]
+		statuses = append(statuses, status)
+		var report diff.Report
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("invalid report: %v", err)
+		}
+		received = append(received, report)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := NewWebhook(server.URL+"/hook/secret", notify.Config{RetryDelay: time.Millisecond})
+	report := diff.Report{Changes: []diff.Change{{Kind: diff.KindAppeared, Username: "alice", SiteName: "A"}}}
+
+	// The 503 is retried.
+	if err := sink.Send(context.Background(), report); err != nil {
+		t.Fatalf("Send() = %v", err)
+	}
+	if len(received) != 2 || !reflect.DeepEqual(received[1].Changes, report.Changes) {
+		t.Errorf("received %+v, want the report twice", received)
+	}
+
+	// The 400 is not.
+	err := sink.Send(context.Background(), report)
+	if err == nil || !strings.Contains(err.Error(), "400") || strings.Contains(err.Error(), "secret") {
+		t.Errorf("Send() = %v, want the 400 without the URL", err)
+	}
+	if len(statuses) != 3 {
+		t.Errorf("posted %d times, want 3", len(statuses))
+	}
+}
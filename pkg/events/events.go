@@ -0,0 +1,136 @@
+// Package events defines the event stream usrsx writes with --json: one
+// JSON object per line (NDJSON), each carrying its Type and the
+// SchemaVersion it was written with. A run produces run_started, one
+// site_loaded per checked site, result and progress events while the scan
+// runs, and a final run_finished.
+//
+// Fields are only ever added within a schema version. Renaming or removing
+// a field, or changing its meaning, bumps SchemaVersion. Schema holds the
+// JSON Schema of every event.
+package events
+
+import (
+	_ "embed"
+	"time"
+)
+
+// SchemaVersion is the version of the event format written by this
+// package.
+const SchemaVersion = 1
+
+// Schema is the JSON Schema (draft-07) describing every event.
+//
+//go:embed schema.json
+var Schema []byte
+
+type Type string
+
+const (
+	TypeRunStarted  Type = "run_started"
+	TypeSiteLoaded  Type = "site_loaded"
+	TypeResult      Type = "result"
+	TypeProgress    Type = "progress"
+	TypeRunFinished Type = "run_finished"
+)
+
+// Mode is what a run checks.
+type Mode string
+
+const (
+	ModeUsernames Mode = "usernames"
+	ModeSelfCheck Mode = "self_check"
+)
+
+// Header starts every event. Consumers read Type to pick the struct to
+// decode the line into.
+type Header struct {
+	SchemaVersion int       `json:"schema_version"`
+	Type          Type      `json:"type"`
+	Time          time.Time `json:"time"`
+}
+
+// NewHeader returns the header of an event of type t at time at.
+func NewHeader(t Type, at time.Time) Header {
+	return Header{SchemaVersion: SchemaVersion, Type: t, Time: at.UTC()}
+}
+
+// RunStarted is written once before any other event.
+type RunStarted struct {
+	Header
+	Version   string   `json:"version"`
+	Mode      Mode     `json:"mode"`
+	Usernames []string `json:"usernames"`
+	Sites     int      `json:"sites"`
+	Total     int      `json:"total"`
+}
+
+// SiteLoaded is written for each site that will be checked.
+type SiteLoaded struct {
+	Header
+	SiteName string `json:"site_name"`
+	Category string `json:"category"`
+}
+
+// Result is a single check. Its fields use the same names as the results
+// of a --json-output file.
+type Result struct {
+	Header
+	Username      string           `json:"username"`
+	SiteName      string           `json:"site_name"`
+	Category      string           `json:"category"`
+	ResultStatus  string           `json:"result_status"`
+	ResultURL     string           `json:"result_url,omitempty"`
+	ResponseCode  int              `json:"response_code,omitempty"`
+	ResponsePath  string           `json:"response_path,omitempty"`
+	Elapsed       float64          `json:"elapsed"`
+	Attempts      int              `json:"attempts,omitempty"`
+	Proxy         string           `json:"proxy,omitempty"`
+	Error         string           `json:"error,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Metadata      *ProfileMetadata `json:"metadata,omitempty"`
+	FalsePositive bool             `json:"false_positive,omitempty"`
+}
+
+type ProfileMetadata struct {
+	DisplayName     string            `json:"display_name,omitempty"`
+	Bio             string            `json:"bio,omitempty"`
+	AvatarURL       string            `json:"avatar_url,omitempty"`
+	Location        string            `json:"location,omitempty"`
+	Website         string            `json:"website,omitempty"`
+	JoinDate        string            `json:"join_date,omitempty"`
+	FollowerCount   int               `json:"follower_count,omitempty"`
+	FollowingCount  int               `json:"following_count,omitempty"`
+	IsVerified      bool              `json:"is_verified,omitempty"`
+	AdditionalLinks map[string]string `json:"additional_links,omitempty"`
+	CustomFields    map[string]string `json:"custom_fields,omitempty"`
+}
+
+// Progress is written at most once per interval while results come in,
+// and counts every check whether or not its result event was written.
+type Progress struct {
+	Header
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
+	Found     int `json:"found"`
+}
+
+// RunFinished is the last event of a run. Error is set when the run was
+// aborted; the summary then covers the checks that completed.
+type RunFinished struct {
+	Header
+	Elapsed float64 `json:"elapsed"`
+	Summary Summary `json:"summary"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Summary counts results by status.
+type Summary struct {
+	Total     int `json:"total"`
+	Found     int `json:"found"`
+	NotFound  int `json:"not_found"`
+	Errors    int `json:"errors"`
+	Unknown   int `json:"unknown"`
+	Ambiguous int `json:"ambiguous"`
+	NotValid  int `json:"not_valid"`
+	Cancelled int `json:"cancelled"`
+}
@@ -0,0 +1,168 @@
+package usrsx_test
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gnomegl/usrsx/pkg/usrsx"
+)
+
+// exampleSites serves two sites with an account for alice, compressing
+// responses like most real sites do, and returns them as a site source.
+func exampleSites() (usrsx.StaticSites, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		title, status := "not found", http.StatusNotFound
+		if strings.HasSuffix(r.URL.Path, "/alice") {
+			title, status = "profile", http.StatusOK
+		}
+		body := "<html><head><title>" + title + "</title></head><body>" +
+			strings.Repeat("<p>Lorem ipsum dolor sit amet.</p>", 20) + "</body></html>"
+
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(status)
+		zw := gzip.NewWriter(w)
+		fmt.Fprint(zw, body)
+		zw.Close()
+	}))
+
+	found, missing := http.StatusOK, http.StatusNotFound
+	var sites usrsx.StaticSites
+	for _, name := range []string{"Forum", "Gallery"} {
+		sites = append(sites, usrsx.Site{
+			Name:     name,
+			Category: "social",
+			URICheck: server.URL + "/" + strings.ToLower(name) + "/{account}",
+			ECode:    &found,
+			EString:  "profile",
+			MCode:    &missing,
+			MString:  "not found",
+		})
+	}
+	return sites, server.Close
+}
+
+func Example() {
+	sites, stop := exampleSites()
+	defer stop()
+
+	scanner, err := usrsx.New(usrsx.Options{Sites: sites, Concurrency: 20})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = scanner.Run(context.Background(), []string{"alice", "bob"}, usrsx.SinkFunc(
+		func(ctx context.Context, result usrsx.SiteResult) error {
+			if result.ResultStatus == usrsx.StatusFound {
+				fmt.Println(result.Username, "is on", result.SiteName)
+			}
+			return nil
+		}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Unordered output:
+	// alice is on Forum
+	// alice is on Gallery
+}
+
+func Example_channel() {
+	sites, stop := exampleSites()
+	defer stop()
+
+	scanner, err := usrsx.New(usrsx.Options{Sites: sites})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	results := make(chan usrsx.SiteResult)
+	go func() {
+		defer close(results)
+		if err := scanner.Run(context.Background(), []string{"bob"}, usrsx.ChannelSink(results)); err != nil {
+			log.Print(err)
+		}
+	}()
+	for result := range results {
+		fmt.Println(result.SiteName, result.ResultStatus)
+	}
+	// Unordered output:
+	// Forum not_found
+	// Gallery not_found
+}
+
+func ExampleOptions_httpClient() {
+	sites, stop := exampleSites()
+	defer stop()
+
+	// The caller's client sends the checks as it is configured; usrsx
+	// still adds browser headers and decodes compressed responses.
+	scanner, err := usrsx.New(usrsx.Options{
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		Sites:      sites[:1],
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = scanner.Run(context.Background(), []string{"alice"}, usrsx.SinkFunc(
+		func(ctx context.Context, result usrsx.SiteResult) error {
+			fmt.Println(result.SiteName, result.ResultStatus, result.ResponseCode)
+			return nil
+		}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// Forum found 200
+}
+
+func ExampleLists() {
+	dir, err := os.MkdirTemp("", "usrsx-example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sites.json")
+	list := `{"sites": [
+		{"name": "Forum", "cat": "social", "uri_check": "https://forum.example/u/{account}", "e_code": 200, "e_string": "profile"},
+		{"name": "Gallery", "cat": "art", "uri_check": "https://gallery.example/{account}", "e_code": 200, "e_string": "profile"}
+	]}`
+	if err := os.WriteFile(path, []byte(list), 0o644); err != nil {
+		log.Fatal(err)
+	}
+
+	sites, err := usrsx.Lists{Sources: []string{path}}.Sites(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, site := range sites {
+		fmt.Println(site.Name, site.Category)
+	}
+	// Output:
+	// Forum social
+	// Gallery art
+}
+
+func ExampleGetResultStatus() {
+	found, missing := http.StatusOK, http.StatusNotFound
+	site := usrsx.Site{Name: "Forum", ECode: &found, EString: "profile", MCode: &missing, MString: "not found"}
+
+	fmt.Println(usrsx.GetResultStatus(site, 200, "<title>profile</title>", false))
+	fmt.Println(usrsx.GetResultStatus(site, 404, "<title>not found</title>", false))
+	fmt.Println(usrsx.GetResultStatus(site, 200, "<title>not found</title>", false))
+	// Output:
+	// found
+	// not_found
+	// unknown
+}
@@ -0,0 +1,32 @@
+package usrsx
+
+import "context"
+
+// ResultSink receives the results of a scan as they arrive. Run calls
+// Send from one goroutine, so a sink need not be safe for concurrent use.
+// An error from Send stops the scan and is returned by Run.
+type ResultSink interface {
+	Send(ctx context.Context, result SiteResult) error
+}
+
+// SinkFunc is a callback used as a ResultSink.
+type SinkFunc func(ctx context.Context, result SiteResult) error
+
+func (f SinkFunc) Send(ctx context.Context, result SiteResult) error {
+	return f(ctx, result)
+}
+
+// ChannelSink sends every result on ch. The channel is not closed; Run
+// returning means no more results will be sent. A send blocks until ch
+// takes the result or the context of Run ends, which drops the results
+// still to come.
+func ChannelSink(ch chan<- SiteResult) ResultSink {
+	return SinkFunc(func(ctx context.Context, result SiteResult) error {
+		select {
+		case ch <- result:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
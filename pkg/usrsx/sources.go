@@ -0,0 +1,80 @@
+package usrsx
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/cli"
+	"github.com/gnomegl/usrsx/internal/client"
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+// SiteSource provides the sites a scan checks. Run asks for them once per
+// scan, so a source may pick up list updates between scans.
+type SiteSource interface {
+	Sites(ctx context.Context) ([]Site, error)
+}
+
+// StaticSites is a fixed set of sites.
+type StaticSites []Site
+
+func (s StaticSites) Sites(ctx context.Context) ([]Site, error) {
+	return s, nil
+}
+
+// Lists loads site lists in the WhatsMyName format from files and URLs,
+// like the --local-list and --remote-list options. Sites in later lists
+// replace those of the same name in earlier ones.
+type Lists struct {
+	// Sources are file paths and http(s) URLs. Empty means the
+	// WhatsMyName list.
+	Sources []string
+
+	// CacheDir keeps copies of remote lists, revalidated once they are
+	// older than CacheMaxAge. Empty disables the cache.
+	CacheDir    string
+	CacheMaxAge time.Duration
+
+	// Offline loads remote lists from the cache only.
+	Offline bool
+
+	// HTTPClient fetches remote lists. Defaults to one like the scanner's.
+	HTTPClient *http.Client
+}
+
+func (l Lists) Sites(ctx context.Context) ([]Site, error) {
+	httpClient, err := newHTTPClient(l.HTTPClient)
+	if err != nil {
+		return nil, err
+	}
+
+	// LoadWMNData tells files and URLs apart itself, so keeping all the
+	// sources in one list keeps their order.
+	wmnData, err := cli.LoadWMNData(ctx, &cli.Config{
+		LocalLists:  l.Sources,
+		CacheDir:    l.CacheDir,
+		CacheMaxAge: l.CacheMaxAge,
+		Offline:     l.Offline,
+	}, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	sites := make([]Site, len(wmnData.Sites))
+	for i, site := range wmnData.Sites {
+		sites[i] = Site(site)
+	}
+	return sites, nil
+}
+
+// newHTTPClient wraps base, or builds a client with the usrsx command's
+// defaults when it is nil.
+func newHTTPClient(base *http.Client) (*client.HTTPClient, error) {
+	return client.NewHTTPClient(client.ClientConfig{
+		Timeout:       core.HTTPRequestTimeoutSeconds,
+		VerifySSL:     core.HTTPSSLVerify,
+		AllowRedirect: core.HTTPAllowRedirects,
+		Impersonate:   client.BrowserChrome,
+		Client:        base,
+	})
+}
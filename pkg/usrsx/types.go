@@ -0,0 +1,101 @@
+package usrsx
+
+import (
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+)
+
+type ResultStatus string
+
+const (
+	StatusFound     ResultStatus = "found"
+	StatusNotFound  ResultStatus = "not_found"
+	StatusError     ResultStatus = "error"
+	StatusUnknown   ResultStatus = "unknown"
+	StatusAmbiguous ResultStatus = "ambiguous"
+	StatusNotValid  ResultStatus = "not_valid"
+	// StatusCancelled is a check cut short because the context of Run
+	// ended.
+	StatusCancelled ResultStatus = "cancelled"
+)
+
+// Site is one site in the WhatsMyName list format. URICheck holds the
+// {account} placeholder; ECode and EString detect an existing account,
+// MCode and MString a missing one.
+type Site struct {
+	Name         string            `json:"name"`
+	Category     string            `json:"cat"`
+	URICheck     string            `json:"uri_check"`
+	URIPretty    string            `json:"uri_pretty,omitempty"`
+	ECode        *int              `json:"e_code,omitempty"`
+	EString      string            `json:"e_string,omitempty"`
+	MCode        *int              `json:"m_code,omitempty"`
+	MString      string            `json:"m_string,omitempty"`
+	Known        []string          `json:"known,omitempty"`
+	PostBody     string            `json:"post_body,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	StripBadChar string            `json:"strip_bad_char,omitempty"`
+	RateLimit    float64           `json:"rate_limit,omitempty"`
+}
+
+// SiteResult is the outcome of checking one username on one site, with
+// the fields of a result in a usrsx JSON export.
+type SiteResult struct {
+	SiteName     string           `json:"site_name"`
+	Category     string           `json:"category"`
+	Username     string           `json:"username"`
+	ResultStatus ResultStatus     `json:"result_status"`
+	ResultURL    string           `json:"result_url,omitempty"`
+	ResponseCode int              `json:"response_code,omitempty"`
+	Metadata     *ProfileMetadata `json:"metadata,omitempty"`
+	// Elapsed is in seconds.
+	Elapsed   float64   `json:"elapsed,omitempty"`
+	Attempts  int       `json:"attempts,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProfileMetadata is what could be read off a found profile.
+type ProfileMetadata struct {
+	DisplayName     string            `json:"display_name,omitempty"`
+	Bio             string            `json:"bio,omitempty"`
+	AvatarURL       string            `json:"avatar_url,omitempty"`
+	Location        string            `json:"location,omitempty"`
+	Website         string            `json:"website,omitempty"`
+	JoinDate        string            `json:"join_date,omitempty"`
+	FollowerCount   int               `json:"follower_count,omitempty"`
+	FollowingCount  int               `json:"following_count,omitempty"`
+	IsVerified      bool              `json:"is_verified,omitempty"`
+	AdditionalLinks map[string]string `json:"additional_links,omitempty"`
+	CustomFields    map[string]string `json:"custom_fields,omitempty"`
+}
+
+// GetResultStatus classifies a response from site the way the checker
+// does. In fuzzy mode any one of the site's conditions is enough; otherwise
+// all of them must hold.
+func GetResultStatus(site Site, responseCode int, responseText string, fuzzy bool) ResultStatus {
+	return ResultStatus(core.GetResultStatus(responseCode, responseText,
+		site.ECode, site.EString, site.MCode, site.MString, fuzzy))
+}
+
+// newSiteResult converts a result of the checker.
+func newSiteResult(result core.SiteResult) SiteResult {
+	converted := SiteResult{
+		SiteName:     result.SiteName,
+		Category:     result.Category,
+		Username:     result.Username,
+		ResultStatus: ResultStatus(result.ResultStatus),
+		ResultURL:    result.ResultURL,
+		ResponseCode: result.ResponseCode,
+		Elapsed:      result.Elapsed,
+		Attempts:     result.Attempts,
+		Error:        result.Error,
+		CreatedAt:    result.CreatedAt,
+	}
+	if m := result.Metadata; m != nil {
+		metadata := ProfileMetadata(*m)
+		converted.Metadata = &metadata
+	}
+	return converted
+}
@@ -0,0 +1,163 @@
+// Package usrsx checks usernames across sites from Go programs, with the
+// same checker as the usrsx command. A Scanner is built once from Options
+// and runs any number of scans, each taking its sites from a SiteSource
+// and handing its results to a ResultSink.
+//
+// With a callback:
+//
+//	scanner, err := usrsx.New(usrsx.Options{Concurrency: 20})
+//	if err != nil {
+//		return err
+//	}
+//	err = scanner.Run(ctx, []string{"alice"}, usrsx.SinkFunc(
+//		func(ctx context.Context, result usrsx.SiteResult) error {
+//			if result.ResultStatus == usrsx.StatusFound {
+//				fmt.Println(result.SiteName, result.ResultURL)
+//			}
+//			return nil
+//		}))
+//
+// With a channel:
+//
+//	results := make(chan usrsx.SiteResult)
+//	go func() {
+//		defer close(results)
+//		if err := scanner.Run(ctx, usernames, usrsx.ChannelSink(results)); err != nil {
+//			log.Print(err)
+//		}
+//	}()
+//	for result := range results {
+//		// ...
+//	}
+//
+// Site lists come from Lists, the WhatsMyName list by default, or from
+// StaticSites or any other SiteSource.
+package usrsx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gnomegl/usrsx/internal/core"
+	"github.com/gnomegl/usrsx/internal/utils"
+)
+
+type Options struct {
+	// HTTPClient sends the checks. Its Timeout, redirect policy and
+	// transport apply as they are; requests still carry Chrome's headers
+	// and the site's, and compressed responses are decoded. Defaults to
+	// the usrsx command's client: Chrome's TLS fingerprint, a 30s timeout,
+	// no redirects.
+	HTTPClient *http.Client
+
+	// Sites defaults to Lists{}, the WhatsMyName list.
+	Sites SiteSource
+
+	// Concurrency is the most checks in flight, across all running scans
+	// of the Scanner. Default: 50.
+	Concurrency int
+
+	// PerHostRPS limits the requests per second to each host; 0 is no
+	// limit.
+	PerHostRPS float64
+
+	// MaxAttempts is how often a check is tried when it fails with a
+	// timeout, a reset connection or a 429 or 5xx response. Default: 3.
+	MaxAttempts int
+
+	// Fuzzy classifies a response as found or missing when any one of the
+	// site's conditions holds, instead of all of them.
+	Fuzzy bool
+}
+
+// Scanner runs scans. It is safe for concurrent use.
+type Scanner struct {
+	checker     *core.Checker
+	sites       SiteSource
+	concurrency int
+	fuzzy       bool
+}
+
+func New(options Options) (*Scanner, error) {
+	if options.Sites == nil {
+		options.Sites = Lists{}
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = core.MaxConcurrentTasks
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = core.RetryMaxAttempts
+	}
+	if err := utils.ValidatePerHostRPS(options.PerHostRPS); err != nil {
+		return nil, err
+	}
+
+	httpClient, err := newHTTPClient(options.HTTPClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+
+	return &Scanner{
+		checker: core.NewChecker(httpClient, nil, core.CheckerConfig{
+			MaxTasks:   options.Concurrency,
+			PerHostRPS: options.PerHostRPS,
+			Retry: core.RetryPolicy{
+				MaxAttempts: options.MaxAttempts,
+				BaseDelay:   core.RetryBaseDelayMillis * time.Millisecond,
+				MaxDelay:    core.RetryMaxDelaySeconds * time.Second,
+				StatusCodes: core.DefaultRetryStatusCodes,
+			},
+		}),
+		sites:       options.Sites,
+		concurrency: options.Concurrency,
+		fuzzy:       options.Fuzzy,
+	}, nil
+}
+
+// Run checks usernames on every site of the scanner's source and sends
+// each result to sink as it arrives. When ctx ends, the checks in flight
+// are aborted, the rest are sent as StatusCancelled and Run returns ctx's
+// error.
+func (s *Scanner) Run(ctx context.Context, usernames []string, sink ResultSink) error {
+	usernames, err := utils.ValidateUsernames(usernames)
+	if err != nil {
+		return err
+	}
+	sites, err := s.sites.Sites(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load sites: %w", err)
+	}
+	checkSites := make([]core.Site, len(sites))
+	for i, site := range sites {
+		checkSites[i] = core.Site(site)
+	}
+
+	scanCtx, stopScan := context.WithCancel(ctx)
+	defer stopScan()
+
+	progressChan := make(chan core.SiteResult, s.concurrency)
+	go func() {
+		s.checker.CheckUsernames(scanCtx, usernames, checkSites, s.fuzzy, progressChan)
+		close(progressChan)
+	}()
+
+	var sinkErr error
+	for result := range progressChan {
+		// After a sink error the scan is being stopped; the rest of the
+		// results are only drained.
+		if sinkErr != nil {
+			continue
+		}
+		if err := sink.Send(scanCtx, newSiteResult(result)); err != nil {
+			sinkErr = err
+			stopScan()
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return sinkErr
+}